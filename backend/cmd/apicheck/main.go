@@ -0,0 +1,287 @@
+// Command apicheck is the API contract test. It fails if a route is
+// registered without an OpenAPI entry or the other way round, and, given a
+// running server, if any read-only endpoint answers with a status or body
+// the spec doesn't describe.
+//
+//	go run ./cmd/apicheck
+//	go run ./cmd/apicheck -base-url http://localhost:8080/api -email admin@cpool.ai -password ...
+//
+// The live check logs in, calls every GET operation and fills path
+// parameters from ids found in list responses. Use an admin account to
+// cover the admin endpoints. TestResponsesMatchSpec in internal/handlers
+// covers every operation, writes included, against a test database.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cpool.ai/backend/internal/apispec"
+	"cpool.ai/backend/internal/config"
+	"cpool.ai/backend/internal/handlers"
+	"cpool.ai/backend/internal/presence"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	baseURL := flag.String("base-url", "", "API base URL of a running server, e.g. http://localhost:8080/api; routes are only compared without it")
+	email := flag.String("email", os.Getenv("APICHECK_EMAIL"), "account to log in with")
+	password := flag.String("password", os.Getenv("APICHECK_PASSWORD"), "password for -email")
+	flag.Parse()
+
+	doc := handlers.Spec()
+	failures := checkRoutes(doc)
+
+	if *baseURL != "" {
+		c := &checker{
+			doc:     doc,
+			baseURL: strings.TrimSuffix(*baseURL, "/"),
+			client:  &http.Client{Timeout: 30 * time.Second},
+			ids:     map[string]string{},
+		}
+		failures += c.run(*email, *password)
+	}
+
+	if failures > 0 {
+		fmt.Printf("\n%d contract failure(s)\n", failures)
+		os.Exit(1)
+	}
+	fmt.Println("\nAPI matches the spec")
+}
+
+// checkRoutes compares the routes the server mounts with the operations in
+// the spec
+func checkRoutes(doc *apispec.Document) int {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	h := handlers.New(nil, &config.Config{}, nil, nil, nil, nil, nil, nil)
+	h.RegisterRoutes(router, presence.NewTracker(nil))
+
+	failures := 0
+	mounted := map[string]bool{}
+	for _, route := range router.Routes() {
+		key := route.Method + " " + apispec.OpenAPIPath(strings.TrimPrefix(route.Path, doc.BasePath()))
+		mounted[key] = true
+		if doc.Operation(route.Method, route.Path) == nil {
+			fmt.Printf("FAIL %s %s is registered but not in the spec\n", route.Method, route.Path)
+			failures++
+		}
+	}
+
+	for _, key := range operationKeys(doc) {
+		if !mounted[key] {
+			fmt.Printf("FAIL %s is in the spec but not registered\n", key)
+			failures++
+		}
+	}
+
+	if failures == 0 {
+		fmt.Printf("ok   %d routes documented\n", len(mounted))
+	}
+	return failures
+}
+
+func operationKeys(doc *apispec.Document) []string {
+	var keys []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			keys = append(keys, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type checker struct {
+	doc     *apispec.Document
+	baseURL string
+	client  *http.Client
+	token   string
+
+	// ids holds an id seen in each list response, keyed by path, to fill
+	// in path parameters of the endpoints below it
+	ids map[string]string
+}
+
+func (c *checker) run(email, password string) int {
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	status, data, err := c.call("POST", "/auth/login", body)
+	if err != nil {
+		fmt.Println("FAIL login:", err)
+		return 1
+	}
+	if status != http.StatusOK {
+		fmt.Printf("FAIL login: status %d: %s\n", status, data)
+		return 1
+	}
+
+	var login struct {
+		Token string `json:"token"`
+		User  struct {
+			ID json.Number `json:"id"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(data, &login); err != nil {
+		fmt.Println("FAIL login:", err)
+		return 1
+	}
+	c.token = login.Token
+	c.ids["/users"] = login.User.ID.String()
+
+	// Lists first so their ids are known when the detail endpoints run
+	var lists, details []string
+	for _, key := range operationKeys(c.doc) {
+		if !strings.HasPrefix(key, "GET ") {
+			continue
+		}
+		if strings.Contains(key, "{") {
+			details = append(details, key)
+		} else {
+			lists = append(lists, key)
+		}
+	}
+
+	failures := 0
+	for _, key := range append(lists, details...) {
+		path := strings.TrimPrefix(key, "GET ")
+		url, ok := c.fill(path)
+		if !ok {
+			fmt.Printf("skip GET %s: no id to fill in\n", path)
+			continue
+		}
+		status, err := c.check(path, url)
+		switch {
+		case err != nil:
+			fmt.Printf("FAIL GET %s: %v\n", url, err)
+			failures++
+		case status == http.StatusForbidden:
+			fmt.Printf("skip GET %s: forbidden for this account\n", url)
+		default:
+			fmt.Printf("ok   GET %s\n", url)
+		}
+	}
+	return failures
+}
+
+// check calls one endpoint and validates the response. Error statuses
+// other than 403 fail the check even when their body matches.
+func (c *checker) check(path, url string) (int, error) {
+	req, err := http.NewRequest("GET", c.baseURL+url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.doc.CheckResponse("GET", path, resp.StatusCode, resp.Header.Get("Content-Type"), data); err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusForbidden {
+		return resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+
+	if !strings.Contains(path, "{") {
+		if id := firstID(data); id != "" {
+			c.ids[path] = id
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (c *checker) call(method, path string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := c.doc.CheckResponse(method, path, resp.StatusCode, resp.Header.Get("Content-Type"), data); err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// fill replaces the {id} in a path such as /rides/{id}/messages with an id
+// from the matching list, trying the admin list when there is no public
+// one and the other way round. Paths with other parameters are skipped.
+func (c *checker) fill(path string) (string, bool) {
+	start := strings.Index(path, "{")
+	if start < 0 {
+		return path, true
+	}
+	end := strings.Index(path, "}")
+	if path[start+1:end] != "id" || strings.Contains(path[end:], "{") {
+		return "", false
+	}
+
+	list := strings.TrimSuffix(path[:start], "/")
+	for _, key := range []string{list, "/admin" + list, strings.TrimPrefix(list, "/admin")} {
+		if id, ok := c.ids[key]; ok {
+			return path[:start] + id + path[end+1:], true
+		}
+	}
+	return "", false
+}
+
+// firstID returns the id of the first object in a list response, or in the
+// first list inside an object such as a page of results
+func firstID(data []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if dec.Decode(&v) != nil {
+		return ""
+	}
+
+	if obj, ok := v.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if list, ok := obj[k].([]interface{}); ok {
+				v = list
+				break
+			}
+		}
+	}
+
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return ""
+	}
+	item, ok := list[0].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if id, ok := item["id"].(json.Number); ok {
+		return id.String()
+	}
+	return ""
+}
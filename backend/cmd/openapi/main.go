@@ -0,0 +1,300 @@
+// Command openapi writes the typed API client the frontend uses, generated
+// from the OpenAPI document the server serves at /api/openapi.json.
+//
+//	go run ./cmd/openapi                 # rewrite ../frontend/lib/api.gen.ts
+//	go run ./cmd/openapi -check          # fail if it is out of date
+//	go run ./cmd/openapi -spec spec.json # also write the document
+//
+// Run it after changing a route, request or response type.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"cpool.ai/backend/internal/apispec"
+	"cpool.ai/backend/internal/handlers"
+)
+
+func main() {
+	out := flag.String("out", "../frontend/lib/api.gen.ts", "TypeScript client to write")
+	specOut := flag.String("spec", "", "also write the OpenAPI document to this file")
+	check := flag.Bool("check", false, "report whether -out is up to date instead of writing it")
+	flag.Parse()
+
+	doc := handlers.Spec()
+	client := generate(doc)
+
+	if *check {
+		current, err := os.ReadFile(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !bytes.Equal(current, client) {
+			log.Fatalf("%s is out of date; run go run ./cmd/openapi", *out)
+		}
+		fmt.Printf("%s is up to date\n", *out)
+		return
+	}
+
+	if err := os.WriteFile(*out, client, 0644); err != nil {
+		log.Fatal(err)
+	}
+	if *specOut != "" {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*specOut, append(data, '\n'), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// methodOrder sorts operations on the same path
+var methodOrder = map[string]int{"get": 0, "post": 1, "put": 2, "patch": 3, "delete": 4}
+
+type operation struct {
+	method string
+	path   string
+	*apispec.Operation
+}
+
+func generate(doc *apispec.Document) []byte {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go run ./cmd/openapi. DO NOT EDIT.\n")
+	b.WriteString("// Types and functions for every operation in /api/openapi.json.\n\n")
+	b.WriteString("import type { AxiosInstance } from 'axios'\n")
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "ErrorResponse" {
+			b.WriteString("\n// ErrorResponse is the body of every 4xx and 5xx response\n")
+		} else {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "export interface %s %s\n", name, objectType(doc.Components.Schemas[name], ""))
+	}
+
+	var ops []operation
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			ops = append(ops, operation{method: method, path: path, Operation: op})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].path != ops[j].path {
+			return ops[i].path < ops[j].path
+		}
+		return methodOrder[ops[i].method] < methodOrder[ops[j].method]
+	})
+
+	for _, op := range ops {
+		if params := queryParams(op); len(params) > 0 {
+			fmt.Fprintf(&b, "\nexport interface %sParams {\n", exported(op.OperationID))
+			for _, p := range params {
+				if p.Description != "" {
+					fmt.Fprintf(&b, "  /** %s */\n", p.Description)
+				}
+				fmt.Fprintf(&b, "  %s%s: %s\n", p.Name, optional(p.Required), tsType(p.Schema, "  "))
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	b.WriteString("\n// The response interceptor on the axios instance passed in must unwrap\n")
+	b.WriteString("// response.data, as apiClient in api.ts does\n")
+	b.WriteString("const unwrap = <T>(response: Promise<unknown>) => response as Promise<T>\n\n")
+	b.WriteString("export function createClient(http: AxiosInstance) {\n")
+	b.WriteString("  return {\n")
+	for i, op := range ops {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeOperation(&b, op)
+	}
+	b.WriteString("  }\n")
+	b.WriteString("}\n\n")
+	b.WriteString("export type Client = ReturnType<typeof createClient>\n")
+	return b.Bytes()
+}
+
+func writeOperation(b *bytes.Buffer, op operation) {
+	summary := op.Summary
+	if op.Admin {
+		summary += " (admin only)"
+	}
+	fmt.Fprintf(b, "    /** %s */\n", summary)
+
+	var args []string
+	path := op.path
+	for _, p := range op.Parameters {
+		if p.In != "path" {
+			continue
+		}
+		args = append(args, p.Name+": "+tsType(p.Schema, ""))
+		value := "${" + p.Name + "}"
+		if p.Schema.Type == "string" {
+			value = "${encodeURIComponent(" + p.Name + ")}"
+		}
+		path = strings.Replace(path, "{"+p.Name+"}", value, 1)
+	}
+
+	// data is the request body; config carries query params and options
+	data := ""
+	var config []string
+	if body := op.RequestBody; body != nil {
+		if media := body.Content["application/json"]; media != nil {
+			args = append(args, "body"+optional(body.Required)+": "+tsType(media.Schema, "    "))
+		} else {
+			args = append(args, "form: FormData")
+		}
+		data = "body"
+		if body.Content["application/json"] == nil {
+			data = "form"
+		}
+	}
+	if len(queryParams(op)) > 0 {
+		args = append(args, "params?: "+exported(op.OperationID)+"Params")
+		config = append(config, "params")
+	}
+
+	result := "void"
+	success := successResponse(op.Operation)
+	if success != nil {
+		for contentType, media := range success.Content {
+			if contentType == "application/json" {
+				result = tsType(media.Schema, "    ")
+			} else {
+				result = "Blob"
+				config = append(config, "responseType: 'blob'")
+			}
+		}
+	}
+
+	call := "http." + op.method + "(`" + path + "`"
+	switch {
+	case op.method == "delete" && data != "":
+		config = append(config, "data: "+data)
+	case op.method == "post" || op.method == "put" || op.method == "patch":
+		if data == "" {
+			data = "undefined"
+		}
+		call += ", " + data
+	}
+	if len(config) > 0 {
+		call += ", { " + strings.Join(config, ", ") + " }"
+	}
+	call += ")"
+
+	fmt.Fprintf(b, "    %s: (%s) =>\n", op.OperationID, strings.Join(args, ", "))
+	fmt.Fprintf(b, "      unwrap<%s>(%s),\n", result, call)
+}
+
+func queryParams(op operation) []*apispec.Parameter {
+	var params []*apispec.Parameter
+	for _, p := range op.Parameters {
+		if p.In == "query" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// successResponse returns the operation's 2xx response
+func successResponse(op *apispec.Operation) *apispec.Response {
+	for status, resp := range op.Responses {
+		if strings.HasPrefix(status, "2") {
+			return resp
+		}
+	}
+	return nil
+}
+
+// tsType renders a schema as a TypeScript type; indent is the indentation
+// of the line the type starts on
+func tsType(s *apispec.Schema, indent string) string {
+	t := baseType(s, indent)
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
+
+func baseType(s *apispec.Schema, indent string) string {
+	switch {
+	case s.Ref != "":
+		return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	case len(s.AllOf) == 1:
+		return baseType(s.AllOf[0], indent)
+	case len(s.Enum) > 0:
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			values[i] = "'" + v + "'"
+		}
+		return strings.Join(values, " | ")
+	}
+
+	switch s.Type {
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "string":
+		return "string"
+	case "array":
+		item := tsType(s.Items, indent)
+		if strings.Contains(item, " ") {
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	case "object":
+		if extra, ok := s.AdditionalProperties.(*apispec.Schema); ok && len(s.Properties) == 0 {
+			return "Record<string, " + tsType(extra, indent) + ">"
+		}
+		return objectType(s, indent)
+	}
+	return "unknown"
+}
+
+func objectType(s *apispec.Schema, indent string) string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s%s: %s\n", indent, name, optional(required[name]), tsType(s.Properties[name], indent+"  "))
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+func optional(required bool) string {
+	if required {
+		return ""
+	}
+	return "?"
+}
+
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
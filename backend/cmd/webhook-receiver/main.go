@@ -0,0 +1,61 @@
+// Command webhook-receiver is a local endpoint for trying out webhooks. It
+// verifies each request's signature and prints the event.
+//
+//	go run ./cmd/webhook-receiver -addr :9000 -secret whsec_...
+//
+// Register http://localhost:9000/ as an endpoint, then send it a test ping
+// from POST /api/admin/webhooks/:id/test. -fail makes it answer 500 so
+// retries and auto-disable can be observed.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"cpool.ai/backend/internal/webhooks"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	secret := flag.String("secret", "", "endpoint signing secret; signatures are not checked without it")
+	fail := flag.Bool("fail", false, "respond 500 to every request")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if *secret != "" {
+			err := webhooks.Verify(*secret, r.Header.Get(webhooks.HeaderSignature), body, 5*time.Minute)
+			if err != nil {
+				log.Printf("Rejected delivery %s: %v", r.Header.Get(webhooks.HeaderDelivery), err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Write(body)
+		}
+		log.Printf("Delivery %s (%s):\n%s",
+			r.Header.Get(webhooks.HeaderDelivery), r.Header.Get(webhooks.HeaderEvent), pretty.String())
+
+		if *fail {
+			http.Error(w, "failing on purpose", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Listening for webhooks on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,183 @@
+// Package apierr defines the errors handlers return. Each carries the HTTP
+// status, a stable machine-readable code and a message for people;
+// middleware.ErrorMiddleware writes it as the response body.
+package apierr
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Generic codes, one per status
+const (
+	CodeBadRequest      = "bad_request"
+	CodeInvalidJSON     = "invalid_json"
+	CodeValidation      = "validation_failed"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodePayloadTooLarge = "payload_too_large"
+	CodeRateLimited     = "rate_limited"
+	CodeInternal        = "internal_error"
+	CodeUnavailable     = "service_unavailable"
+)
+
+// Specific codes clients are expected to branch on
+const (
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeEmailTaken         = "email_taken"
+	CodeVehicleNumberTaken = "vehicle_number_taken"
+	CodeVehicleUnverified  = "vehicle_unverified"
+	CodeVehicleInUse       = "vehicle_in_use"
+	CodeSeatsBooked        = "seats_booked"
+	CodeInsufficientSeats  = "insufficient_seats"
+	CodeDuplicateRequest   = "duplicate_request"
+	CodeDuplicateReview    = "duplicate_review"
+	CodeDuplicateReport    = "duplicate_report"
+	CodeFeatureExists      = "feature_exists"
+	CodeRideClosed         = "ride_closed"
+	CodeRideNotUpcoming    = "ride_not_upcoming"
+	CodeDriverIsRider      = "driver_is_rider"
+	CodeWomenOnly          = "women_only"
+	CodePushUnavailable    = "push_unavailable"
+	CodeAccountLocked      = "account_locked"
+)
+
+// Codes lists every code in the order they are documented
+var Codes = []string{
+	CodeBadRequest, CodeInvalidJSON, CodeValidation, CodeUnauthorized, CodeForbidden,
+	CodeNotFound, CodeConflict, CodePayloadTooLarge, CodeRateLimited, CodeInternal, CodeUnavailable,
+	CodeInvalidCredentials, CodeEmailTaken, CodeVehicleNumberTaken, CodeVehicleUnverified,
+	CodeVehicleInUse, CodeSeatsBooked, CodeInsufficientSeats, CodeDuplicateRequest,
+	CodeDuplicateReview, CodeDuplicateReport, CodeFeatureExists, CodeRideClosed,
+	CodeRideNotUpcoming, CodeDriverIsRider, CodeWomenOnly, CodePushUnavailable,
+	CodeAccountLocked,
+}
+
+// FieldError describes one invalid field of a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is an error with everything needed to answer the request. Cause is
+// logged for 5xx errors and never sent to the client. RetryAfter is sent as
+// the Retry-After header when set.
+type Error struct {
+	Status     int
+	Code       string
+	Message    string
+	Details    []FieldError
+	Extra      map[string]interface{}
+	RetryAfter time.Duration
+	Cause      error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// With adds a field to the response body alongside the error, such as the
+// booked seats that blocked a change
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Extra == nil {
+		e.Extra = map[string]interface{}{}
+	}
+	e.Extra[key] = value
+	return e
+}
+
+// Body returns the JSON response body. "error" stays the message so clients
+// that only read it keep working.
+func (e *Error) Body(requestID string) map[string]interface{} {
+	body := map[string]interface{}{
+		"error": e.Message,
+		"code":  e.Code,
+	}
+	for k, v := range e.Extra {
+		body[k] = v
+	}
+	if len(e.Details) > 0 {
+		body["details"] = e.Details
+	}
+	if e.RetryAfter > 0 {
+		body["retry_after"] = e.RetryAfterSeconds()
+	}
+	if requestID != "" {
+		body["request_id"] = requestID
+	}
+	return body
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds, as Retry-After
+// requires
+func (e *Error) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// New returns an error with a specific code
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// BadRequest is a 400 for a malformed parameter or a rule the request breaks
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// Unauthorized is a 401
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// Forbidden is a 403
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+// NotFound is a 404
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+// Conflict is a 409. Conflicts always get a specific code so clients can
+// tell them apart.
+func Conflict(code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+// TooManyRequests is a 429 telling the client to wait retryAfter before
+// trying again
+func TooManyRequests(code, message string, retryAfter time.Duration) *Error {
+	e := New(http.StatusTooManyRequests, code, message)
+	e.RetryAfter = retryAfter
+	return e
+}
+
+// Internal is a 500 whose message is safe to show; cause is only logged
+func Internal(message string, cause error) *Error {
+	e := New(http.StatusInternalServerError, CodeInternal, message)
+	e.Cause = cause
+	return e
+}
+
+// From converts any error to an *Error. Errors that aren't one become an
+// internal error that hides the original.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal("Internal server error", err)
+}
@@ -0,0 +1,124 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report fields by their JSON names rather than Go field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// Validation converts the error from binding a request body. Failed binding
+// rules become a 400 with one detail per field; a body that isn't valid JSON
+// or has a value of the wrong type is reported as invalid_json.
+func Validation(err error) *Error {
+	var fields validator.ValidationErrors
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &fields):
+		e := New(http.StatusBadRequest, CodeValidation, "")
+		messages := make([]string, len(fields))
+		for i, fe := range fields {
+			detail := FieldError{Field: fieldPath(fe), Rule: fe.Tag(), Message: ruleMessage(fe)}
+			e.Details = append(e.Details, detail)
+			messages[i] = detail.Field + " " + detail.Message
+		}
+		e.Message = strings.Join(messages, "; ")
+		return e
+	case errors.As(err, &typeErr):
+		e := New(http.StatusBadRequest, CodeInvalidJSON, fmt.Sprintf("%s must be a %s", typeErr.Field, jsonTypeName(typeErr.Type)))
+		e.Details = []FieldError{{Field: typeErr.Field, Rule: "type", Message: "must be a " + jsonTypeName(typeErr.Type)}}
+		return e
+	case errors.As(err, &syntax), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return New(http.StatusBadRequest, CodeInvalidJSON, "Request body must be valid JSON")
+	}
+	return BadRequest(err.Error())
+}
+
+// fieldPath is the field's JSON path without the request type, such as
+// preferences[0].channel
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	param := fe.Param()
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "uppercase":
+		return "must be uppercase"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "min", "gte":
+		switch fe.Kind() {
+		case reflect.String:
+			return "must be at least " + param + " characters"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return "must have at least " + param + " items"
+		}
+		return "must be at least " + param
+	case "max", "lte":
+		switch fe.Kind() {
+		case reflect.String:
+			return "must be at most " + param + " characters"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return "must have at most " + param + " items"
+		}
+		return "must be at most " + param
+	case "len":
+		if fe.Kind() == reflect.String {
+			return "must be exactly " + param + " characters"
+		}
+		return "must have exactly " + param + " items"
+	}
+	return "is invalid"
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	}
+	return "object"
+}
@@ -0,0 +1,324 @@
+// Package apispec builds the API's OpenAPI 3 document from a table of
+// endpoints and the Go types handlers bind and return, and checks actual
+// responses against it.
+package apispec
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cpool.ai/backend/internal/apierr"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	basePath string
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL the paths are relative to
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem holds the operations on one path, keyed by lower-case method
+type PathItem map[string]*Operation
+
+// Operation is one method on a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+
+	// Admin marks operations restricted to administrators
+	Admin bool `json:"x-admin,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes what an operation accepts
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes one status an operation can answer with
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema for one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the named schemas operations refer to
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes how requests authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Auth is who may call an endpoint
+type Auth int
+
+const (
+	Public Auth = iota
+	User
+	Admin
+)
+
+// Param is a query parameter or multipart form field
+type Param struct {
+	Name        string
+	Type        string // integer, number, boolean, string or file; string if empty
+	Format      string
+	Enum        []string
+	Required    bool
+	Description string
+}
+
+// Endpoint is one route as registered with gin, with the Go types it binds
+// and returns
+type Endpoint struct {
+	Method  string
+	Path    string // gin path relative to the base path, e.g. /rides/:id
+	ID      string // operationId, also the generated client's function name
+	Summary string
+	Tag     string
+	Auth    Auth
+	Query   []Param
+
+	// Body is a value of the JSON request body type. OptionalBody allows the
+	// request to be sent without one.
+	Body         interface{}
+	OptionalBody bool
+
+	// Form lists multipart/form-data fields for uploads
+	Form []Param
+
+	// Status is the success status, 200 if zero. Response is a value of the
+	// type written with it, or nil for a response without a body. Produces
+	// overrides application/json for file and export downloads.
+	Status   int
+	Response interface{}
+	Produces string
+}
+
+const (
+	jsonType   = "application/json"
+	bearerAuth = "bearerAuth"
+)
+
+// ErrorResponse is the body of every 4xx and 5xx response. Error is the
+// message to show, Code is stable for clients to branch on and Details
+// lists invalid fields of a request body. Seat conflicts also report what
+// blocked the change, and throttled requests the seconds to wait.
+type ErrorResponse struct {
+	Error         string              `json:"error"`
+	Code          string              `json:"code"`
+	Details       []apierr.FieldError `json:"details,omitempty"`
+	RequestID     string              `json:"request_id,omitempty"`
+	BookedSeats   int                 `json:"booked_seats,omitempty"`
+	UpcomingRides int                 `json:"upcoming_rides,omitempty"`
+	RetryAfter    int                 `json:"retry_after,omitempty"`
+}
+
+// Build generates the document for endpoints mounted under basePath
+func Build(info Info, basePath string, endpoints []Endpoint) *Document {
+	s := newSchemas()
+
+	// Responses are registered first so request types that share a Go type
+	// with a response are the ones renamed
+	errorSchema := s.response(ErrorResponse{})
+	s.components["ErrorResponse"].Properties["code"].Enum = apierr.Codes
+	responses := make([]*Schema, len(endpoints))
+	for i, e := range endpoints {
+		if e.Response != nil && e.Produces == "" {
+			responses[i] = s.response(e.Response)
+		}
+	}
+
+	doc := &Document{
+		OpenAPI:  "3.0.3",
+		Info:     info,
+		Servers:  []Server{{URL: basePath}},
+		Paths:    map[string]PathItem{},
+		basePath: basePath,
+		Components: Components{
+			Schemas: s.components,
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	tags := map[string]bool{}
+	for i, e := range endpoints {
+		op := &Operation{
+			OperationID: e.ID,
+			Summary:     e.Summary,
+			Responses:   map[string]*Response{},
+			Admin:       e.Auth == Admin,
+		}
+		if e.Tag != "" {
+			op.Tags = []string{e.Tag}
+			if !tags[e.Tag] {
+				tags[e.Tag] = true
+				doc.Tags = append(doc.Tags, Tag{Name: e.Tag})
+			}
+		}
+		if e.Auth != Public {
+			op.Security = []map[string][]string{{bearerAuth: {}}}
+		}
+
+		for _, name := range pathParams(e.Path) {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name: name, In: "path", Required: true, Schema: pathParamSchema(name),
+			})
+		}
+		for _, p := range e.Query {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name: p.Name, In: "query", Required: p.Required, Description: p.Description, Schema: p.schema(),
+			})
+		}
+
+		switch {
+		case e.Body != nil:
+			op.RequestBody = &RequestBody{
+				Required: !e.OptionalBody,
+				Content:  map[string]*MediaType{jsonType: {Schema: s.request(e.Body)}},
+			}
+		case len(e.Form) > 0:
+			form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			for _, p := range e.Form {
+				form.Properties[p.Name] = p.schema()
+				if p.Required {
+					form.Required = append(form.Required, p.Name)
+				}
+			}
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]*MediaType{"multipart/form-data": {Schema: form}},
+			}
+		}
+
+		status := e.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &Response{Description: http.StatusText(status)}
+		switch {
+		case e.Produces != "":
+			success.Content = map[string]*MediaType{e.Produces: {Schema: &Schema{Type: "string", Format: "binary"}}}
+		case responses[i] != nil:
+			success.Content = map[string]*MediaType{jsonType: {Schema: responses[i]}}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+		op.Responses["default"] = &Response{
+			Description: "Error",
+			Content:     map[string]*MediaType{jsonType: {Schema: errorSchema}},
+		}
+
+		path := OpenAPIPath(e.Path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(e.Method)] = op
+	}
+
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc
+}
+
+// Operation returns the operation for a method and gin route path, which
+// may include the base path
+func (d *Document) Operation(method, ginPath string) *Operation {
+	item := d.Paths[OpenAPIPath(strings.TrimPrefix(ginPath, d.basePath))]
+	return item[strings.ToLower(method)]
+}
+
+// BasePath returns the path endpoints are mounted under
+func (d *Document) BasePath() string {
+	return d.basePath
+}
+
+// OpenAPIPath converts a gin path such as /rides/:id to /rides/{id}
+func OpenAPIPath(ginPath string) string {
+	parts := strings.Split(ginPath, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func pathParams(ginPath string) []string {
+	var names []string
+	for _, part := range strings.Split(ginPath, "/") {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			names = append(names, part[1:])
+		}
+	}
+	return names
+}
+
+// pathParamSchema treats id, userId and the like as integers and anything
+// else (feature and job names, share tokens) as strings
+func pathParamSchema(name string) *Schema {
+	if name == "id" || strings.HasSuffix(name, "Id") {
+		return &Schema{Type: "integer"}
+	}
+	return &Schema{Type: "string"}
+}
+
+func (p Param) schema() *Schema {
+	switch p.Type {
+	case "file":
+		return &Schema{Type: "string", Format: "binary"}
+	case "":
+		return &Schema{Type: "string", Format: p.Format, Enum: p.Enum}
+	}
+	return &Schema{Type: p.Type, Format: p.Format, Enum: p.Enum}
+}
@@ -0,0 +1,221 @@
+package apispec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is the subset of the OpenAPI 3.0 schema object the API uses
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas builds component schemas from Go types. Response types are
+// closed (additionalProperties: false) and require every field not marked
+// omitempty, so a handler adding or dropping a field shows up as drift.
+// Request types follow their binding tags instead.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+	inputs     map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+		inputs:     map[reflect.Type]string{},
+	}
+}
+
+// response returns the schema for a value returned by a handler
+func (s *schemas) response(v interface{}) *Schema {
+	return s.of(reflect.TypeOf(v), false)
+}
+
+// request returns the schema for a request body a handler binds
+func (s *schemas) request(v interface{}) *Schema {
+	return s.of(reflect.TypeOf(v), true)
+}
+
+func (s *schemas) of(t reflect.Type, input bool) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.of(t.Elem(), input)
+		if schema.Ref != "" {
+			return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.of(t.Elem(), input)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem(), input)}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t, input)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.component(t, input)}
+	}
+	panic(fmt.Sprintf("apispec: unsupported type %s", t))
+}
+
+// component registers a named struct under components/schemas. Types used
+// both as requests and responses get a separate "Input" schema for the
+// request side since the required fields differ.
+func (s *schemas) component(t reflect.Type, input bool) string {
+	registry := s.names
+	if input {
+		registry = s.inputs
+	}
+	if name, ok := registry[t]; ok {
+		return name
+	}
+
+	name := exported(t.Name())
+	if input {
+		if _, ok := s.components[name]; ok {
+			name += "Input"
+		}
+	} else if _, ok := s.components[name]; ok {
+		panic(fmt.Sprintf("apispec: schema name %s is used by more than one type", name))
+	}
+	registry[t] = name
+	s.components[name] = nil
+	s.components[name] = s.object(t, input)
+	return name
+}
+
+func (s *schemas) object(t reflect.Type, input bool) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if !input {
+		schema.AdditionalProperties = false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := s.of(field.Type, input)
+		required := !strings.Contains(","+opts+",", ",omitempty,")
+		if input {
+			required = applyBinding(prop, field.Tag.Get("binding"))
+		}
+		schema.Properties[name] = prop
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// applyBinding copies gin binding rules onto a property schema and reports
+// whether the field is required
+func applyBinding(prop *Schema, binding string) bool {
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			prop.Format = "email"
+		case "url":
+			prop.Format = "uri"
+		case "uppercase":
+			prop.Pattern = "^[^a-z]*$"
+		case "oneof":
+			prop.Enum = strings.Fields(value)
+		case "len":
+			n, _ := strconv.Atoi(value)
+			prop.MinLength, prop.MaxLength = &n, &n
+		case "min", "max":
+			n, _ := strconv.ParseFloat(value, 64)
+			setBound(prop, key, n)
+		}
+	}
+	return required
+}
+
+func setBound(prop *Schema, key string, n float64) {
+	switch prop.Type {
+	case "integer", "number":
+		if key == "min" {
+			prop.Minimum = &n
+		} else {
+			prop.Maximum = &n
+		}
+	case "string":
+		i := int(n)
+		if key == "min" {
+			prop.MinLength = &i
+		} else {
+			prop.MaxLength = &i
+		}
+	case "array":
+		if key == "min" {
+			i := int(n)
+			prop.MinItems = &i
+		}
+	}
+}
+
+func exported(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
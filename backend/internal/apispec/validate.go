@@ -0,0 +1,218 @@
+package apispec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxProblems caps how many mismatches are reported for one response
+const maxProblems = 20
+
+// DriftError lists where a response departs from the document
+type DriftError struct {
+	Method   string
+	Path     string
+	Status   int
+	Problems []string
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%s %s (%d) does not match the API spec: %s",
+		e.Method, e.Path, e.Status, strings.Join(e.Problems, "; "))
+}
+
+// CheckResponse validates a response against the operation for a method and
+// gin route path. Statuses the operation doesn't list are checked against
+// its error response if they are 4xx or 5xx, and reported otherwise.
+// It returns a *DriftError if the response doesn't match.
+func (d *Document) CheckResponse(method, ginPath string, status int, contentType string, body []byte) error {
+	op := d.Operation(method, ginPath)
+	if op == nil {
+		return fmt.Errorf("%s %s is not in the API spec", method, ginPath)
+	}
+
+	drift := &DriftError{Method: method, Path: ginPath, Status: status}
+	resp := op.Responses[strconv.Itoa(status)]
+	if resp == nil {
+		if status < 400 {
+			drift.Problems = append(drift.Problems, fmt.Sprintf("status %d is not documented", status))
+			return drift
+		}
+		resp = op.Responses["default"]
+	}
+
+	if len(resp.Content) == 0 {
+		if len(bytes.TrimSpace(body)) > 0 {
+			drift.Problems = append(drift.Problems, "response has a body but none is documented")
+		}
+	} else {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		media := resp.Content[mediaType]
+		switch {
+		case media == nil && !binaryContent(resp):
+			drift.Problems = append(drift.Problems, fmt.Sprintf("content type %q is not documented", contentType))
+		case mediaType == jsonType:
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				drift.Problems = append(drift.Problems, "invalid JSON: "+err.Error())
+			} else {
+				d.validate(media.Schema, v, "$", &drift.Problems)
+			}
+		}
+	}
+
+	if len(drift.Problems) > 0 {
+		return drift
+	}
+	return nil
+}
+
+// binaryContent reports whether a response is a file download, which may be
+// served with whatever type the file has
+func binaryContent(resp *Response) bool {
+	for _, media := range resp.Content {
+		if media.Schema != nil && media.Schema.Format == "binary" {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Document) validate(s *Schema, v interface{}, path string, problems *[]string) {
+	if len(*problems) >= maxProblems {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if v == nil {
+		if !s.Nullable && !s.isAny() {
+			fail("null is not allowed")
+		}
+		return
+	}
+	if s.Ref != "" {
+		target := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if target == nil {
+			fail("unknown schema %s", s.Ref)
+			return
+		}
+		d.validate(target, v, path, problems)
+		return
+	}
+	for _, sub := range s.AllOf {
+		d.validate(sub, v, path, problems)
+	}
+
+	switch s.Type {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			fail("expected object, got %s", jsonKind(v))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := m[name]; !ok {
+				fail("missing property %q", name)
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop := s.Properties[k]; prop != nil {
+				d.validate(prop, m[k], path+"."+k, problems)
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					fail("unexpected property %q", k)
+				}
+			case *Schema:
+				d.validate(extra, m[k], path+"."+k, problems)
+			}
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			fail("expected array, got %s", jsonKind(v))
+			return
+		}
+		for i, item := range items {
+			d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("expected string, got %s", jsonKind(v))
+			return
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				fail("%q is not a date-time", str)
+			}
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			fail("%q is not one of %s", str, strings.Join(s.Enum, ", "))
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			fail("expected integer, got %s", jsonKind(v))
+			return
+		}
+		if _, err := n.Int64(); err != nil {
+			fail("%s is not an integer", n)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			fail("expected number, got %s", jsonKind(v))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("expected boolean, got %s", jsonKind(v))
+		}
+	}
+}
+
+// isAny reports whether a schema accepts any value, as json.RawMessage does
+func (s *Schema) isAny() bool {
+	return s.Type == "" && s.Ref == "" && len(s.AllOf) == 0
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
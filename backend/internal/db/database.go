@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -23,29 +24,89 @@ func Initialize(databaseURL string) (*sql.DB, error) {
 	return db, nil
 }
 
-// RunMigrations runs database migrations
-func RunMigrations(db *sql.DB) error {
-	migrations := []string{
-		createUsersTable,
-		createCitiesTable,
-		createCorridorsTable,
-		createUserCorridorsTable,
-		createVehiclesTable,
-		createRidesTable,
-		createRideRequestsTable,
-		createMessagesTable,
-		createPaymentsTable,
-		createCarbonCreditsTable,
-		createFeatureFlagsTable,
-		insertInitialData,
+// migrations run in order on every start, so each must be idempotent.
+// Append new ones before insertInitialData.
+var migrations = []string{
+	createUsersTable,
+	createCitiesTable,
+	createCorridorsTable,
+	createUserCorridorsTable,
+	createVehiclesTable,
+	createRidesTable,
+	createRideRequestsTable,
+	createMessagesTable,
+	createPaymentsTable,
+	createCarbonCreditsTable,
+	createFeatureFlagsTable,
+	alterCitiesAddLaunchAt,
+	createCityWaitlistTable,
+	createNotificationsTable,
+	alterCitiesAddLocale,
+	alterRidesRideTimeType,
+	alterVehiclesAddVerification,
+	createVehicleDocumentsTable,
+	alterVehiclesAddRegistration,
+	alterVehiclesAddArchivedAt,
+	createAuditLogTable,
+	alterFeatureFlagsAddTargeting,
+	alterRidesAddCancellation,
+	createRideMetricsDailyTable,
+	alterUsersAddLastSeen,
+	createReviewsTable,
+	createUserBlocksTable,
+	createReportsTable,
+	createSafetyTables,
+	alterAddWomenOnlyRides,
+	createNotificationDeliveryTables,
+	createScheduledJobsTables,
+	createOutboxTables,
+	createWebhookTables,
+	createSchemaVersionTable,
+	createRateLimitTables,
+	insertInitialData,
+}
+
+// SchemaVersion is the number of migrations this build applies
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// CurrentVersion returns the schema version recorded in the database, 0 if
+// none has been
+func CurrentVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT version FROM schema_version`).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
 	}
+	return version, err
+}
 
+// RunMigrations runs database migrations
+func RunMigrations(db *sql.DB) error {
 	for _, migration := range migrations {
 		if _, err := db.Exec(migration); err != nil {
 			return fmt.Errorf("migration failed: %w", err)
 		}
 	}
 
+	if _, err := NormalizeVehicleNumbers(db); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	if _, err := NormalizeUserEmails(db); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	// A newer build may already have migrated further; never step back
+	if _, err := db.Exec(`
+		INSERT INTO schema_version (version) VALUES ($1)
+		ON CONFLICT (id) DO UPDATE SET
+			version = GREATEST(schema_version.version, EXCLUDED.version),
+			applied_at = CURRENT_TIMESTAMP`, SchemaVersion()); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
 	log.Println("Database migrations completed")
 	return nil
 }
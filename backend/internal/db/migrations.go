@@ -151,6 +151,505 @@ CREATE TABLE IF NOT EXISTS feature_flags (
 );
 `
 
+const alterCitiesAddLaunchAt = `
+ALTER TABLE cities ADD COLUMN IF NOT EXISTS launch_at TIMESTAMP;
+`
+
+const createCityWaitlistTable = `
+CREATE TABLE IF NOT EXISTS city_waitlist (
+    id SERIAL PRIMARY KEY,
+    city_id INTEGER REFERENCES cities(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    preferred_from VARCHAR(255) NOT NULL,
+    preferred_to VARCHAR(255) NOT NULL,
+    commute_start TIME,
+    commute_return TIME,
+    notified_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(city_id, user_id)
+);
+`
+
+const createNotificationsTable = `
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_at DESC);
+`
+
+const alterCitiesAddLocale = `
+ALTER TABLE cities ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Kolkata';
+ALTER TABLE cities ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'INR';
+ALTER TABLE cities ADD COLUMN IF NOT EXISTS locale VARCHAR(20) NOT NULL DEFAULT 'en-IN';
+`
+
+// ride_time used to be free text; convert it to TIME. If any value isn't a
+// time of day the migration fails, listing each ride, and changes nothing, so
+// the values can be corrected by hand before restarting.
+const alterRidesRideTimeType = `
+DO $$
+DECLARE
+    r RECORD;
+    bad TEXT[] := '{}';
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'rides' AND column_name = 'ride_time') = 'character varying' THEN
+        FOR r IN SELECT id, ride_time FROM rides ORDER BY id LOOP
+            IF r.ride_time !~ '^\s*[0-9]{1,2}:[0-9]{2}(:[0-9]{2})?\s*([AaPp][Mm])?\s*$' THEN
+                bad := bad || format('ride %s: %L', r.id, r.ride_time);
+                CONTINUE;
+            END IF;
+            BEGIN
+                PERFORM TRIM(r.ride_time)::time;
+            EXCEPTION WHEN invalid_datetime_format OR datetime_field_overflow THEN
+                bad := bad || format('ride %s: %L', r.id, r.ride_time);
+            END;
+        END LOOP;
+
+        IF cardinality(bad) > 0 THEN
+            RAISE EXCEPTION 'rides.ride_time has % value(s) that are not a time of day; correct them and restart: %',
+                cardinality(bad), array_to_string(bad, '; ');
+        END IF;
+
+        ALTER TABLE rides ALTER COLUMN ride_time TYPE TIME USING TRIM(ride_time)::time;
+    END IF;
+END $$;
+`
+
+const alterVehiclesAddVerification = `
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS verification_status VARCHAR(20) NOT NULL DEFAULT 'unverified'
+    CHECK (verification_status IN ('unverified', 'pending', 'verified', 'rejected', 'expired'));
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS verification_note TEXT;
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS verified_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+`
+
+const createVehicleDocumentsTable = `
+CREATE TABLE IF NOT EXISTS vehicle_documents (
+    id SERIAL PRIMARY KEY,
+    vehicle_id INTEGER REFERENCES vehicles(id) ON DELETE CASCADE,
+    doc_type VARCHAR(20) NOT NULL CHECK (doc_type IN ('rc', 'insurance', 'driving_licence')),
+    storage_key VARCHAR(255) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    expires_on DATE,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'superseded')),
+    review_note TEXT,
+    reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    reminder_sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicle_documents_vehicle ON vehicle_documents(vehicle_id, doc_type);
+`
+
+const alterVehiclesAddRegistration = `
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS state_code VARCHAR(2);
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS rto_code VARCHAR(4);
+`
+
+// Archived vehicles release their registration number so it can be registered again
+const alterVehiclesAddArchivedAt = `
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+ALTER TABLE vehicles DROP CONSTRAINT IF EXISTS vehicles_vehicle_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicles_number_active ON vehicles(vehicle_number) WHERE archived_at IS NULL;
+`
+
+// actor_id deliberately has no foreign key: rows must survive user deletion unchanged
+const createAuditLogTable = `
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    actor_email VARCHAR(255),
+    action VARCHAR(100) NOT NULL,
+    target_type VARCHAR(50) NOT NULL,
+    target_id VARCHAR(50) NOT NULL,
+    before JSONB,
+    after JSONB,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+`
+
+const alterFeatureFlagsAddTargeting = `
+ALTER TABLE feature_flags ADD COLUMN IF NOT EXISTS rollout_percentage INTEGER NOT NULL DEFAULT 100
+    CHECK (rollout_percentage BETWEEN 0 AND 100);
+ALTER TABLE feature_flags ADD COLUMN IF NOT EXISTS targeting JSONB NOT NULL DEFAULT '{}';
+
+CREATE OR REPLACE FUNCTION notify_feature_flag_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('feature_flags_changed', COALESCE(NEW.name, OLD.name));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS feature_flags_notify ON feature_flags;
+CREATE TRIGGER feature_flags_notify AFTER INSERT OR UPDATE OR DELETE ON feature_flags
+    FOR EACH ROW EXECUTE FUNCTION notify_feature_flag_change();
+`
+
+const alterRidesAddCancellation = `
+ALTER TABLE rides ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+ALTER TABLE rides ADD COLUMN IF NOT EXISTS cancelled_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE rides ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_rides_status_date ON rides(status, ride_date);
+`
+
+const createRideMetricsDailyTable = `
+CREATE TABLE IF NOT EXISTS ride_metrics_daily (
+    bucket_date DATE NOT NULL,
+    city_id INTEGER NOT NULL REFERENCES cities(id) ON DELETE CASCADE,
+    corridor_id INTEGER NOT NULL REFERENCES corridors(id) ON DELETE CASCADE,
+    rides_offered INTEGER NOT NULL DEFAULT 0,
+    rides_completed INTEGER NOT NULL DEFAULT 0,
+    rides_cancelled INTEGER NOT NULL DEFAULT 0,
+    seats_offered INTEGER NOT NULL DEFAULT 0,
+    seats_filled INTEGER NOT NULL DEFAULT 0,
+    requests_made INTEGER NOT NULL DEFAULT 0,
+    requests_accepted INTEGER NOT NULL DEFAULT 0,
+    requests_decided INTEGER NOT NULL DEFAULT 0,
+    response_seconds_total BIGINT NOT NULL DEFAULT 0,
+    revenue_settled DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    credits_issued INTEGER NOT NULL DEFAULT 0,
+    refreshed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (bucket_date, corridor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_metrics_daily_city ON ride_metrics_daily(city_id, bucket_date);
+`
+
+const alterUsersAddLastSeen = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_last_seen ON users(last_seen_at);
+`
+
+const createReviewsTable = `
+CREATE TABLE IF NOT EXISTS reviews (
+    id SERIAL PRIMARY KEY,
+    ride_id INTEGER NOT NULL REFERENCES rides(id) ON DELETE CASCADE,
+    reviewer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reviewee_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    comment TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'visible' CHECK (status IN ('visible', 'hidden')),
+    moderation_note TEXT,
+    moderated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    moderated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(ride_id, reviewer_id, reviewee_id),
+    CHECK (reviewer_id <> reviewee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviews_reviewee ON reviews(reviewee_id, created_at DESC);
+`
+
+const createUserBlocksTable = `
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked ON user_blocks(blocked_id);
+`
+
+const createReportsTable = `
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    reporter_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type VARCHAR(20) NOT NULL CHECK (target_type IN ('user', 'message', 'ride')),
+    target_id INTEGER NOT NULL,
+    reported_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    category VARCHAR(30) NOT NULL CHECK (category IN ('harassment', 'unsafe_driving', 'inappropriate_content', 'spam', 'fraud', 'no_show', 'other')),
+    description TEXT,
+    evidence TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'reviewing', 'actioned', 'dismissed')),
+    resolution_note TEXT,
+    resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_unique ON reports(reporter_id, target_type, target_id)
+    WHERE status IN ('open', 'reviewing');
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_reports_reported_user ON reports(reported_user_id);
+`
+
+const createSafetyTables = `
+CREATE TABLE IF NOT EXISTS emergency_contacts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    phone VARCHAR(20) NOT NULL,
+    email VARCHAR(255),
+    relationship VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_emergency_contacts_user ON emergency_contacts(user_id);
+
+CREATE TABLE IF NOT EXISTS sos_alerts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ride_id INTEGER REFERENCES rides(id) ON DELETE SET NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    accuracy_m DOUBLE PRECISION,
+    message TEXT,
+    contacts_notified INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    resolution_note TEXT,
+    resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sos_alerts_status ON sos_alerts(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sos_alerts_ride ON sos_alerts(ride_id);
+
+CREATE TABLE IF NOT EXISTS ride_shares (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    ride_id INTEGER NOT NULL REFERENCES rides(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ride_shares_ride ON ride_shares(ride_id);
+`
+
+const alterAddWomenOnlyRides = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS gender VARCHAR(20)
+    CHECK (gender IN ('female', 'male', 'non_binary', 'undisclosed'));
+ALTER TABLE corridors ADD COLUMN IF NOT EXISTS women_only_allowed BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE rides ADD COLUMN IF NOT EXISTS visibility VARCHAR(20) NOT NULL DEFAULT 'everyone'
+    CHECK (visibility IN ('everyone', 'women_only'));
+`
+
+const createNotificationDeliveryTables = `
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, kind, channel)
+);
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL UNIQUE,
+    p256dh VARCHAR(255) NOT NULL,
+    auth VARCHAR(255) NOT NULL,
+    user_agent VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user ON push_subscriptions(user_id);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id SERIAL PRIMARY KEY,
+    notification_id INTEGER REFERENCES notifications(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL,
+    address TEXT NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_due ON notification_deliveries(next_attempt_at)
+    WHERE status IN ('pending', 'sending');
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
+`
+
+const createScheduledJobsTables = `
+CREATE TABLE IF NOT EXISTS scheduled_jobs (
+    name VARCHAR(100) PRIMARY KEY,
+    schedule VARCHAR(100) NOT NULL,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 0,
+    locked_by VARCHAR(255),
+    locked_until TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    last_status VARCHAR(20),
+    last_error TEXT
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id SERIAL PRIMARY KEY,
+    job_name VARCHAR(100) NOT NULL,
+    attempt INTEGER NOT NULL,
+    runner VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed')),
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ,
+    duration_ms BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job_name, started_at DESC);
+
+ALTER TABLE rides ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMP;
+`
+
+const createOutboxTables = `
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    dispatched_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_undispatched ON outbox_events(id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS event_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    subscriber VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'done', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(event_id, subscriber)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_deliveries_due ON event_deliveries(next_attempt_at)
+    WHERE status IN ('pending', 'processing');
+
+-- Credits are issued once per user and ride
+CREATE UNIQUE INDEX IF NOT EXISTS idx_carbon_credits_ride_user ON carbon_credits(ride_id, user_id)
+    WHERE ride_id IS NOT NULL;
+`
+
+const createWebhookTables = `
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    description VARCHAR(255),
+    event_types TEXT[] NOT NULL,
+    secret VARCHAR(100) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    last_success_at TIMESTAMPTZ,
+    last_failure_at TIMESTAMPTZ,
+    disabled_at TIMESTAMPTZ,
+    disabled_reason VARCHAR(255),
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    endpoint_id INTEGER NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_id BIGINT REFERENCES outbox_events(id) ON DELETE SET NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(endpoint_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at)
+    WHERE status IN ('pending', 'sending');
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, id DESC);
+
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+    id SERIAL PRIMARY KEY,
+    delivery_id INTEGER NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    response_code INTEGER,
+    response_body TEXT,
+    error TEXT,
+    duration_ms BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_attempts_delivery ON webhook_attempts(delivery_id);
+`
+
+// schema_version holds a single row with the number of migrations applied,
+// which readiness checks compare against the binary's
+const createSchemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version INTEGER NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+const createRateLimitTables = `
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated ON rate_limit_buckets(updated_at);
+
+-- Failed logins per account, whether or not it exists, for lockout
+CREATE TABLE IF NOT EXISTS login_failures (
+    email VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMPTZ
+);
+`
+
 const insertInitialData = `
 -- Insert cities
 INSERT INTO cities (name, status) VALUES 
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// UserEmailIssue describes accounts whose emails are the same apart from case
+type UserEmailIssue struct {
+	UserIDs []int
+	Email   string
+}
+
+// NormalizeUserEmails lower-cases stored emails, which logins match
+// case-insensitively, and then makes them unique regardless of case.
+// Accounts that collide once lower-cased are left untouched and reported,
+// and uniqueness is only enforced once they have been merged or renamed.
+func NormalizeUserEmails(db *sql.DB) ([]UserEmailIssue, error) {
+	rows, err := db.Query(`SELECT id, email FROM users ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+
+	type user struct {
+		id    int
+		email string
+	}
+	groups := map[string][]user{}
+	var order []string
+
+	for rows.Next() {
+		var u user
+		if err := rows.Scan(&u.id, &u.email); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		email := strings.ToLower(strings.TrimSpace(u.email))
+		if _, ok := groups[email]; !ok {
+			order = append(order, email)
+		}
+		groups[email] = append(groups[email], u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var issues []UserEmailIssue
+	for _, email := range order {
+		group := groups[email]
+		if len(group) > 1 {
+			ids := make([]int, len(group))
+			for i, u := range group {
+				ids[i] = u.id
+			}
+			issues = append(issues, UserEmailIssue{UserIDs: ids, Email: email})
+			continue
+		}
+		if group[0].email == email {
+			continue
+		}
+		if _, err := db.Exec(`UPDATE users SET email = $1 WHERE id = $2`, email, group[0].id); err != nil {
+			return nil, fmt.Errorf("failed to normalise email of user %d: %w", group[0].id, err)
+		}
+	}
+
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			ids := make([]string, len(issue.UserIDs))
+			for i, id := range issue.UserIDs {
+				ids[i] = fmt.Sprint(id)
+			}
+			log.Printf("Email %q is used by users %s in different cases", issue.Email, strings.Join(ids, ", "))
+		}
+		log.Printf("Emails are not unique regardless of case until the %d duplicate(s) above are resolved", len(issues))
+
+		// Logins still need the lookup index
+		_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(LOWER(email))`)
+		return issues, err
+	}
+
+	_, err = db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower_unique ON users(LOWER(email));
+		DROP INDEX IF EXISTS idx_users_email_lower;`)
+	return nil, err
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"cpool.ai/backend/internal/regno"
+)
+
+// VehicleNumberIssue describes a stored registration number that could not be normalised
+type VehicleNumberIssue struct {
+	VehicleIDs []int
+	Number     string
+	Reason     string
+}
+
+// NormalizeVehicleNumbers rewrites stored registration numbers into canonical form
+// and fills in state and RTO codes. Vehicles whose numbers are invalid, or that
+// collide with another vehicle once normalised, are left untouched and reported.
+func NormalizeVehicleNumbers(db *sql.DB) ([]VehicleNumberIssue, error) {
+	rows, err := db.Query(`SELECT id, vehicle_number FROM vehicles WHERE archived_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vehicles: %w", err)
+	}
+
+	type vehicle struct {
+		id     int
+		number string
+		plate  regno.Plate
+	}
+	groups := map[string][]vehicle{}
+	var order []string
+	var issues []VehicleNumberIssue
+
+	for rows.Next() {
+		var v vehicle
+		if err := rows.Scan(&v.id, &v.number); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		plate, err := regno.Parse(v.number)
+		if err != nil {
+			issues = append(issues, VehicleNumberIssue{VehicleIDs: []int{v.id}, Number: v.number, Reason: err.Error()})
+			continue
+		}
+		v.plate = plate
+		if _, ok := groups[plate.Number]; !ok {
+			order = append(order, plate.Number)
+		}
+		groups[plate.Number] = append(groups[plate.Number], v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, number := range order {
+		group := groups[number]
+		if len(group) > 1 {
+			ids := make([]int, len(group))
+			for i, v := range group {
+				ids[i] = v.id
+			}
+			issues = append(issues, VehicleNumberIssue{VehicleIDs: ids, Number: number, Reason: "duplicate registration number"})
+			continue
+		}
+
+		v := group[0]
+		var stateCode, rtoCode *string
+		if !v.plate.BHSeries {
+			stateCode, rtoCode = &v.plate.StateCode, &v.plate.RTOCode
+		}
+		_, err := db.Exec(
+			`UPDATE vehicles SET vehicle_number = $1, state_code = $2, rto_code = $3
+			 WHERE id = $4 AND (vehicle_number != $1 OR state_code IS DISTINCT FROM $2 OR rto_code IS DISTINCT FROM $3)`,
+			v.plate.Number, stateCode, rtoCode, v.id,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to normalise vehicle %d: %w", v.id, err)
+		}
+	}
+
+	for _, issue := range issues {
+		ids := make([]string, len(issue.VehicleIDs))
+		for i, id := range issue.VehicleIDs {
+			ids[i] = fmt.Sprint(id)
+		}
+		log.Printf("Vehicle number %q (vehicles %s): %s", issue.Number, strings.Join(ids, ", "), issue.Reason)
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,466 @@
+// Package events records domain events in a transactional outbox and
+// dispatches them to in-process subscribers with at-least-once delivery.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Event types
+const (
+	RideCreated     = "ride.created"
+	RideCancelled   = "ride.cancelled"
+	RideCompleted   = "ride.completed"
+	RideDeparting   = "ride.departing"
+	RequestCreated  = "ride_request.created"
+	RequestAccepted = "ride_request.accepted"
+	RequestRejected = "ride_request.rejected"
+	PaymentMarked   = "payment.marked"
+	MessageSent     = "message.sent"
+	CreditsAwarded  = "credits.awarded"
+	CityLaunched    = "city.launched"
+)
+
+// Types lists every event type in the order they are documented
+var Types = []string{
+	RideCreated, RideCancelled, RideCompleted, RideDeparting,
+	RequestCreated, RequestAccepted, RequestRejected,
+	PaymentMarked, MessageSent, CreditsAwarded,
+	CityLaunched,
+}
+
+// NotifyChannel is the Postgres NOTIFY channel raised when events are committed
+const NotifyChannel = "outbox_events"
+
+const (
+	// maxAttempts is how many times a subscriber is given an event before it is marked failed
+	maxAttempts  = 8
+	baseBackoff  = 10 * time.Second
+	maxBackoff   = time.Hour
+	claimTimeout = 10 * time.Minute
+	batchSize    = 100
+	// handlerTimeout bounds a single subscriber call
+	handlerTimeout = time.Minute
+	// retention is how long processed events are kept
+	retention = 14 * 24 * time.Hour
+)
+
+// Event is a recorded domain event
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Decode unmarshals the event payload into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// RidePayload describes a ride event
+type RidePayload struct {
+	RideID   int    `json:"ride_id"`
+	DriverID int    `json:"driver_id"`
+	RideDate string `json:"ride_date"`
+	RiderIDs []int  `json:"rider_ids,omitempty"`
+	// CancelledBy is "driver", "admin" or "system" for cancellations
+	CancelledBy string `json:"cancelled_by,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// RequestPayload describes a ride request event
+type RequestPayload struct {
+	RequestID int `json:"request_id"`
+	RideID    int `json:"ride_id"`
+	RiderID   int `json:"rider_id"`
+	DriverID  int `json:"driver_id"`
+	Seats     int `json:"seats"`
+	// Reason is ReasonExpired when a request is rejected because its ride
+	// departed before the driver answered
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReasonExpired marks requests rejected for going unanswered
+const ReasonExpired = "expired"
+
+// PaymentPayload describes a payment being marked as sent or received
+type PaymentPayload struct {
+	RideID  int `json:"ride_id"`
+	RiderID int `json:"rider_id"`
+	GiverID int `json:"giver_id"`
+	ActorID int `json:"actor_id"`
+	// Status is "sent" when the rider paid and "received" when the driver confirmed
+	Status string `json:"status"`
+	Admin  bool   `json:"admin"`
+}
+
+// MessagePayload describes a chat message
+type MessagePayload struct {
+	MessageID int `json:"message_id"`
+	RideID    int `json:"ride_id"`
+	SenderID  int `json:"sender_id"`
+}
+
+// CreditsPayload describes carbon credits issued for a ride
+type CreditsPayload struct {
+	RideID int           `json:"ride_id"`
+	Awards []CreditAward `json:"awards"`
+}
+
+// CityPayload describes a city opening for rides
+type CityPayload struct {
+	CityID int    `json:"city_id"`
+	Name   string `json:"name"`
+	// UserIDs are the waitlisted users told about the launch
+	UserIDs []int `json:"user_ids,omitempty"`
+}
+
+// CreditAward is the credits one user earned
+type CreditAward struct {
+	UserID  int    `json:"user_id"`
+	Credits int    `json:"credits"`
+	Reason  string `json:"reason"`
+}
+
+// Publish records an event as part of tx so it is dispatched only if tx commits
+func Publish(tx *sql.Tx, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO outbox_events (type, payload) VALUES ($1, $2)`, eventType, string(data)); err != nil {
+		return err
+	}
+	// Delivered on commit, waking dispatchers on every replica
+	_, err = tx.Exec(`SELECT pg_notify($1, '')`, NotifyChannel)
+	return err
+}
+
+// Handler processes an event. Returning an error retries it with backoff, so
+// handlers must be safe to run more than once for the same event.
+type Handler func(ctx context.Context, e Event) error
+
+type subscription struct {
+	types   map[string]bool
+	handler Handler
+}
+
+// Bus dispatches outbox events to named subscribers
+type Bus struct {
+	db   *sql.DB
+	wake chan struct{}
+	// stop asks the dispatch loop to return and done is closed once it has,
+	// so Stop can wait out a dispatch that is under way
+	stop chan struct{}
+	done chan struct{}
+
+	mu   sync.Mutex
+	subs map[string]subscription
+}
+
+// NewBus creates a dispatcher backed by the outbox tables
+func NewBus(db *sql.DB) *Bus {
+	return &Bus{db: db, wake: make(chan struct{}, 1), stop: make(chan struct{}), subs: map[string]subscription{}}
+}
+
+// Subscribe registers handler for the given event types. Deliveries are
+// tracked per name, so it must stay the same across releases.
+func (b *Bus) Subscribe(name string, handler Handler, types ...string) {
+	set := map[string]bool{}
+	for _, t := range types {
+		set[t] = true
+	}
+	b.mu.Lock()
+	b.subs[name] = subscription{types: set, handler: handler}
+	b.mu.Unlock()
+}
+
+// Listen wakes the dispatcher as soon as events are committed by any replica
+func (b *Bus) Listen(dsn string) error {
+	listener := pq.NewListener(dsn, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Outbox listener: %v", err)
+		}
+	})
+	if err := listener.Listen(NotifyChannel); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to listen for outbox events: %w", err)
+	}
+
+	go func() {
+		for range listener.Notify {
+			b.poke()
+		}
+	}()
+	return nil
+}
+
+func (b *Bus) poke() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start dispatches events as they are committed, and at least every interval
+func (b *Bus) Start(interval time.Duration) {
+	b.done = make(chan struct{})
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := b.Process(); err != nil {
+				log.Printf("Failed to dispatch events: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-b.wake:
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background loop once the current dispatch finishes, waiting
+// for it until ctx ends
+func (b *Bus) Stop(ctx context.Context) error {
+	if b.done == nil {
+		return nil
+	}
+	close(b.stop)
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Process fans new events out to their subscribers and runs every delivery that is due
+func (b *Bus) Process() error {
+	for {
+		n, err := b.fanOut()
+		if err != nil {
+			return err
+		}
+		if n < batchSize {
+			break
+		}
+	}
+	for {
+		n, err := b.deliver()
+		if err != nil {
+			return err
+		}
+		if n < batchSize {
+			return nil
+		}
+	}
+}
+
+// fanOut creates a delivery for each subscriber of each undispatched event
+func (b *Bus) fanOut() (int, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		`SELECT id, type FROM outbox_events
+		 WHERE dispatched_at IS NULL
+		 ORDER BY id
+		 LIMIT $1
+		 FOR UPDATE SKIP LOCKED`,
+		batchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		id        int64
+		eventType string
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.eventType); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	b.mu.Lock()
+	subs := make(map[string]subscription, len(b.subs))
+	for name, s := range b.subs {
+		subs[name] = s
+	}
+	b.mu.Unlock()
+
+	ids := make([]int64, 0, len(batch))
+	for _, p := range batch {
+		for name, s := range subs {
+			if !s.types[p.eventType] {
+				continue
+			}
+			_, err := tx.Exec(
+				`INSERT INTO event_deliveries (event_id, subscriber) VALUES ($1, $2)
+				 ON CONFLICT (event_id, subscriber) DO NOTHING`,
+				p.id, name,
+			)
+			if err != nil {
+				return 0, err
+			}
+		}
+		ids = append(ids, p.id)
+	}
+
+	if len(ids) > 0 {
+		_, err := tx.Exec(`UPDATE outbox_events SET dispatched_at = CURRENT_TIMESTAMP WHERE id = ANY($1)`, pq.Array(ids))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(batch), tx.Commit()
+}
+
+// delivery is a claimed event for one subscriber
+type delivery struct {
+	id         int64
+	subscriber string
+	attempt    int
+	event      Event
+}
+
+// deliver claims due deliveries and hands them to their subscribers
+func (b *Bus) deliver() (int, error) {
+	rows, err := b.db.Query(
+		`UPDATE event_deliveries d
+		 SET status = 'processing', attempts = d.attempts + 1, updated_at = CURRENT_TIMESTAMP
+		 FROM outbox_events e
+		 WHERE e.id = d.event_id AND d.id IN (
+		     SELECT id FROM event_deliveries
+		     WHERE (status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP)
+		        OR (status = 'processing' AND updated_at < CURRENT_TIMESTAMP - $1::int * INTERVAL '1 second')
+		     ORDER BY event_id
+		     LIMIT $2
+		     FOR UPDATE SKIP LOCKED)
+		 RETURNING d.id, d.subscriber, d.attempts, e.id, e.type, e.payload, e.created_at`,
+		int(claimTimeout.Seconds()), batchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var batch []delivery
+	for rows.Next() {
+		var d delivery
+		if err := rows.Scan(&d.id, &d.subscriber, &d.attempt,
+			&d.event.ID, &d.event.Type, &d.event.Payload, &d.event.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, d := range batch {
+		b.handle(d)
+	}
+	return len(batch), nil
+}
+
+// handle runs one delivery and records the outcome
+func (b *Bus) handle(d delivery) {
+	b.mu.Lock()
+	sub, ok := b.subs[d.subscriber]
+	b.mu.Unlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("subscriber %q is not registered", d.subscriber)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), handlerTimeout)
+		err = safeHandle(ctx, sub.handler, d.event)
+		cancel()
+	}
+
+	if err == nil {
+		_, err = b.db.Exec(
+			`UPDATE event_deliveries SET status = 'done', last_error = NULL, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $1`,
+			d.id,
+		)
+	} else if !ok || d.attempt >= maxAttempts {
+		log.Printf("Event %d (%s) failed for %s: %v", d.event.ID, d.event.Type, d.subscriber, err)
+		_, err = b.db.Exec(
+			`UPDATE event_deliveries SET status = 'failed', last_error = $1, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $2`,
+			err.Error(), d.id,
+		)
+	} else {
+		_, err = b.db.Exec(
+			`UPDATE event_deliveries
+			 SET status = 'pending', last_error = $1, updated_at = CURRENT_TIMESTAMP,
+			     next_attempt_at = CURRENT_TIMESTAMP + $2::int * INTERVAL '1 second'
+			 WHERE id = $3`,
+			err.Error(), int(backoff(d.attempt).Seconds()), d.id,
+		)
+	}
+	if err != nil {
+		log.Printf("Failed to record delivery of event %d to %s: %v", d.event.ID, d.subscriber, err)
+	}
+}
+
+// safeHandle runs a handler, turning a panic into an error
+func safeHandle(ctx context.Context, h Handler, e Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, e)
+}
+
+// backoff returns the delay before retrying after the given attempt
+func backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Prune deletes dispatched events older than the retention period whose
+// deliveries have all finished
+func (b *Bus) Prune(ctx context.Context) error {
+	_, err := b.db.ExecContext(ctx,
+		`DELETE FROM outbox_events e
+		 WHERE e.dispatched_at < CURRENT_TIMESTAMP - $1::int * INTERVAL '1 second'
+		   AND NOT EXISTS (SELECT 1 FROM event_deliveries d
+		                   WHERE d.event_id = e.id AND d.status IN ('pending', 'processing'))`,
+		int(retention.Seconds()),
+	)
+	return err
+}
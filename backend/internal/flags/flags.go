@@ -0,0 +1,258 @@
+// Package flags evaluates feature flags with targeting and percentage rollouts.
+package flags
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ChangeChannel is the Postgres NOTIFY channel raised when a flag row changes
+const ChangeChannel = "feature_flags_changed"
+
+// refreshInterval reloads flags even without notifications, in case one was missed
+const refreshInterval = 5 * time.Minute
+
+// Targeting restricts a flag to matching subjects. Empty lists match everyone
+// and each non-empty list must match. Listed UserIDs always match; a flag that
+// only lists UserIDs matches nobody else.
+type Targeting struct {
+	CityIDs     []int    `json:"city_ids,omitempty"`
+	CorridorIDs []int    `json:"corridor_ids,omitempty"`
+	Orgs        []string `json:"orgs,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	UserIDs     []int    `json:"user_ids,omitempty"`
+}
+
+// Flag is a feature flag definition
+type Flag struct {
+	Name              string    `json:"name"`
+	Enabled           bool      `json:"enabled"`
+	Description       *string   `json:"description"`
+	RolloutPercentage int       `json:"rollout_percentage"`
+	Targeting         Targeting `json:"targeting"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Subject is who a flag is being evaluated for. Zero values mean unknown.
+type Subject struct {
+	UserID     int
+	Role       string
+	Org        string
+	CityID     int
+	CorridorID int
+}
+
+// Service caches flags in memory and keeps them current
+type Service struct {
+	db *sql.DB
+
+	mu          sync.RWMutex
+	flags       map[string]Flag
+	subscribers []func(Flag)
+}
+
+// NewService creates a flag service backed by the feature_flags table
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db, flags: map[string]Flag{}}
+}
+
+// Load refreshes the cache from the database and notifies subscribers of changed flags
+func (s *Service) Load() error {
+	rows, err := s.db.Query(
+		`SELECT name, enabled, description, rollout_percentage, targeting, updated_at FROM feature_flags`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	loaded := map[string]Flag{}
+	for rows.Next() {
+		var f Flag
+		var targeting []byte
+		if err := rows.Scan(&f.Name, &f.Enabled, &f.Description, &f.RolloutPercentage, &targeting, &f.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to load feature flags: %w", err)
+		}
+		if len(targeting) > 0 {
+			if err := json.Unmarshal(targeting, &f.Targeting); err != nil {
+				log.Printf("Ignoring invalid targeting for flag %s: %v", f.Name, err)
+			}
+		}
+		loaded[f.Name] = f
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load feature flags: %w", err)
+	}
+
+	s.mu.Lock()
+	var changed []Flag
+	for name, f := range loaded {
+		if old, ok := s.flags[name]; !ok || !reflect.DeepEqual(old, f) {
+			changed = append(changed, f)
+		}
+	}
+	for name, old := range s.flags {
+		if _, ok := loaded[name]; !ok {
+			old.Enabled = false
+			changed = append(changed, old)
+		}
+	}
+	s.flags = loaded
+	subscribers := append([]func(Flag){}, s.subscribers...)
+	s.mu.Unlock()
+
+	for _, f := range changed {
+		for _, fn := range subscribers {
+			fn(f)
+		}
+	}
+	return nil
+}
+
+// Listen reloads flags whenever another replica changes them, with a periodic refresh as a fallback
+func (s *Service) Listen(dsn string) error {
+	listener := pq.NewListener(dsn, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Feature flag listener: %v", err)
+		}
+	})
+	if err := listener.Listen(ChangeChannel); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to listen for feature flag changes: %w", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-listener.Notify:
+				// A nil notification means the connection was re-established; reload either way
+			case <-ticker.C:
+			}
+			if err := s.Load(); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Subscribe registers fn to be called whenever a flag is created, changed or removed
+func (s *Service) Subscribe(fn func(Flag)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Get returns a flag definition from the cache
+func (s *Service) Get(name string) (Flag, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.flags[name]
+	return f, ok
+}
+
+// All returns every cached flag, sorted by name
+func (s *Service) All() []Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := make([]Flag, 0, len(s.flags))
+	for _, f := range s.flags {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Enabled reports whether a flag is on for a subject. Unknown flags are off.
+func (s *Service) Enabled(name string, subject Subject) bool {
+	f, ok := s.Get(name)
+	if !ok {
+		return false
+	}
+	return f.EnabledFor(subject)
+}
+
+// Evaluate returns the state of every flag for a subject
+func (s *Service) Evaluate(subject Subject) map[string]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]bool, len(s.flags))
+	for name, f := range s.flags {
+		result[name] = f.EnabledFor(subject)
+	}
+	return result
+}
+
+// EnabledFor applies targeting and percentage rollout for a subject
+func (f Flag) EnabledFor(subject Subject) bool {
+	if !f.Enabled {
+		return false
+	}
+
+	t := f.Targeting
+	if subject.UserID != 0 && containsInt(t.UserIDs, subject.UserID) {
+		return true
+	}
+	if len(t.UserIDs) > 0 && len(t.CityIDs) == 0 && len(t.CorridorIDs) == 0 && len(t.Orgs) == 0 && len(t.Roles) == 0 {
+		// An allowlist-only flag excludes everyone not on the list
+		return false
+	}
+	if len(t.CityIDs) > 0 && !containsInt(t.CityIDs, subject.CityID) {
+		return false
+	}
+	if len(t.CorridorIDs) > 0 && !containsInt(t.CorridorIDs, subject.CorridorID) {
+		return false
+	}
+	if len(t.Orgs) > 0 && !containsFold(t.Orgs, subject.Org) {
+		return false
+	}
+	if len(t.Roles) > 0 && !containsFold(t.Roles, subject.Role) {
+		return false
+	}
+
+	return inRollout(f.Name, subject.UserID, f.RolloutPercentage)
+}
+
+// inRollout deterministically buckets a user into 0-99 per flag so each user
+// keeps the same result as the percentage grows
+func inRollout(name string, userID, percentage int) bool {
+	if percentage >= 100 {
+		return true
+	}
+	if percentage <= 0 || userID == 0 {
+		return false
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", name, userID)
+	return int(h.Sum32()%100) < percentage
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, v string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
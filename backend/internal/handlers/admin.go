@@ -3,61 +3,98 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
+	"cpool.ai/backend/internal/apierr"
 	"cpool.ai/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetAllUsers returns all users (admin only)
-func (h *Handlers) GetAllUsers(c *gin.Context) {
+// userSorts are the sort keys GetAllUsers accepts
+var userSorts = listSorts{
+	"created_at": {"COALESCE(created_at, 'epoch')", "timestamp"},
+	"name":       {"name", "text"},
+	"email":      {"email", "text"},
+	"city":       {"COALESCE(city, '')", "text"},
+}
+
+// GetAllUsers returns a page of users, newest first by default. q searches
+// name, email, city and role; city and role filter exactly (admin only).
+func (h *Handlers) GetAllUsers(c *gin.Context) error {
+	p, err := parsePage(c, userSorts, "-created_at", "id")
+	if err != nil {
+		return err
+	}
+
+	where := ` WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		placeholder := `$` + strconv.Itoa(argIndex)
+		where += ` AND (name ILIKE ` + placeholder + ` OR email ILIKE ` + placeholder +
+			` OR city ILIKE ` + placeholder + ` OR role ILIKE ` + placeholder + `)`
+		args = append(args, "%"+q+"%")
+		argIndex++
+	}
+	if city := c.Query("city"); city != "" {
+		where += ` AND LOWER(city) = LOWER($` + strconv.Itoa(argIndex) + `)`
+		args = append(args, city)
+		argIndex++
+	}
+	if role := c.Query("role"); role != "" {
+		if role != "user" && role != "admin" {
+			return apierr.BadRequest("role must be user or admin")
+		}
+		where += ` AND role = $` + strconv.Itoa(argIndex)
+		args = append(args, role)
+		argIndex++
+	}
+
+	after, afterArgs := p.where(argIndex)
 	rows, err := h.DB.Query(
-		`SELECT id, email, name, phone, city, role, carbon_credits, upi_id, created_at, updated_at
-		 FROM users ORDER BY created_at DESC`,
+		`SELECT id, email, name, phone, city, role, carbon_credits, upi_id, created_at, updated_at`+p.sortColumn()+`
+		 FROM users`+where+after+p.orderBy(),
+		append(args, afterArgs...)...,
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var users []models.User
+	users := []models.User{}
 	for rows.Next() {
 		var user models.User
+		var sortValue string
 		if err := rows.Scan(
 			&user.ID, &user.Email, &user.Name, &user.Phone, &user.City,
-			&user.Role, &user.CarbCredits, &user.UPIID, &user.CreatedAt, &user.UpdatedAt,
+			&user.Role, &user.CarbCredits, &user.UPIID, &user.CreatedAt, &user.UpdatedAt, &sortValue,
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, user.ID) {
+			break
 		}
 		users = append(users, user)
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, usersPage{Users: users, NextCursor: p.nextCursor()})
+	return nil
 }
 
 // UpdateUser updates a user (admin only)
-func (h *Handlers) UpdateUser(c *gin.Context) {
+func (h *Handlers) UpdateUser(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
+		return apierr.BadRequest("Invalid user ID")
 	}
 
-	var req struct {
-		Name         *string `json:"name"`
-		Phone        *string `json:"phone"`
-		City         *string `json:"city"`
-		Role         *string `json:"role"`
-		CarbCredits  *int    `json:"carbon_credits"`
-		UPIID        *string `json:"upi_id"`
-	}
+	var req updateUserRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
 	updates := []string{}
@@ -96,8 +133,7 @@ func (h *Handlers) UpdateUser(c *gin.Context) {
 	}
 
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
-		return
+		return apierr.BadRequest("No fields to update")
 	}
 
 	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
@@ -109,61 +145,49 @@ func (h *Handlers) UpdateUser(c *gin.Context) {
 	}
 	query += ` WHERE id = $` + strconv.Itoa(argIndex)
 
-	_, err = h.DB.Exec(query, args...)
+	tx, err := h.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-		return
+		return apierr.Internal("Database error", err)
 	}
+	defer tx.Rollback()
 
-	c.JSON(http.StatusOK, gin.H{"message": "User updated"})
-}
-
-// GetAnalytics returns analytics data (admin only)
-func (h *Handlers) GetAnalytics(c *gin.Context) {
-	var stats struct {
-		TotalUsers      int     `json:"total_users"`
-		TotalRides      int     `json:"total_rides"`
-		ActiveRides     int     `json:"active_rides"`
-		CompletedRides  int     `json:"completed_rides"`
-		TotalRevenue    float64 `json:"total_revenue"`
-		TotalCredits    int     `json:"total_credits"`
-		ActiveCorridors int     `json:"active_corridors"`
-	}
-
-	h.DB.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&stats.TotalUsers)
-	h.DB.QueryRow(`SELECT COUNT(*) FROM rides`).Scan(&stats.TotalRides)
-	h.DB.QueryRow(`SELECT COUNT(*) FROM rides WHERE status IN ('open', 'partially_filled')`).Scan(&stats.ActiveRides)
-	h.DB.QueryRow(`SELECT COUNT(*) FROM rides WHERE status = 'completed'`).Scan(&stats.CompletedRides)
-	h.DB.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM payments WHERE rider_status = 'done' AND giver_status = 'received'`).Scan(&stats.TotalRevenue)
-	h.DB.QueryRow(`SELECT COALESCE(SUM(credits), 0) FROM carbon_credits`).Scan(&stats.TotalCredits)
-	h.DB.QueryRow(`SELECT COUNT(*) FROM corridors WHERE is_active = true`).Scan(&stats.ActiveCorridors)
+	before := snapshot(tx, "users", "id = $1", id)
 
-	c.JSON(http.StatusOK, stats)
-}
-
-// ToggleFeature toggles a feature flag (admin only)
-func (h *Handlers) ToggleFeature(c *gin.Context) {
-	featureName := c.Param("name")
-
-	var req struct {
-		Enabled bool `json:"enabled"`
+	_, err = tx.Exec(query, args...)
+	if err == nil {
+		err = audit(c, tx, "user.update", "user", id, before, snapshot(tx, "users", "id = $1", id))
 	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to update user", err)
 	}
 
-	_, err := h.DB.Exec(
-		`UPDATE feature_flags SET enabled = $1, updated_at = CURRENT_TIMESTAMP WHERE name = $2`,
-		req.Enabled, featureName,
-	)
+	c.JSON(http.StatusOK, gin.H{"message": "User updated"})
+	return nil
+}
 
+// GetAnalytics returns analytics data (admin only)
+func (h *Handlers) GetAnalytics(c *gin.Context) error {
+	var stats analyticsResponse
+
+	err := h.DB.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM users),
+		        (SELECT COUNT(*) FROM rides),
+		        (SELECT COUNT(*) FROM rides WHERE status IN ('open', 'partially_filled')),
+		        (SELECT COUNT(*) FROM rides WHERE status = 'completed'),
+		        (SELECT COALESCE(SUM(amount), 0) FROM payments WHERE rider_status = 'done' AND giver_status = 'received'),
+		        (SELECT COALESCE(SUM(credits), 0) FROM carbon_credits),
+		        (SELECT COUNT(*) FROM corridors WHERE is_active = true)`,
+	).Scan(
+		&stats.TotalUsers, &stats.TotalRides, &stats.ActiveRides, &stats.CompletedRides,
+		&stats.TotalRevenue, &stats.TotalCredits, &stats.ActiveCorridors,
+	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle feature"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Feature toggled"})
+	c.JSON(http.StatusOK, stats)
+	return nil
 }
-
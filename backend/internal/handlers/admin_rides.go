@@ -0,0 +1,716 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/events"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// staleRideReason is recorded on rides closed after departing without any riders
+const staleRideReason = "Expired without riders"
+
+// adminRideSelect and adminRideFrom are kept apart so a list can add its
+// sort column between them
+const adminRideSelect = `
+	SELECT r.id, r.user_id, u.name as user_name, r.corridor_id, c.name as corridor_name,
+	       r.vehicle_id, r.ride_date, to_char(r.ride_time, 'HH24:MI'), r.pickup_point, r.drop_point,
+	       r.route_description, r.price_per_seat, r.available_seats, r.total_seats,
+	       r.status, r.visibility, ci.timezone, ci.currency,
+	       COALESCE((SELECT SUM(rr.seats_requested) FROM ride_requests rr
+	                 WHERE rr.ride_id = r.id AND rr.status = 'accepted'), 0),
+	       r.cancel_reason, r.cancelled_at, r.created_at, r.updated_at`
+
+const adminRideFrom = `
+	FROM rides r
+	JOIN users u ON r.user_id = u.id
+	JOIN corridors c ON r.corridor_id = c.id
+	JOIN cities ci ON c.city_id = ci.id`
+
+const adminRideColumns = adminRideSelect + adminRideFrom
+
+func scanAdminRide(scan func(...interface{}) error) (models.Ride, error) {
+	var ride models.Ride
+	var booked int
+	err := scan(
+		&ride.ID, &ride.UserID, &ride.UserName, &ride.CorridorID, &ride.CorridorName,
+		&ride.VehicleID, &ride.RideDate, &ride.RideTime, &ride.PickupPoint, &ride.DropPoint,
+		&ride.RouteDescription, &ride.PricePerSeat, &ride.AvailableSeats, &ride.TotalSeats,
+		&ride.Status, &ride.Visibility, &ride.Timezone, &ride.Currency, &booked,
+		&ride.CancelReason, &ride.CancelledAt, &ride.CreatedAt, &ride.UpdatedAt,
+	)
+	ride.BookedSeats = &booked
+	return ride, err
+}
+
+// adminRideSorts are the sort keys AdminGetRides accepts
+var adminRideSorts = listSorts{
+	"departure":  {"r.ride_date + r.ride_time", "timestamp"},
+	"created_at": {"COALESCE(r.created_at, 'epoch')", "timestamp"},
+}
+
+// AdminGetRides lists and searches rides in any status, latest departure
+// first by default (admin only)
+func (h *Handlers) AdminGetRides(c *gin.Context) error {
+	p, err := parsePage(c, adminRideSorts, "-departure", "r.id")
+	if err != nil {
+		return err
+	}
+
+	where := ` WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+
+	for _, filter := range []struct{ param, column string }{
+		{"status", "r.status"},
+		{"city_id", "c.city_id"},
+		{"corridor_id", "r.corridor_id"},
+		{"user_id", "r.user_id"},
+		{"vehicle_id", "r.vehicle_id"},
+	} {
+		v := c.Query(filter.param)
+		if v == "" {
+			continue
+		}
+		if filter.param != "status" {
+			if _, err := strconv.Atoi(v); err != nil {
+				return apierr.BadRequest("Invalid " + filter.param)
+			}
+		}
+		where += ` AND ` + filter.column + ` = $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+
+	if v := c.Query("rider_id"); v != "" {
+		if _, err := strconv.Atoi(v); err != nil {
+			return apierr.BadRequest("Invalid rider_id")
+		}
+		where += ` AND EXISTS(SELECT 1 FROM ride_requests rr WHERE rr.ride_id = r.id AND rr.user_id = $` + strconv.Itoa(argIndex) + `)`
+		args = append(args, v)
+		argIndex++
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		placeholder := `$` + strconv.Itoa(argIndex)
+		where += ` AND (u.name ILIKE ` + placeholder + ` OR u.email ILIKE ` + placeholder +
+			` OR c.name ILIKE ` + placeholder + ` OR r.pickup_point ILIKE ` + placeholder +
+			` OR r.drop_point ILIKE ` + placeholder + `)`
+		args = append(args, "%"+q+"%")
+		argIndex++
+	}
+
+	for _, bound := range []struct{ param, op string }{{"from", ">="}, {"to", "<="}} {
+		v := c.Query(bound.param)
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return apierr.BadRequest("Invalid " + bound.param + " date, expected YYYY-MM-DD")
+		}
+		where += ` AND r.ride_date ` + bound.op + ` $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+
+	if c.Query("stale") == "true" {
+		where += ` AND r.status IN ('open', 'partially_filled', 'full')
+		           AND r.ride_date < (CURRENT_TIMESTAMP AT TIME ZONE ci.timezone)::date`
+	}
+
+	after, afterArgs := p.where(argIndex)
+	rows, err := h.DB.Query(
+		adminRideSelect+p.sortColumn()+adminRideFrom+where+after+p.orderBy(),
+		append(args, afterArgs...)...,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	rides := []models.Ride{}
+	for rows.Next() {
+		var sortValue string
+		ride, err := scanAdminRide(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &sortValue)...)
+		})
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, ride.ID) {
+			break
+		}
+		rides = append(rides, ride)
+	}
+
+	c.JSON(http.StatusOK, adminRidesPage{Rides: rides, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// AdminCancelRide force-cancels a ride with a reason and notifies everyone on it (admin only)
+func (h *Handlers) AdminCancelRide(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	adminID, _ := c.Get("user_id")
+
+	var req adminCancelRideRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return apierr.BadRequest("A cancellation reason is required")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "rides", "id = $1", id)
+
+	var driverID int
+	var status, rideDate string
+	err = tx.QueryRow(
+		`SELECT user_id, status, to_char(ride_date, 'YYYY-MM-DD') FROM rides WHERE id = $1 FOR UPDATE`,
+		id,
+	).Scan(&driverID, &status, &rideDate)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Ride not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if status == "cancelled" || status == "completed" {
+		return apierr.Conflict(apierr.CodeRideClosed, "Ride is already "+status)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE rides SET status = 'cancelled', cancel_reason = $1, cancelled_by = $2,
+		                 cancelled_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $3`,
+		req.Reason, adminID, id,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to cancel ride", err)
+	}
+
+	riderIDs, err := rideRiders(tx, id, "accepted", "pending")
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE ride_requests SET status = 'rejected', updated_at = CURRENT_TIMESTAMP
+		 WHERE ride_id = $1 AND status = 'pending'`,
+		id,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to cancel ride", err)
+	}
+
+	err = events.Publish(tx, events.RideCancelled, events.RidePayload{
+		RideID:      id,
+		DriverID:    driverID,
+		RideDate:    rideDate,
+		RiderIDs:    riderIDs,
+		CancelledBy: "admin",
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		return apierr.Internal("Failed to cancel ride", err)
+	}
+
+	if err := audit(c, tx, "ride.force_cancel", "ride", id, before, snapshot(tx, "rides", "id = $1", id)); err != nil {
+		return apierr.Internal("Failed to cancel ride", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apierr.Internal("Failed to cancel ride", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ride cancelled", "riders_notified": len(riderIDs)})
+	return nil
+}
+
+// ReassignRide hands an upcoming ride to another driver and vehicle (admin only)
+func (h *Handlers) ReassignRide(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	var req reassignRideRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "rides", "id = $1", id)
+
+	var previousDriverID, corridorID int
+	var upcoming bool
+	var rideDate, rideTime string
+	err = tx.QueryRow(
+		`SELECT r.user_id, r.corridor_id,
+		        r.status IN ('open', 'partially_filled', 'full')
+		          AND r.ride_date >= (CURRENT_TIMESTAMP AT TIME ZONE ci.timezone)::date,
+		        to_char(r.ride_date, 'YYYY-MM-DD'), to_char(r.ride_time, 'HH24:MI')
+		 FROM rides r
+		 JOIN corridors co ON r.corridor_id = co.id
+		 JOIN cities ci ON co.city_id = ci.id
+		 WHERE r.id = $1
+		 FOR UPDATE OF r`,
+		id,
+	).Scan(&previousDriverID, &corridorID, &upcoming, &rideDate, &rideTime)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Ride not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if !upcoming {
+		return apierr.Conflict(apierr.CodeRideNotUpcoming, "Only upcoming rides can be reassigned")
+	}
+
+	var hasAccess, isRider bool
+	err = tx.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM user_corridors WHERE user_id = $1 AND corridor_id = $2),
+		        EXISTS(SELECT 1 FROM ride_requests WHERE user_id = $1 AND ride_id = $3 AND status = 'accepted')`,
+		req.UserID, corridorID, id,
+	).Scan(&hasAccess, &isRider)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if !hasAccess {
+		return apierr.BadRequest("New driver doesn't have access to this corridor")
+	}
+	if isRider {
+		return apierr.Conflict(apierr.CodeDriverIsRider, "New driver is already a rider on this ride")
+	}
+
+	var totalSeats int
+	var verificationStatus, description string
+	err = tx.QueryRow(
+		`SELECT total_seats, verification_status, make || ' ' || model || ' (' || vehicle_number || ')'
+		 FROM vehicles WHERE id = $1 AND user_id = $2 AND archived_at IS NULL FOR UPDATE`,
+		req.VehicleID, req.UserID,
+	).Scan(&totalSeats, &verificationStatus, &description)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Vehicle not found for new driver")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if verificationStatus != "verified" {
+		return apierr.New(http.StatusForbidden, apierr.CodeVehicleUnverified, "Vehicle must be verified before offering rides")
+	}
+
+	ride := upcomingRide{id: id}
+	err = tx.QueryRow(
+		`SELECT COALESCE(SUM(seats_requested), 0) FROM ride_requests WHERE ride_id = $1 AND status = 'accepted'`,
+		id,
+	).Scan(&ride.booked)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if ride.booked > totalSeats {
+		return apierr.Conflict(apierr.CodeSeatsBooked, "Vehicle doesn't have enough seats for riders already booked").
+			With("booked_seats", ride.booked)
+	}
+
+	if _, err := tx.Exec(`UPDATE rides SET user_id = $1 WHERE id = $2`, req.UserID, id); err != nil {
+		return apierr.Internal("Failed to reassign ride", err)
+	}
+
+	if err := resizeRides(tx, []upcomingRide{ride}, req.VehicleID, totalSeats); err != nil {
+		return apierr.Internal("Failed to reassign ride", err)
+	}
+
+	// Outstanding payments are now owed to the new driver
+	_, err = tx.Exec(
+		`UPDATE payments SET ride_giver_id = $1, updated_at = CURRENT_TIMESTAMP
+		 WHERE ride_id = $2 AND giver_status = 'pending'`,
+		req.UserID, id,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to reassign ride", err)
+	}
+
+	riderIDs, err := rideRiders(tx, id, "accepted")
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if err := audit(c, tx, "ride.reassign", "ride", id, before, snapshot(tx, "rides", "id = $1", id)); err != nil {
+		return apierr.Internal("Failed to reassign ride", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apierr.Internal("Failed to reassign ride", err)
+	}
+
+	when := rideDate + " at " + rideTime
+	h.notify(previousDriverID, "ride_reassigned", "Ride reassigned",
+		"Your ride on "+when+" has been reassigned to another driver by an administrator.")
+	h.notify(req.UserID, "ride_assigned", "Ride assigned to you",
+		"An administrator assigned you the ride on "+when+" in your "+description+".")
+	for _, riderID := range riderIDs {
+		h.notify(riderID, "ride_driver_changed", "Driver changed for your ride",
+			"Your ride on "+when+" has a new driver travelling in "+description+".")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ride reassigned"})
+	return nil
+}
+
+// CloseStaleRides closes rides whose date has passed in their city. Rides with
+// accepted riders are completed; the rest are cancelled (admin only).
+func (h *Handlers) CloseStaleRides(c *gin.Context) error {
+	var req closeStaleRidesRequest
+
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return apierr.Validation(err)
+		}
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	completed, cancelled, err := closeStaleRides(tx, req.RideIDs)
+	for _, id := range completed {
+		if err == nil {
+			err = audit(c, tx, "ride.close_stale", "ride", id, nil, auditData(gin.H{"status": "completed"}))
+		}
+	}
+	for _, id := range cancelled {
+		if err == nil {
+			err = audit(c, tx, "ride.close_stale", "ride", id, nil, auditData(gin.H{"status": "cancelled", "cancel_reason": staleRideReason}))
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to close rides", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"completed": completed, "cancelled": cancelled})
+	return nil
+}
+
+// closeStaleRides closes still-open rides dated before today in their city,
+// optionally restricted to the given IDs, and rejects their pending requests.
+// The caller commits tx.
+func closeStaleRides(tx *sql.Tx, rideIDs []int) ([]int64, []int64, error) {
+	ids := make([]int64, len(rideIDs))
+	for i, id := range rideIDs {
+		ids[i] = int64(id)
+	}
+	var filter interface{}
+	if len(ids) > 0 {
+		filter = pq.Array(ids)
+	}
+
+	rows, err := tx.Query(
+		`WITH stale AS (
+		     SELECT r.id,
+		            EXISTS(SELECT 1 FROM ride_requests rr
+		                   WHERE rr.ride_id = r.id AND rr.status = 'accepted') AS had_riders
+		     FROM rides r
+		     JOIN corridors co ON r.corridor_id = co.id
+		     JOIN cities ci ON co.city_id = ci.id
+		     WHERE r.status IN ('open', 'partially_filled', 'full')
+		       AND r.ride_date < (CURRENT_TIMESTAMP AT TIME ZONE ci.timezone)::date
+		       AND ($1::bigint[] IS NULL OR r.id = ANY($1::bigint[]))
+		     FOR UPDATE OF r
+		 )
+		 UPDATE rides r SET
+		     status = CASE WHEN s.had_riders THEN 'completed' ELSE 'cancelled' END,
+		     cancel_reason = CASE WHEN s.had_riders THEN r.cancel_reason ELSE $2 END,
+		     cancelled_at = CASE WHEN s.had_riders THEN r.cancelled_at ELSE CURRENT_TIMESTAMP END,
+		     updated_at = CURRENT_TIMESTAMP
+		 FROM stale s
+		 WHERE r.id = s.id
+		 RETURNING r.id, r.status, r.user_id, to_char(r.ride_date, 'YYYY-MM-DD')`,
+		filter, staleRideReason,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	completed, cancelled := []int64{}, []int64{}
+	var closedRides []events.RidePayload
+	for rows.Next() {
+		var id int64
+		var status string
+		var ride events.RidePayload
+		if err := rows.Scan(&id, &status, &ride.DriverID, &ride.RideDate); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		ride.RideID = int(id)
+		if status == "completed" {
+			completed = append(completed, id)
+		} else {
+			ride.CancelledBy = "system"
+			ride.Reason = staleRideReason
+			cancelled = append(cancelled, id)
+		}
+		closedRides = append(closedRides, ride)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, ride := range closedRides {
+		eventType := events.RideCancelled
+		if ride.CancelledBy == "" {
+			eventType = events.RideCompleted
+			if ride.RiderIDs, err = rideRiders(tx, ride.RideID, "accepted"); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := events.Publish(tx, eventType, ride); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	closed := append(append([]int64{}, completed...), cancelled...)
+	if len(closed) > 0 {
+		_, err := tx.Exec(
+			`UPDATE ride_requests SET status = 'rejected', updated_at = CURRENT_TIMESTAMP
+			 WHERE ride_id = ANY($1) AND status = 'pending'`,
+			pq.Array(closed),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return completed, cancelled, nil
+}
+
+// rideRiders returns the distinct users with requests in the given statuses on a ride
+func rideRiders(tx *sql.Tx, rideID int, statuses ...string) ([]int, error) {
+	rows, err := tx.Query(
+		`SELECT DISTINCT user_id FROM ride_requests WHERE ride_id = $1 AND status = ANY($2)`,
+		rideID, pq.Array(statuses),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetRideTimeline returns a ride with its requests, messages, payments and a
+// merged chronological event history (admin only)
+func (h *Handlers) GetRideTimeline(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	timeline := models.RideTimeline{
+		Requests: []models.RideRequest{},
+		Messages: []models.Message{},
+		Payments: []models.Payment{},
+		Events:   []models.RideEvent{},
+	}
+
+	timeline.Ride, err = scanAdminRide(h.DB.QueryRow(adminRideColumns+` WHERE r.id = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Ride not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	ride := timeline.Ride
+	driverID := ride.UserID
+	timeline.Events = append(timeline.Events, models.RideEvent{
+		At: ride.CreatedAt, Type: "ride.created", ActorID: &driverID, ActorName: ride.UserName,
+		Detail: fmt.Sprintf("%d seats at %.2f %s", ride.TotalSeats, ride.PricePerSeat, ride.Currency),
+	})
+
+	requests, err := h.DB.Query(
+		`SELECT rr.id, rr.ride_id, rr.user_id, u.name as user_name, rr.seats_requested,
+		       rr.comment, rr.status, rr.created_at, rr.updated_at
+		 FROM ride_requests rr
+		 JOIN users u ON rr.user_id = u.id
+		 WHERE rr.ride_id = $1
+		 ORDER BY rr.created_at`,
+		id,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer requests.Close()
+
+	for requests.Next() {
+		var req models.RideRequest
+		if err := requests.Scan(
+			&req.ID, &req.RideID, &req.UserID, &req.UserName, &req.SeatsRequested,
+			&req.Comment, &req.Status, &req.CreatedAt, &req.UpdatedAt,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		timeline.Requests = append(timeline.Requests, req)
+
+		riderID := req.UserID
+		timeline.Events = append(timeline.Events, models.RideEvent{
+			At: req.CreatedAt, Type: "request.created", ActorID: &riderID, ActorName: req.UserName,
+			Detail: fmt.Sprintf("%d seat(s) requested", req.SeatsRequested),
+		})
+		if req.Status != "pending" {
+			timeline.Events = append(timeline.Events, models.RideEvent{
+				At: req.UpdatedAt, Type: "request." + req.Status, ActorID: &riderID, ActorName: req.UserName,
+			})
+		}
+	}
+
+	messages, err := h.DB.Query(
+		`SELECT m.id, m.ride_id, m.user_id, u.name as user_name, m.message, m.created_at
+		 FROM messages m
+		 JOIN users u ON m.user_id = u.id
+		 WHERE m.ride_id = $1
+		 ORDER BY m.created_at`,
+		id,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer messages.Close()
+
+	for messages.Next() {
+		var msg models.Message
+		if err := messages.Scan(
+			&msg.ID, &msg.RideID, &msg.UserID, &msg.UserName, &msg.Message, &msg.CreatedAt,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		timeline.Messages = append(timeline.Messages, msg)
+
+		authorID := msg.UserID
+		timeline.Events = append(timeline.Events, models.RideEvent{
+			At: msg.CreatedAt, Type: "message", ActorID: &authorID, ActorName: msg.UserName, Detail: msg.Message,
+		})
+	}
+
+	payments, err := h.DB.Query(
+		`SELECT p.id, p.ride_id, p.rider_id, u1.name as rider_name, p.ride_giver_id,
+		       u2.name as giver_name, p.amount, p.rider_status, p.giver_status,
+		       p.admin_override, p.created_at, p.updated_at
+		 FROM payments p
+		 JOIN users u1 ON p.rider_id = u1.id
+		 JOIN users u2 ON p.ride_giver_id = u2.id
+		 WHERE p.ride_id = $1
+		 ORDER BY p.created_at`,
+		id,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer payments.Close()
+
+	for payments.Next() {
+		var payment models.Payment
+		if err := payments.Scan(
+			&payment.ID, &payment.RideID, &payment.RiderID, &payment.RiderName,
+			&payment.RideGiverID, &payment.GiverName, &payment.Amount,
+			&payment.RiderStatus, &payment.GiverStatus, &payment.AdminOverride,
+			&payment.CreatedAt, &payment.UpdatedAt,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		timeline.Payments = append(timeline.Payments, payment)
+
+		riderID := payment.RiderID
+		timeline.Events = append(timeline.Events, models.RideEvent{
+			At: payment.CreatedAt, Type: "payment.created", ActorID: &riderID, ActorName: payment.RiderName,
+			Detail: fmt.Sprintf("%.2f %s owed to %s", payment.Amount, ride.Currency, payment.GiverName),
+		})
+		if payment.UpdatedAt.After(payment.CreatedAt) {
+			timeline.Events = append(timeline.Events, models.RideEvent{
+				At: payment.UpdatedAt, Type: "payment.updated", ActorID: &riderID, ActorName: payment.RiderName,
+				Detail: "rider " + payment.RiderStatus + ", driver " + payment.GiverStatus,
+			})
+		}
+	}
+
+	// Admin and owner actions recorded against the ride
+	audits, err := h.DB.Query(
+		`SELECT `+auditColumns+` FROM audit_log WHERE target_type = 'ride' AND target_id = $1 ORDER BY created_at`,
+		strconv.Itoa(id),
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer audits.Close()
+
+	for audits.Next() {
+		entry, err := scanAuditEntry(audits.Scan)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		event := models.RideEvent{At: entry.CreatedAt, Type: entry.Action, ActorID: entry.ActorID}
+		if entry.ActorEmail != nil {
+			event.ActorName = *entry.ActorEmail
+		}
+		if entry.After != nil {
+			event.Detail = string(entry.After)
+		}
+		timeline.Events = append(timeline.Events, event)
+	}
+
+	sort.SliceStable(timeline.Events, func(i, j int) bool {
+		return timeline.Events[i].At.Before(timeline.Events[j].At)
+	})
+
+	c.JSON(http.StatusOK, timeline)
+	return nil
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsRefreshDays is how far back each periodic refresh recomputes, so
+// late payments and credits land in the bucket of the ride they belong to
+const metricsRefreshDays = 60
+
+// metricsLockKey serialises rollup refreshes across replicas
+const metricsLockKey = 340034
+
+// refreshRideMetrics recomputes daily corridor buckets for rides dated on or
+// after since, or every bucket when since is nil. Buckets use the ride date,
+// which is already local to the ride's city.
+func (h *Handlers) refreshRideMetrics(since *time.Time) error {
+	var from interface{}
+	if since != nil {
+		from = since.Format("2006-01-02")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, metricsLockKey); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`DELETE FROM ride_metrics_daily WHERE $1::date IS NULL OR bucket_date >= $1::date`, from,
+	); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO ride_metrics_daily (
+		     bucket_date, city_id, corridor_id, rides_offered, rides_completed, rides_cancelled,
+		     seats_offered, seats_filled, requests_made, requests_accepted, requests_decided,
+		     response_seconds_total, revenue_settled, credits_issued, refreshed_at)
+		 SELECT r.ride_date, co.city_id, r.corridor_id,
+		        COUNT(*),
+		        COUNT(*) FILTER (WHERE r.status = 'completed'),
+		        COUNT(*) FILTER (WHERE r.status = 'cancelled'),
+		        COALESCE(SUM(r.available_seats + rq.seats_filled) FILTER (WHERE r.status <> 'cancelled'), 0),
+		        COALESCE(SUM(rq.seats_filled) FILTER (WHERE r.status <> 'cancelled'), 0),
+		        SUM(rq.made), SUM(rq.accepted), SUM(rq.decided), SUM(rq.response_seconds),
+		        SUM(p.settled), SUM(cc.credits),
+		        CURRENT_TIMESTAMP
+		 FROM rides r
+		 JOIN corridors co ON r.corridor_id = co.id
+		 CROSS JOIN LATERAL (
+		     SELECT COUNT(*) AS made,
+		            COUNT(*) FILTER (WHERE status = 'accepted') AS accepted,
+		            COUNT(*) FILTER (WHERE status <> 'pending') AS decided,
+		            COALESCE(SUM(EXTRACT(EPOCH FROM updated_at - created_at)::bigint)
+		                     FILTER (WHERE status <> 'pending'), 0) AS response_seconds,
+		            COALESCE(SUM(seats_requested) FILTER (WHERE status = 'accepted'), 0) AS seats_filled
+		     FROM ride_requests WHERE ride_id = r.id
+		 ) rq
+		 CROSS JOIN LATERAL (
+		     SELECT COALESCE(SUM(amount), 0) AS settled FROM payments
+		     WHERE ride_id = r.id AND rider_status = 'done' AND giver_status = 'received'
+		 ) p
+		 CROSS JOIN LATERAL (
+		     SELECT COALESCE(SUM(credits), 0) AS credits FROM carbon_credits WHERE ride_id = r.id
+		 ) cc
+		 WHERE $1::date IS NULL OR r.ride_date >= $1::date
+		 GROUP BY r.ride_date, co.city_id, r.corridor_id`,
+		from,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefreshAnalytics rebuilds the analytics rollup immediately (admin only)
+func (h *Handlers) RefreshAnalytics(c *gin.Context) error {
+	if err := h.refreshRideMetrics(nil); err != nil {
+		return apierr.Internal("Failed to refresh analytics", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Analytics refreshed"})
+	return nil
+}
+
+// GetAnalyticsTimeSeries returns day, week or month buckets of ride metrics
+// per city or corridor from the rollup table (admin only)
+func (h *Handlers) GetAnalyticsTimeSeries(c *gin.Context) error {
+	granularity := c.DefaultQuery("granularity", "day")
+	if granularity != "day" && granularity != "week" && granularity != "month" {
+		return apierr.BadRequest("granularity must be day, week or month")
+	}
+
+	groupBy := c.DefaultQuery("group_by", "corridor")
+	if groupBy != "city" && groupBy != "corridor" {
+		return apierr.BadRequest("group_by must be city or corridor")
+	}
+
+	// Default to the last 30 days
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			return apierr.BadRequest("Invalid from date, expected YYYY-MM-DD")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			return apierr.BadRequest("Invalid to date, expected YYYY-MM-DD")
+		}
+	}
+
+	where := ` WHERE m.bucket_date BETWEEN $2 AND $3`
+	args := []interface{}{granularity, from.Format("2006-01-02"), to.Format("2006-01-02")}
+	argIndex := 4
+
+	for _, filter := range []struct{ param, column string }{
+		{"city_id", "m.city_id"},
+		{"corridor_id", "m.corridor_id"},
+	} {
+		v := c.Query(filter.param)
+		if v == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(v); err != nil {
+			return apierr.BadRequest("Invalid " + filter.param)
+		}
+		where += ` AND ` + filter.column + ` = $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+
+	groupColumns := `m.city_id, ci.name, NULL::int, NULL::text`
+	if groupBy == "corridor" {
+		groupColumns = `m.city_id, ci.name, m.corridor_id, co.name`
+	}
+
+	rows, err := h.DB.Query(
+		`SELECT to_char(date_trunc($1, m.bucket_date::timestamp), 'YYYY-MM-DD'), `+groupColumns+`,
+		        SUM(m.rides_offered), SUM(m.rides_completed), SUM(m.rides_cancelled),
+		        SUM(m.seats_offered), SUM(m.seats_filled),
+		        SUM(m.requests_made), SUM(m.requests_accepted), SUM(m.requests_decided),
+		        SUM(m.response_seconds_total), SUM(m.revenue_settled), SUM(m.credits_issued)
+		 FROM ride_metrics_daily m
+		 JOIN cities ci ON m.city_id = ci.id
+		 JOIN corridors co ON m.corridor_id = co.id`+where+`
+		 GROUP BY 1, 2, 3, 4, 5
+		 ORDER BY 1, 3, 5`,
+		args...,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	buckets := []models.MetricsBucket{}
+	for rows.Next() {
+		var b models.MetricsBucket
+		var decided int
+		var responseSeconds int64
+		if err := rows.Scan(
+			&b.Bucket, &b.CityID, &b.CityName, &b.CorridorID, &b.CorridorName,
+			&b.RidesOffered, &b.RidesCompleted, &b.RidesCancelled,
+			&b.SeatsOffered, &b.SeatsFilled,
+			&b.RequestsMade, &b.RequestsAccepted, &decided,
+			&responseSeconds, &b.RevenueSettled, &b.CreditsIssued,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if b.SeatsOffered > 0 {
+			b.FillRate = float64(b.SeatsFilled) / float64(b.SeatsOffered)
+		}
+		if decided > 0 {
+			b.AcceptanceRate = float64(b.RequestsAccepted) / float64(decided)
+			avg := float64(responseSeconds) / float64(decided) / 60
+			b.AvgResponseMinutes = &avg
+		}
+		buckets = append(buckets, b)
+	}
+
+	var refreshedAt sql.NullTime
+	h.DB.QueryRow(`SELECT MAX(refreshed_at) FROM ride_metrics_daily`).Scan(&refreshedAt)
+
+	response := gin.H{
+		"granularity": granularity,
+		"group_by":    groupBy,
+		"from":        from.Format("2006-01-02"),
+		"to":          to.Format("2006-01-02"),
+		"buckets":     buckets,
+	}
+	if refreshedAt.Valid {
+		response["refreshed_at"] = refreshedAt.Time
+	}
+
+	c.JSON(http.StatusOK, response)
+	return nil
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditIgnoredFields are never written to the audit log
+var auditIgnoredFields = map[string]bool{
+	"password_hash": true,
+	"updated_at":    true,
+}
+
+// snapshot returns a row as JSON for audit before/after comparisons. The row
+// is read and locked under tx, so a before image can't go stale ahead of the
+// change it describes. table and where must be trusted SQL; values go through
+// args.
+func snapshot(tx *sql.Tx, table, where string, args ...interface{}) json.RawMessage {
+	var row []byte
+	err := tx.QueryRow(`SELECT row_to_json(t) FROM `+table+` t WHERE `+where+` FOR UPDATE`, args...).Scan(&row)
+	if err != nil {
+		return nil
+	}
+	return row
+}
+
+// auditData marshals an arbitrary value for use as an audit before/after payload
+func auditData(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// audit appends an entry to the audit log in tx, so it commits or rolls back
+// with the change it records. Only fields that differ between before and after
+// are stored. Callers fail the request when it errors: an action that can't be
+// audited doesn't happen.
+func audit(c *gin.Context, tx *sql.Tx, action, targetType string, targetID interface{}, before, after json.RawMessage) error {
+	actorID, _ := c.Get("user_id")
+	actorEmail, _ := c.Get("user_email")
+
+	before, after = diffSnapshots(before, after)
+
+	_, err := tx.Exec(
+		`INSERT INTO audit_log (actor_id, actor_email, action, target_type, target_id, before, after, ip_address)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		actorID, actorEmail, action, targetType, fmt.Sprint(targetID),
+		nullableJSON(before), nullableJSON(after), c.ClientIP(),
+	)
+	return err
+}
+
+// diffSnapshots reduces two JSON objects to the keys whose values changed
+func diffSnapshots(before, after json.RawMessage) (json.RawMessage, json.RawMessage) {
+	var b, a map[string]interface{}
+	json.Unmarshal(before, &b)
+	json.Unmarshal(after, &a)
+
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for k, v := range b {
+		if auditIgnoredFields[k] {
+			continue
+		}
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(v, av) {
+			changedBefore[k] = v
+		}
+	}
+	for k, v := range a {
+		if auditIgnoredFields[k] {
+			continue
+		}
+		if bv, ok := b[k]; !ok || !reflect.DeepEqual(v, bv) {
+			changedAfter[k] = v
+		}
+	}
+
+	var outBefore, outAfter json.RawMessage
+	if b != nil {
+		outBefore = auditData(changedBefore)
+	}
+	if a != nil {
+		outAfter = auditData(changedAfter)
+	}
+	return outBefore, outAfter
+}
+
+func nullableJSON(data json.RawMessage) interface{} {
+	if data == nil {
+		return nil
+	}
+	return []byte(data)
+}
+
+// auditFilters builds the WHERE clause shared by the audit list and export
+func auditFilters(c *gin.Context) (string, []interface{}, error) {
+	where := ` WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+
+	if v := c.Query("actor_id"); v != "" {
+		if _, err := strconv.Atoi(v); err != nil {
+			return "", nil, fmt.Errorf("Invalid actor_id")
+		}
+		where += ` AND actor_id = $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+	for _, field := range []string{"action", "target_type", "target_id"} {
+		if v := c.Query(field); v != "" {
+			where += ` AND ` + field + ` = $` + strconv.Itoa(argIndex)
+			args = append(args, v)
+			argIndex++
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid from date, expected YYYY-MM-DD")
+		}
+		where += ` AND created_at >= $` + strconv.Itoa(argIndex)
+		args = append(args, from)
+		argIndex++
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid to date, expected YYYY-MM-DD")
+		}
+		where += ` AND created_at < $` + strconv.Itoa(argIndex)
+		args = append(args, to.AddDate(0, 0, 1))
+		argIndex++
+	}
+
+	return where, args, nil
+}
+
+const auditColumns = `id, actor_id, actor_email, action, target_type, target_id, before, after, ip_address, created_at`
+
+func scanAuditEntry(scan func(...interface{}) error) (models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var before, after []byte
+	err := scan(
+		&entry.ID, &entry.ActorID, &entry.ActorEmail, &entry.Action, &entry.TargetType,
+		&entry.TargetID, &before, &after, &entry.IPAddress, &entry.CreatedAt,
+	)
+	if before != nil {
+		entry.Before = before
+	}
+	if after != nil {
+		entry.After = after
+	}
+	return entry, err
+}
+
+// auditSorts are the sort keys GetAuditLog accepts
+var auditSorts = listSorts{
+	"created_at": {"COALESCE(created_at, 'epoch')", "timestamp"},
+}
+
+// GetAuditLog returns a filtered page of the audit log, newest first by
+// default (admin only)
+func (h *Handlers) GetAuditLog(c *gin.Context) error {
+	p, err := parsePage(c, auditSorts, "-created_at", "id")
+	if err != nil {
+		return err
+	}
+
+	where, args, err := auditFilters(c)
+	if err != nil {
+		return apierr.BadRequest(err.Error())
+	}
+
+	after, afterArgs := p.where(len(args) + 1)
+	rows, err := h.DB.Query(
+		`SELECT `+auditColumns+p.sortColumn()+` FROM audit_log`+where+after+p.orderBy(),
+		append(args, afterArgs...)...,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var sortValue string
+		entry, err := scanAuditEntry(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &sortValue)...)
+		})
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, int(entry.ID)) {
+			break
+		}
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, auditLogPage{Entries: entries, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// ExportAuditLog returns the filtered audit log as CSV (admin only). The file
+// is built in full before anything is sent, so a failed read is an error
+// response rather than a truncated download.
+func (h *Handlers) ExportAuditLog(c *gin.Context) error {
+	where, args, err := auditFilters(c)
+	if err != nil {
+		return apierr.BadRequest(err.Error())
+	}
+
+	rows, err := h.DB.Query(`SELECT `+auditColumns+` FROM audit_log`+where+` ORDER BY created_at DESC, id DESC`, args...)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "created_at", "actor_id", "actor_email", "action", "target_type", "target_id", "before", "after", "ip_address"})
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows.Scan)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		actorID, actorEmail := "", ""
+		if entry.ActorID != nil {
+			actorID = strconv.Itoa(*entry.ActorID)
+		}
+		if entry.ActorEmail != nil {
+			actorEmail = *entry.ActorEmail
+		}
+		w.Write([]string{
+			strconv.FormatInt(entry.ID, 10), entry.CreatedAt.Format(time.RFC3339), actorID, actorEmail,
+			entry.Action, entry.TargetType, entry.TargetID, string(entry.Before), string(entry.After),
+			entry.IPAddress,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return apierr.Internal("Failed to write CSV", err)
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="audit-`+time.Now().Format("20060102-150405")+`.csv"`)
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+	return nil
+}
@@ -1,65 +1,68 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"cpool.ai/backend/internal/apierr"
 	"cpool.ai/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Register handles user registration
-func (h *Handlers) Register(c *gin.Context) {
-	var req struct {
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required,min=6"`
-		Name     string `json:"name" binding:"required"`
-		Phone    string `json:"phone"`
-		City     string `json:"city"`
-	}
+func (h *Handlers) Register(c *gin.Context) error {
+	var req registerRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
+	req.Email = normalizeEmail(req.Email)
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-		return
+		return apierr.Internal("Failed to hash password", err)
 	}
 
 	// Insert user
 	var userID int
-	var phone, city *string
+	var phone, city, gender *string
 	if req.Phone != "" {
 		phone = &req.Phone
 	}
 	if req.City != "" {
 		city = &req.City
 	}
+	if req.Gender != "" {
+		gender = &req.Gender
+	}
 
 	err = h.DB.QueryRow(
-		`INSERT INTO users (email, password_hash, name, phone, city, role) 
-		 VALUES ($1, $2, $3, $4, $5, 'user') RETURNING id`,
-		req.Email, hashedPassword, req.Name, phone, city,
+		`INSERT INTO users (email, password_hash, name, phone, city, gender, role) 
+		 VALUES ($1, $2, $3, $4, $5, $6, 'user') RETURNING id`,
+		req.Email, hashedPassword, req.Name, phone, city, gender,
 	).Scan(&userID)
 
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return apierr.Conflict(apierr.CodeEmailTaken, "Email already exists")
+	}
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-		return
+		return apierr.Internal("Failed to create user", err)
 	}
 
 	// Generate token
 	token, err := h.generateToken(userID, req.Email, "user")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+		return apierr.Internal("Failed to generate token", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -71,81 +74,184 @@ func (h *Handlers) Register(c *gin.Context) {
 			"role":  "user",
 		},
 	})
+	return nil
 }
 
 // Login handles user login
-func (h *Handlers) Login(c *gin.Context) {
-	var req struct {
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required"`
-	}
+func (h *Handlers) Login(c *gin.Context) error {
+	var req loginRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
+	}
+
+	// Refuse accounts locked by repeated failures, even with the right password
+	ctx := c.Request.Context()
+	email := normalizeEmail(req.Email)
+	locked, err := h.accountLockedFor(ctx, email)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if locked > 0 {
+		return accountLocked(locked)
 	}
 
 	// Get user
 	var user models.User
 	var passwordHash string
-	err := h.DB.QueryRow(
-		`SELECT id, email, password_hash, name, phone, city, role, carbon_credits, upi_id 
-		 FROM users WHERE email = $1`,
-		req.Email,
+	err = h.DB.QueryRow(
+		`SELECT id, email, password_hash, name, phone, city, gender, role, carbon_credits, upi_id 
+		 FROM users WHERE LOWER(email) = $1`,
+		email,
 	).Scan(
 		&user.ID, &user.Email, &passwordHash, &user.Name,
-		&user.Phone, &user.City, &user.Role, &user.CarbCredits, &user.UPIID,
+		&user.Phone, &user.City, &user.Gender, &user.Role, &user.CarbCredits, &user.UPIID,
 	)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
+		return h.loginFailed(ctx, email)
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		return
+		return h.loginFailed(ctx, email)
+	}
+	if err := h.clearLoginFailures(ctx, email); err != nil {
+		log.Printf("Failed to clear failed logins: %v", err)
 	}
 
 	// Generate token
 	token, err := h.generateToken(user.ID, user.Email, user.Role)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+		return apierr.Internal("Failed to generate token", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"token": token,
 		"user":  user,
 	})
+	return nil
+}
+
+// loginFailed records a failed login and returns the error to answer it
+// with: invalid credentials, or a lockout if this failure started one
+func (h *Handlers) loginFailed(ctx context.Context, email string) error {
+	locked, err := h.recordLoginFailure(ctx, email)
+	if err != nil {
+		log.Printf("Failed to record failed login: %v", err)
+	}
+	if locked > 0 {
+		return accountLocked(locked)
+	}
+	return apierr.New(http.StatusUnauthorized, apierr.CodeInvalidCredentials, "Invalid credentials")
+}
+
+func accountLocked(retryAfter time.Duration) error {
+	return apierr.TooManyRequests(apierr.CodeAccountLocked, "Too many failed logins, try again later", retryAfter)
 }
 
 // GetProfile returns current user profile
-func (h *Handlers) GetProfile(c *gin.Context) {
+func (h *Handlers) GetProfile(c *gin.Context) error {
 	userID, _ := c.Get("user_id")
 
 	var user models.User
 	err := h.DB.QueryRow(
-		`SELECT id, email, name, phone, city, role, carbon_credits, upi_id, created_at, updated_at 
-		 FROM users WHERE id = $1`,
+		`SELECT u.id, u.email, u.name, u.phone, u.city, u.gender, u.role, u.carbon_credits, u.upi_id,
+		        rt.average, rt.total, u.created_at, u.updated_at
+		 FROM users u
+		 CROSS JOIN LATERAL (`+ratingSubquery("u.id")+`) rt
+		 WHERE u.id = $1`,
 		userID,
 	).Scan(
-		&user.ID, &user.Email, &user.Name, &user.Phone, &user.City,
-		&user.Role, &user.CarbCredits, &user.UPIID, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.Name, &user.Phone, &user.City, &user.Gender,
+		&user.Role, &user.CarbCredits, &user.UPIID, &user.Rating, &user.ReviewCount,
+		&user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
+		return apierr.NotFound("User not found")
+	}
+
+	user.EmergencyContacts, err = h.emergencyContacts(userID)
+	if err != nil {
+		return apierr.Internal("Database error", err)
 	}
 
 	c.JSON(http.StatusOK, user)
+	return nil
+}
+
+// UpdateProfile updates the current user's own profile fields
+func (h *Handlers) UpdateProfile(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	var req updateProfileRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	updates := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			return apierr.BadRequest("Name can't be empty")
+		}
+		updates = append(updates, "name = $"+strconv.Itoa(argIndex))
+		args = append(args, strings.TrimSpace(*req.Name))
+		argIndex++
+	}
+	if req.Phone != nil {
+		updates = append(updates, "phone = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.Phone)
+		argIndex++
+	}
+	if req.City != nil {
+		updates = append(updates, "city = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.City)
+		argIndex++
+	}
+	if req.UPIID != nil {
+		updates = append(updates, "upi_id = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.UPIID)
+		argIndex++
+	}
+	if req.Gender != nil {
+		// An empty string clears the declaration
+		var gender *string
+		switch *req.Gender {
+		case "":
+		case "female", "male", "non_binary", "undisclosed":
+			gender = req.Gender
+		default:
+			return apierr.BadRequest("Invalid gender")
+		}
+		updates = append(updates, "gender = $"+strconv.Itoa(argIndex))
+		args = append(args, gender)
+		argIndex++
+	}
+
+	if len(updates) == 0 {
+		return apierr.BadRequest("No fields to update")
+	}
+
+	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, userID)
+
+	query := `UPDATE users SET ` + strings.Join(updates, ", ") + ` WHERE id = $` + strconv.Itoa(argIndex)
+
+	if _, err := h.DB.Exec(query, args...); err != nil {
+		return apierr.Internal("Failed to update profile", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Profile updated"})
+	return nil
 }
 
 // generateToken creates a JWT token
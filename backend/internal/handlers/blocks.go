@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notBlockedClause excludes rows whose userColumn has a block in either
+// direction with the user bound to placeholder
+func notBlockedClause(userColumn, placeholder string) string {
+	return ` AND NOT EXISTS (SELECT 1 FROM user_blocks ub
+	         WHERE (ub.blocker_id = ` + placeholder + ` AND ub.blocked_id = ` + userColumn + `)
+	            OR (ub.blocker_id = ` + userColumn + ` AND ub.blocked_id = ` + placeholder + `))`
+}
+
+// isBlocked reports whether either user has blocked the other
+func (h *Handlers) isBlocked(a, b interface{}) (bool, error) {
+	var blocked bool
+	err := h.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM user_blocks
+		               WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1))`,
+		a, b,
+	).Scan(&blocked)
+	return blocked, err
+}
+
+// GetBlockedUsers returns the current user's block list
+func (h *Handlers) GetBlockedUsers(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	rows, err := h.DB.Query(
+		`SELECT ub.blocked_id, u.name, ub.created_at
+		 FROM user_blocks ub
+		 JOIN users u ON ub.blocked_id = u.id
+		 WHERE ub.blocker_id = $1
+		 ORDER BY ub.created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	blocks := []models.BlockedUser{}
+	for rows.Next() {
+		var b models.BlockedUser
+		if err := rows.Scan(&b.UserID, &b.Name, &b.CreatedAt); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		blocks = append(blocks, b)
+	}
+
+	c.JSON(http.StatusOK, blocks)
+	return nil
+}
+
+// BlockUser adds a user to the current user's block list and withdraws
+// pending requests between the two on upcoming rides
+func (h *Handlers) BlockUser(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	var req blockUserRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	if req.UserID == userID.(int) {
+		return apierr.BadRequest("You can't block yourself")
+	}
+
+	var exists bool
+	if err := h.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, req.UserID).Scan(&exists); err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if !exists {
+		return apierr.NotFound("User not found")
+	}
+
+	_, err := h.DB.Exec(
+		`INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		userID, req.UserID,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to block user", err)
+	}
+
+	// Pending requests in either direction no longer make sense
+	h.DB.Exec(
+		`UPDATE ride_requests rr SET status = 'rejected', updated_at = CURRENT_TIMESTAMP
+		 FROM rides r
+		 WHERE rr.ride_id = r.id AND rr.status = 'pending'
+		   AND ((r.user_id = $1 AND rr.user_id = $2) OR (r.user_id = $2 AND rr.user_id = $1))`,
+		userID, req.UserID,
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+	return nil
+}
+
+// UnblockUser removes a user from the current user's block list
+func (h *Handlers) UnblockUser(c *gin.Context) error {
+	blockedID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		return apierr.BadRequest("Invalid user ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	result, err := h.DB.Exec(
+		`DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`,
+		userID, blockedID,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to unblock user", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("User is not blocked")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+	return nil
+}
@@ -1,60 +1,199 @@
 package handlers
 
 import (
-
+	"database/sql"
 	"net/http"
 	"strconv"
+	"time"
 
+	"cpool.ai/backend/internal/apierr"
 	"cpool.ai/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetCities returns all cities
-func (h *Handlers) GetCities(c *gin.Context) {
-	rows, err := h.DB.Query(`SELECT id, name, status, created_at, updated_at FROM cities ORDER BY name`)
+func (h *Handlers) GetCities(c *gin.Context) error {
+	rows, err := h.DB.Query(`SELECT id, name, status, timezone, currency, locale, launch_at, created_at, updated_at
+		 FROM cities ORDER BY name`)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var cities []models.City
+	cities := []models.City{}
 	for rows.Next() {
 		var city models.City
-		if err := rows.Scan(&city.ID, &city.Name, &city.Status, &city.CreatedAt, &city.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+		if err := rows.Scan(
+			&city.ID, &city.Name, &city.Status, &city.Timezone, &city.Currency,
+			&city.Locale, &city.LaunchAt, &city.CreatedAt, &city.UpdatedAt,
+		); err != nil {
+			return apierr.Internal("Database error", err)
 		}
 		cities = append(cities, city)
 	}
 
 	c.JSON(http.StatusOK, cities)
+	return nil
 }
 
-// UpdateCityStatus updates city status (admin only)
-func (h *Handlers) UpdateCityStatus(c *gin.Context) {
+// UpdateCityStatus updates city status (admin only).
+// Activating a city with a future launch_at schedules the launch instead.
+func (h *Handlers) UpdateCityStatus(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid city ID"})
-		return
+		return apierr.BadRequest("Invalid city ID")
 	}
 
-	var req struct {
-		Status string `json:"status" binding:"required,oneof=active locked"`
-	}
+	var req updateCityStatusRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
-	_, err = h.DB.Exec(`UPDATE cities SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, req.Status, id)
+	tx, err := h.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "cities", "id = $1", id)
+
+	if req.Status == "locked" {
+		result, err := tx.Exec(
+			`UPDATE cities SET status = 'locked', launch_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1`,
+			id,
+		)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return apierr.NotFound("City not found")
+		}
+		err = audit(c, tx, "city.lock", "city", id, before, snapshot(tx, "cities", "id = $1", id))
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "City status updated"})
+		return nil
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "City status updated"})
+	if req.LaunchAt != nil && req.LaunchAt.After(time.Now()) {
+		result, err := tx.Exec(
+			`UPDATE cities SET launch_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = 'locked'`,
+			*req.LaunchAt, id,
+		)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return apierr.NotFound("City not found or already active")
+		}
+		err = audit(c, tx, "city.schedule_launch", "city", id, before, snapshot(tx, "cities", "id = $1", id))
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "City launch scheduled", "launch_at": req.LaunchAt})
+		return nil
+	}
+
+	notified, err := launchCity(tx, id)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("City not found")
+	}
+	if err == nil {
+		err = audit(c, tx, "city.launch", "city", id, before, snapshot(tx, "cities", "id = $1", id))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "City status updated", "waitlist_notified": notified})
+	return nil
 }
 
+// UpdateCitySettings updates a city's timezone, currency and locale (admin only)
+func (h *Handlers) UpdateCitySettings(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid city ID")
+	}
+
+	var req updateCitySettingsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	updates := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Timezone != nil {
+		if _, err := loadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+			return apierr.BadRequest("Invalid timezone")
+		}
+		updates = append(updates, "timezone = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.Timezone)
+		argIndex++
+	}
+	if req.Currency != nil {
+		updates = append(updates, "currency = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.Currency)
+		argIndex++
+	}
+	if req.Locale != nil {
+		updates = append(updates, "locale = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.Locale)
+		argIndex++
+	}
+
+	if len(updates) == 0 {
+		return apierr.BadRequest("No fields to update")
+	}
+
+	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, id)
+
+	query := `UPDATE cities SET ` + updates[0]
+	for i := 1; i < len(updates); i++ {
+		query += `, ` + updates[i]
+	}
+	query += ` WHERE id = $` + strconv.Itoa(argIndex)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "cities", "id = $1", id)
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return apierr.Internal("Failed to update city", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("City not found")
+	}
+
+	err = audit(c, tx, "city.update", "city", id, before, snapshot(tx, "cities", "id = $1", id))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to update city", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "City updated"})
+	return nil
+}
@@ -5,20 +5,36 @@ import (
 	"net/http"
 	"strconv"
 
+	"cpool.ai/backend/internal/apierr"
 	"cpool.ai/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetCorridors returns all corridors (filtered by city if provided)
-func (h *Handlers) GetCorridors(c *gin.Context) {
+// corridorSorts are the sort keys GetCorridors accepts
+var corridorSorts = listSorts{
+	"name":       {"c.name", "text"},
+	"created_at": {"COALESCE(c.created_at, 'epoch')", "timestamp"},
+}
+
+// GetCorridors returns a page of corridors (filtered by city if provided),
+// by name by default
+func (h *Handlers) GetCorridors(c *gin.Context) error {
+	p, err := parsePage(c, corridorSorts, "name", "c.id")
+	if err != nil {
+		return err
+	}
+
 	cityID := c.Query("city_id")
 	activeOnly := c.Query("active") == "true"
+	if _, err := strconv.Atoi(cityID); cityID != "" && err != nil {
+		return apierr.BadRequest("Invalid city_id")
+	}
 
 	query := `
 		SELECT c.id, c.city_id, ci.name as city_name, c.name, c.location_from, 
 		       c.location_to, c.pickup_points, c.terms_conditions, c.is_active, 
-		       c.map_enabled, c.created_at, c.updated_at
+		       c.map_enabled, c.women_only_allowed, c.created_at, c.updated_at` + p.sortColumn() + `
 		FROM corridors c
 		JOIN cities ci ON c.city_id = ci.id
 		WHERE 1=1
@@ -36,46 +52,50 @@ func (h *Handlers) GetCorridors(c *gin.Context) {
 		query += ` AND c.is_active = true`
 	}
 
-	query += ` ORDER BY c.name`
+	after, afterArgs := p.where(argIndex)
+	query += after + p.orderBy()
 
-	rows, err := h.DB.Query(query, args...)
+	rows, err := h.DB.Query(query, append(args, afterArgs...)...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var corridors []models.Corridor
+	corridors := []models.Corridor{}
 	for rows.Next() {
 		var corridor models.Corridor
+		var sortValue string
 		if err := rows.Scan(
 			&corridor.ID, &corridor.CityID, &corridor.CityName, &corridor.Name,
 			&corridor.LocationFrom, &corridor.LocationTo, &corridor.PickupPoints,
-			&corridor.TermsConditions, &corridor.IsActive, &corridor.MapEnabled,
-			&corridor.CreatedAt, &corridor.UpdatedAt,
+			&corridor.TermsConditions, &corridor.IsActive, &corridor.MapEnabled, &corridor.WomenOnlyAllowed,
+			&corridor.CreatedAt, &corridor.UpdatedAt, &sortValue,
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, corridor.ID) {
+			break
 		}
+		h.gateCorridorFeatures(c, &corridor)
 		corridors = append(corridors, corridor)
 	}
 
-	c.JSON(http.StatusOK, corridors)
+	c.JSON(http.StatusOK, corridorsPage{Corridors: corridors, NextCursor: p.nextCursor()})
+	return nil
 }
 
 // GetCorridor returns a single corridor
-func (h *Handlers) GetCorridor(c *gin.Context) {
+func (h *Handlers) GetCorridor(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid corridor ID"})
-		return
+		return apierr.BadRequest("Invalid corridor ID")
 	}
 
 	var corridor models.Corridor
 	err = h.DB.QueryRow(
 		`SELECT c.id, c.city_id, ci.name as city_name, c.name, c.location_from, 
 		       c.location_to, c.pickup_points, c.terms_conditions, c.is_active, 
-		       c.map_enabled, c.created_at, c.updated_at
+		       c.map_enabled, c.women_only_allowed, c.created_at, c.updated_at
 		 FROM corridors c
 		 JOIN cities ci ON c.city_id = ci.id
 		 WHERE c.id = $1`,
@@ -83,78 +103,71 @@ func (h *Handlers) GetCorridor(c *gin.Context) {
 	).Scan(
 		&corridor.ID, &corridor.CityID, &corridor.CityName, &corridor.Name,
 		&corridor.LocationFrom, &corridor.LocationTo, &corridor.PickupPoints,
-		&corridor.TermsConditions, &corridor.IsActive, &corridor.MapEnabled,
+		&corridor.TermsConditions, &corridor.IsActive, &corridor.MapEnabled, &corridor.WomenOnlyAllowed,
 		&corridor.CreatedAt, &corridor.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Corridor not found"})
-		return
+		return apierr.NotFound("Corridor not found")
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 
+	h.gateCorridorFeatures(c, &corridor)
+
 	c.JSON(http.StatusOK, corridor)
+	return nil
 }
 
 // CreateCorridor creates a new corridor (admin only)
-func (h *Handlers) CreateCorridor(c *gin.Context) {
-	var req struct {
-		CityID          int    `json:"city_id" binding:"required"`
-		Name            string `json:"name" binding:"required"`
-		LocationFrom    string `json:"location_from" binding:"required"`
-		LocationTo      string `json:"location_to" binding:"required"`
-		PickupPoints    string `json:"pickup_points"`
-		TermsConditions string `json:"terms_conditions"`
-		IsActive        bool   `json:"is_active"`
-		MapEnabled      bool   `json:"map_enabled"`
-	}
+func (h *Handlers) CreateCorridor(c *gin.Context) error {
+	var req createCorridorRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
 	var corridorID int
-	err := h.DB.QueryRow(
+	err = tx.QueryRow(
 		`INSERT INTO corridors (city_id, name, location_from, location_to, pickup_points, 
-		                        terms_conditions, is_active, map_enabled)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		                        terms_conditions, is_active, map_enabled, women_only_allowed)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
 		req.CityID, req.Name, req.LocationFrom, req.LocationTo,
-		req.PickupPoints, req.TermsConditions, req.IsActive, req.MapEnabled,
+		req.PickupPoints, req.TermsConditions, req.IsActive, req.MapEnabled, req.WomenOnlyAllowed,
 	).Scan(&corridorID)
 
+	if err == nil {
+		err = audit(c, tx, "corridor.create", "corridor", corridorID, nil, snapshot(tx, "corridors", "id = $1", corridorID))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create corridor"})
-		return
+		return apierr.Internal("Failed to create corridor", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"id": corridorID, "message": "Corridor created"})
+	return nil
 }
 
 // UpdateCorridor updates a corridor (admin only)
-func (h *Handlers) UpdateCorridor(c *gin.Context) {
+func (h *Handlers) UpdateCorridor(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid corridor ID"})
-		return
+		return apierr.BadRequest("Invalid corridor ID")
 	}
 
-	var req struct {
-		Name            *string `json:"name"`
-		LocationFrom    *string `json:"location_from"`
-		LocationTo      *string `json:"location_to"`
-		PickupPoints    *string `json:"pickup_points"`
-		TermsConditions *string `json:"terms_conditions"`
-		IsActive        *bool   `json:"is_active"`
-		MapEnabled      *bool   `json:"map_enabled"`
-	}
+	var req updateCorridorRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
 	// Build update query dynamically
@@ -197,10 +210,14 @@ func (h *Handlers) UpdateCorridor(c *gin.Context) {
 		args = append(args, *req.MapEnabled)
 		argIndex++
 	}
+	if req.WomenOnlyAllowed != nil {
+		updates = append(updates, "women_only_allowed = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.WomenOnlyAllowed)
+		argIndex++
+	}
 
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
-		return
+		return apierr.BadRequest("No fields to update")
 	}
 
 	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
@@ -212,40 +229,67 @@ func (h *Handlers) UpdateCorridor(c *gin.Context) {
 	}
 	query += ` WHERE id = $` + strconv.Itoa(argIndex)
 
-	_, err = h.DB.Exec(query, args...)
+	tx, err := h.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update corridor"})
-		return
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "corridors", "id = $1", id)
+
+	_, err = tx.Exec(query, args...)
+	if err == nil {
+		err = audit(c, tx, "corridor.update", "corridor", id, before, snapshot(tx, "corridors", "id = $1", id))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to update corridor", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Corridor updated"})
+	return nil
 }
 
 // DeleteCorridor deletes a corridor (admin only)
-func (h *Handlers) DeleteCorridor(c *gin.Context) {
+func (h *Handlers) DeleteCorridor(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid corridor ID"})
-		return
+		return apierr.BadRequest("Invalid corridor ID")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
 	}
+	defer tx.Rollback()
 
-	_, err = h.DB.Exec(`DELETE FROM corridors WHERE id = $1`, id)
+	before := snapshot(tx, "corridors", "id = $1", id)
+
+	_, err = tx.Exec(`DELETE FROM corridors WHERE id = $1`, id)
+	if err == nil && before != nil {
+		err = audit(c, tx, "corridor.delete", "corridor", id, before, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete corridor"})
-		return
+		return apierr.Internal("Failed to delete corridor", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Corridor deleted"})
+	return nil
 }
 
 // GetUserCorridors returns corridors assigned to current user
-func (h *Handlers) GetUserCorridors(c *gin.Context) {
+func (h *Handlers) GetUserCorridors(c *gin.Context) error {
 	userID, _ := c.Get("user_id")
 
 	rows, err := h.DB.Query(
 		`SELECT c.id, c.city_id, ci.name as city_name, c.name, c.location_from, 
 		       c.location_to, c.pickup_points, c.terms_conditions, c.is_active, 
-		       c.map_enabled, c.created_at, c.updated_at
+		       c.map_enabled, c.women_only_allowed, c.created_at, c.updated_at
 		 FROM user_corridors uc
 		 JOIN corridors c ON uc.corridor_id = c.id
 		 JOIN cities ci ON c.city_id = ci.id
@@ -255,52 +299,60 @@ func (h *Handlers) GetUserCorridors(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var corridors []models.Corridor
+	corridors := []models.Corridor{}
 	for rows.Next() {
 		var corridor models.Corridor
 		if err := rows.Scan(
 			&corridor.ID, &corridor.CityID, &corridor.CityName, &corridor.Name,
 			&corridor.LocationFrom, &corridor.LocationTo, &corridor.PickupPoints,
-			&corridor.TermsConditions, &corridor.IsActive, &corridor.MapEnabled,
+			&corridor.TermsConditions, &corridor.IsActive, &corridor.MapEnabled, &corridor.WomenOnlyAllowed,
 			&corridor.CreatedAt, &corridor.UpdatedAt,
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+			return apierr.Internal("Database error", err)
 		}
+		h.gateCorridorFeatures(c, &corridor)
 		corridors = append(corridors, corridor)
 	}
 
 	c.JSON(http.StatusOK, corridors)
+	return nil
 }
 
 // AssignCorridor assigns a corridor to a user (admin only)
-func (h *Handlers) AssignCorridor(c *gin.Context) {
-	var req struct {
-		UserID    int `json:"user_id" binding:"required"`
-		CorridorID int `json:"corridor_id" binding:"required"`
-	}
+func (h *Handlers) AssignCorridor(c *gin.Context) error {
+	var req assignCorridorRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
-	_, err := h.DB.Exec(
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
 		`INSERT INTO user_corridors (user_id, corridor_id) VALUES ($1, $2)
 		 ON CONFLICT (user_id, corridor_id) DO NOTHING`,
 		req.UserID, req.CorridorID,
 	)
 
+	if err == nil {
+		err = audit(c, tx, "corridor.assign", "user", req.UserID, nil, auditData(gin.H{"corridor_id": req.CorridorID}))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign corridor"})
-		return
+		return apierr.Internal("Failed to assign corridor", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Corridor assigned"})
+	return nil
 }
 
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxEmergencyContacts caps how many contacts a user can register
+const maxEmergencyContacts = 5
+
+// emergencyContacts returns a user's emergency contacts
+func (h *Handlers) emergencyContacts(userID interface{}) ([]models.EmergencyContact, error) {
+	rows, err := h.DB.Query(
+		`SELECT id, name, phone, email, relationship, created_at
+		 FROM emergency_contacts WHERE user_id = $1 ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []models.EmergencyContact{}
+	for rows.Next() {
+		var ec models.EmergencyContact
+		if err := rows.Scan(&ec.ID, &ec.Name, &ec.Phone, &ec.Email, &ec.Relationship, &ec.CreatedAt); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, ec)
+	}
+	return contacts, rows.Err()
+}
+
+// GetEmergencyContacts returns the current user's emergency contacts
+func (h *Handlers) GetEmergencyContacts(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	contacts, err := h.emergencyContacts(userID)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	c.JSON(http.StatusOK, contacts)
+	return nil
+}
+
+// CreateEmergencyContact adds an emergency contact for the current user
+func (h *Handlers) CreateEmergencyContact(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	var req createEmergencyContactRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	phone := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '+' {
+			return r
+		}
+		return -1
+	}, req.Phone)
+	if len(phone) < 8 || len(phone) > 16 {
+		return apierr.BadRequest("Invalid phone number")
+	}
+
+	var email, relationship *string
+	if req.Email != "" {
+		email = &req.Email
+	}
+	if req.Relationship != "" {
+		relationship = &req.Relationship
+	}
+
+	// Insert only while the user is under the cap
+	var contactID int
+	err := h.DB.QueryRow(
+		`INSERT INTO emergency_contacts (user_id, name, phone, email, relationship)
+		 SELECT $1, $2, $3, $4, $5
+		 WHERE (SELECT COUNT(*) FROM emergency_contacts WHERE user_id = $1) < $6
+		 RETURNING id`,
+		userID, strings.TrimSpace(req.Name), phone, email, relationship, maxEmergencyContacts,
+	).Scan(&contactID)
+
+	if err == sql.ErrNoRows {
+		return apierr.BadRequest("You can have at most " + strconv.Itoa(maxEmergencyContacts) + " emergency contacts")
+	}
+	if err != nil {
+		return apierr.Internal("Failed to add emergency contact", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": contactID, "message": "Emergency contact added"})
+	return nil
+}
+
+// DeleteEmergencyContact removes one of the current user's emergency contacts
+func (h *Handlers) DeleteEmergencyContact(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("contactId"))
+	if err != nil {
+		return apierr.BadRequest("Invalid contact ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	result, err := h.DB.Exec(`DELETE FROM emergency_contacts WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return apierr.Internal("Failed to delete emergency contact", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Emergency contact not found")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Emergency contact deleted"})
+	return nil
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/flags"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// flagSubject describes the current user for feature flag evaluation.
+// The city defaults to the user's profile city and can be overridden with
+// city_id; corridor_id scopes evaluation to a corridor.
+func (h *Handlers) flagSubject(c *gin.Context) flags.Subject {
+	if cached, ok := c.Get("flag_subject"); ok {
+		return cached.(flags.Subject)
+	}
+
+	var subject flags.Subject
+	if v, ok := c.Get("user_id"); ok {
+		subject.UserID, _ = v.(int)
+	}
+	if v, ok := c.Get("user_role"); ok {
+		subject.Role, _ = v.(string)
+	}
+	if v, ok := c.Get("user_email"); ok {
+		if email, _ := v.(string); strings.Contains(email, "@") {
+			subject.Org = strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+		}
+	}
+
+	if cityID, err := strconv.Atoi(c.Query("city_id")); err == nil {
+		subject.CityID = cityID
+	} else if subject.UserID != 0 {
+		h.DB.QueryRow(
+			`SELECT ci.id FROM users u JOIN cities ci ON LOWER(ci.name) = LOWER(u.city) WHERE u.id = $1`,
+			subject.UserID,
+		).Scan(&subject.CityID)
+	}
+	if corridorID, err := strconv.Atoi(c.Query("corridor_id")); err == nil {
+		subject.CorridorID = corridorID
+	}
+
+	c.Set("flag_subject", subject)
+	return subject
+}
+
+// featureEnabled reports whether a flag is on for the current request
+func (h *Handlers) featureEnabled(c *gin.Context, name string) bool {
+	return h.Flags.Enabled(name, h.flagSubject(c))
+}
+
+// corridorFeatureEnabled evaluates a flag for the current user within a specific corridor
+func (h *Handlers) corridorFeatureEnabled(c *gin.Context, name string, corridor *models.Corridor) bool {
+	subject := h.flagSubject(c)
+	subject.CityID = corridor.CityID
+	subject.CorridorID = corridor.ID
+	return h.Flags.Enabled(name, subject)
+}
+
+// GetFeatures returns every flag evaluated for the current user
+func (h *Handlers) GetFeatures(c *gin.Context) error {
+	c.JSON(http.StatusOK, h.Flags.Evaluate(h.flagSubject(c)))
+	return nil
+}
+
+// ListFeatures returns all flag definitions (admin only)
+func (h *Handlers) ListFeatures(c *gin.Context) error {
+	rows, err := h.DB.Query(
+		`SELECT id, name, enabled, description, rollout_percentage, targeting, created_at, updated_at
+		 FROM feature_flags ORDER BY name`,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	features := []models.FeatureFlag{}
+	for rows.Next() {
+		var f models.FeatureFlag
+		var targeting []byte
+		if err := rows.Scan(
+			&f.ID, &f.Name, &f.Enabled, &f.Description, &f.RolloutPercentage,
+			&targeting, &f.CreatedAt, &f.UpdatedAt,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		f.Targeting = targeting
+		features = append(features, f)
+	}
+
+	c.JSON(http.StatusOK, features)
+	return nil
+}
+
+// CreateFeature creates a new feature flag (admin only)
+func (h *Handlers) CreateFeature(c *gin.Context) error {
+	var req createFeatureRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	rollout := 100
+	if req.RolloutPercentage != nil {
+		rollout = *req.RolloutPercentage
+	}
+	targeting := flags.Targeting{}
+	if req.Targeting != nil {
+		targeting = *req.Targeting
+	}
+	var description *string
+	if req.Description != "" {
+		description = &req.Description
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(
+		`INSERT INTO feature_flags (name, enabled, description, rollout_percentage, targeting)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		req.Name, req.Enabled, description, rollout, []byte(auditData(targeting)),
+	).Scan(&id)
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return apierr.Conflict(apierr.CodeFeatureExists, "Feature already exists")
+	}
+	if err == nil {
+		err = audit(c, tx, "feature.create", "feature_flag", req.Name, nil, snapshot(tx, "feature_flags", "name = $1", req.Name))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to create feature", err)
+	}
+
+	h.reloadFlags()
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "message": "Feature created"})
+	return nil
+}
+
+// ToggleFeature updates a feature flag's state, rollout and targeting (admin only)
+func (h *Handlers) ToggleFeature(c *gin.Context) error {
+	featureName := c.Param("name")
+
+	var req toggleFeatureRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	updates := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Enabled != nil {
+		updates = append(updates, "enabled = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.Enabled)
+		argIndex++
+	}
+	if req.Description != nil {
+		updates = append(updates, "description = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.Description)
+		argIndex++
+	}
+	if req.RolloutPercentage != nil {
+		updates = append(updates, "rollout_percentage = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.RolloutPercentage)
+		argIndex++
+	}
+	if req.Targeting != nil {
+		updates = append(updates, "targeting = $"+strconv.Itoa(argIndex))
+		args = append(args, []byte(auditData(req.Targeting)))
+		argIndex++
+	}
+
+	if len(updates) == 0 {
+		return apierr.BadRequest("No fields to update")
+	}
+
+	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, featureName)
+
+	query := `UPDATE feature_flags SET ` + strings.Join(updates, ", ") + ` WHERE name = $` + strconv.Itoa(argIndex)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "feature_flags", "name = $1", featureName)
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return apierr.Internal("Failed to toggle feature", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Feature not found")
+	}
+
+	err = audit(c, tx, "feature.toggle", "feature_flag", featureName, before, snapshot(tx, "feature_flags", "name = $1", featureName))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to toggle feature", err)
+	}
+
+	h.reloadFlags()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Feature toggled"})
+	return nil
+}
+
+// DeleteFeature removes a feature flag (admin only)
+func (h *Handlers) DeleteFeature(c *gin.Context) error {
+	featureName := c.Param("name")
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "feature_flags", "name = $1", featureName)
+
+	result, err := tx.Exec(`DELETE FROM feature_flags WHERE name = $1`, featureName)
+	if err != nil {
+		return apierr.Internal("Failed to delete feature", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Feature not found")
+	}
+
+	err = audit(c, tx, "feature.delete", "feature_flag", featureName, before, nil)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to delete feature", err)
+	}
+
+	h.reloadFlags()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Feature deleted"})
+	return nil
+}
+
+// reloadFlags refreshes this replica's cache immediately; others follow via NOTIFY
+func (h *Handlers) reloadFlags() {
+	if err := h.Flags.Load(); err != nil {
+		log.Println(err)
+	}
+}
+
+// gateCorridorFeatures applies server-side feature gates to corridor fields
+func (h *Handlers) gateCorridorFeatures(c *gin.Context, corridor *models.Corridor) {
+	corridor.MapEnabled = corridor.MapEnabled && h.corridorFeatureEnabled(c, "maps_enabled", corridor)
+}
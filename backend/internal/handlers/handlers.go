@@ -2,6 +2,12 @@ package handlers
 
 import (
 	"cpool.ai/backend/internal/config"
+	"cpool.ai/backend/internal/events"
+	"cpool.ai/backend/internal/flags"
+	"cpool.ai/backend/internal/notify"
+	"cpool.ai/backend/internal/ratelimit"
+	"cpool.ai/backend/internal/storage"
+	"cpool.ai/backend/internal/webhooks"
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
@@ -9,15 +15,27 @@ import (
 
 // Handlers holds all handler dependencies
 type Handlers struct {
-	DB     *sql.DB
-	Config *config.Config
+	DB       *sql.DB
+	Config   *config.Config
+	Store    storage.BlobStore
+	Flags    *flags.Service
+	Notifier *notify.Service
+	Events   *events.Bus
+	Webhooks *webhooks.Service
+	Limiter  ratelimit.Limiter
 }
 
 // New creates a new Handlers instance
-func New(db *sql.DB, cfg *config.Config) *Handlers {
+func New(db *sql.DB, cfg *config.Config, store storage.BlobStore, flagService *flags.Service, notifier *notify.Service, bus *events.Bus, hooks *webhooks.Service, limiter ratelimit.Limiter) *Handlers {
 	return &Handlers{
-		DB:     db,
-		Config: cfg,
+		DB:       db,
+		Config:   cfg,
+		Store:    store,
+		Flags:    flagService,
+		Notifier: notifier,
+		Events:   bus,
+		Webhooks: hooks,
+		Limiter:  limiter,
 	}
 }
 
@@ -29,3 +47,14 @@ func HealthCheck(c *gin.Context) {
 	})
 }
 
+
+// handle adapts a handler that returns an error to gin. The error is
+// recorded on the context for middleware.ErrorMiddleware to write.
+func handle(fn func(c *gin.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := fn(c); err != nil {
+			c.Error(err)
+			c.Abort()
+		}
+	}
+}
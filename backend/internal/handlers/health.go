@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"cpool.ai/backend/internal/db"
+	"cpool.ai/backend/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// readyTimeout bounds each readiness check so a hung database fails
+	// the probe instead of stalling it
+	readyTimeout = 2 * time.Second
+	// jobsStaleAfter is how long the job runner may go without a
+	// successful poll before the replica is reported not ready
+	jobsStaleAfter = time.Minute
+)
+
+// Probes serves the liveness and readiness endpoints orchestrators poll.
+// They are mounted outside /api so they bypass auth and the API contract.
+type Probes struct {
+	DB     *sql.DB
+	Runner *jobs.Runner
+
+	draining atomic.Bool
+}
+
+// Drain makes readiness fail from now on, so traffic moves elsewhere
+// before the server shuts down
+func (p *Probes) Drain() {
+	p.draining.Store(true)
+}
+
+// Healthz reports that the process is up and serving. It doesn't touch
+// dependencies, so an outage elsewhere doesn't get the process restarted.
+func (p *Probes) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports whether this replica can serve traffic: the database
+// answers, its schema is at least as new as this build's, and the job
+// runner is polling. It returns 503 with the failing checks otherwise.
+func (p *Probes) Readyz(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	if p.draining.Load() {
+		check("shutdown", fmt.Errorf("draining"))
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+	dbErr := p.DB.PingContext(ctx)
+	check("database", dbErr)
+	if dbErr == nil {
+		check("migrations", p.checkMigrations(ctx))
+	}
+	check("jobs", p.checkJobs())
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+func (p *Probes) checkMigrations(ctx context.Context) error {
+	version, err := db.CurrentVersion(ctx, p.DB)
+	if err != nil {
+		return err
+	}
+	if want := db.SchemaVersion(); version < want {
+		return fmt.Errorf("schema version %d, want %d", version, want)
+	}
+	return nil
+}
+
+func (p *Probes) checkJobs() error {
+	if p.Runner == nil {
+		return nil
+	}
+	started, lastPoll, err := p.Runner.Status()
+	switch {
+	case !started:
+		return fmt.Errorf("not started")
+	case err != nil:
+		return fmt.Errorf("last poll failed: %v", err)
+	case time.Since(lastPoll) > jobsStaleAfter:
+		return fmt.Errorf("no successful poll since %s", lastPoll.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/events"
+	"cpool.ai/backend/internal/jobs"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reminderLead is how long before departure drivers and riders are reminded
+const reminderLead = time.Hour
+
+// RegisterJobs registers the backend's scheduled jobs with runner
+func (h *Handlers) RegisterJobs(runner *jobs.Runner) error {
+	for _, job := range []jobs.Job{
+		{
+			Name:     "launch_cities",
+			Schedule: jobs.MustParseSchedule("* * * * *"),
+			Run:      h.launchDueCities,
+		},
+		{
+			Name:     "document_expiry",
+			Schedule: jobs.MustParseSchedule("@hourly"),
+			Run:      h.processDocumentExpiry,
+		},
+		{
+			Name:     "refresh_ride_metrics",
+			Schedule: jobs.MustParseSchedule("*/15 * * * *"),
+			Run: func(ctx context.Context) error {
+				since := time.Now().AddDate(0, 0, -metricsRefreshDays)
+				return h.refreshRideMetrics(&since)
+			},
+		},
+		{
+			Name:       "rebuild_ride_metrics",
+			Schedule:   jobs.MustParseSchedule("0 3 * * *"),
+			Run:        func(ctx context.Context) error { return h.refreshRideMetrics(nil) },
+			Timeout:    30 * time.Minute,
+			RunOnStart: true,
+		},
+		{
+			Name:     "close_stale_rides",
+			Schedule: jobs.MustParseSchedule("*/10 * * * *"),
+			Run:      h.runCloseStaleRides,
+		},
+		{
+			Name:     "expire_ride_requests",
+			Schedule: jobs.MustParseSchedule("*/5 * * * *"),
+			Run:      h.expireRideRequests,
+		},
+		{
+			Name:     "departure_reminders",
+			Schedule: jobs.MustParseSchedule("*/5 * * * *"),
+			Run:      h.sendDepartureReminders,
+		},
+		{
+			Name:     "prune_job_history",
+			Schedule: jobs.MustParseSchedule("0 4 * * *"),
+			Run:      runner.PruneHistory,
+		},
+		{
+			Name:     "prune_outbox",
+			Schedule: jobs.MustParseSchedule("30 4 * * *"),
+			Run:      h.Events.Prune,
+		},
+		{
+			Name:     "prune_webhook_deliveries",
+			Schedule: jobs.MustParseSchedule("45 4 * * *"),
+			Run:      h.Webhooks.PruneDeliveries,
+		},
+		{
+			Name:     "prune_rate_limits",
+			Schedule: jobs.MustParseSchedule("@hourly"),
+			Run:      h.pruneRateLimits,
+		},
+	} {
+		if err := runner.Register(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCloseStaleRides completes or cancels rides left open after their date
+func (h *Handlers) runCloseStaleRides(ctx context.Context) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	completed, cancelled, err := closeStaleRides(tx, nil)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	if len(completed)+len(cancelled) > 0 {
+		log.Printf("Closed stale rides: %d completed, %d cancelled", len(completed), len(cancelled))
+	}
+	return nil
+}
+
+// expireRideRequests rejects requests still pending when their ride departs.
+// Riders hear about it through the RequestRejected events published here.
+func (h *Handlers) expireRideRequests(ctx context.Context) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`UPDATE ride_requests rr SET status = 'rejected', updated_at = CURRENT_TIMESTAMP
+		 FROM rides r
+		 JOIN corridors co ON r.corridor_id = co.id
+		 JOIN cities ci ON co.city_id = ci.id
+		 WHERE rr.ride_id = r.id AND rr.status = 'pending'
+		   AND (r.ride_date + r.ride_time) AT TIME ZONE ci.timezone <= CURRENT_TIMESTAMP
+		 RETURNING rr.id, rr.ride_id, rr.user_id, r.user_id, rr.seats_requested`,
+	)
+	if err != nil {
+		return err
+	}
+
+	var expired []events.RequestPayload
+	for rows.Next() {
+		p := events.RequestPayload{Reason: events.ReasonExpired}
+		if err := rows.Scan(&p.RequestID, &p.RideID, &p.RiderID, &p.DriverID, &p.Seats); err != nil {
+			rows.Close()
+			return err
+		}
+		expired = append(expired, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range expired {
+		if err := events.Publish(tx, events.RequestRejected, p); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// sendDepartureReminders publishes RideDeparting for rides departing within
+// reminderLead, from which the driver and accepted riders are reminded. Each
+// ride is marked in the same transaction, so it is reminded once.
+func (h *Handlers) sendDepartureReminders(ctx context.Context) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`UPDATE rides r SET reminder_sent_at = CURRENT_TIMESTAMP
+		 FROM corridors co
+		 JOIN cities ci ON co.city_id = ci.id
+		 WHERE r.corridor_id = co.id AND r.reminder_sent_at IS NULL
+		   AND r.status IN ('open', 'partially_filled', 'full')
+		   AND (r.ride_date + r.ride_time) AT TIME ZONE ci.timezone
+		       BETWEEN CURRENT_TIMESTAMP AND CURRENT_TIMESTAMP + $1::int * INTERVAL '1 minute'
+		 RETURNING r.id, r.user_id, to_char(r.ride_date, 'YYYY-MM-DD')`,
+		int(reminderLead.Minutes()),
+	)
+	if err != nil {
+		return err
+	}
+
+	var departing []events.RidePayload
+	for rows.Next() {
+		var p events.RidePayload
+		if err := rows.Scan(&p.RideID, &p.DriverID, &p.RideDate); err != nil {
+			rows.Close()
+			return err
+		}
+		departing = append(departing, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range departing {
+		if p.RiderIDs, err = rideRiders(tx, p.RideID, "accepted"); err != nil {
+			return err
+		}
+		if err := events.Publish(tx, events.RideDeparting, p); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetJobs lists scheduled jobs with their next run and latest outcome (admin only)
+func (h *Handlers) GetJobs(c *gin.Context) error {
+	rows, err := h.DB.Query(
+		`SELECT name, schedule, next_run_at, attempt,
+		        COALESCE(locked_until > CURRENT_TIMESTAMP, false), locked_by,
+		        last_run_at, last_status, last_error
+		 FROM scheduled_jobs ORDER BY name`,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	result := []models.ScheduledJob{}
+	for rows.Next() {
+		var j models.ScheduledJob
+		if err := rows.Scan(
+			&j.Name, &j.Schedule, &j.NextRunAt, &j.Attempt, &j.Running, &j.LockedBy,
+			&j.LastRunAt, &j.LastStatus, &j.LastError,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !j.Running {
+			j.LockedBy = nil
+		}
+		result = append(result, j)
+	}
+
+	c.JSON(http.StatusOK, result)
+	return nil
+}
+
+// jobRunSorts are the sort keys GetJobRuns accepts
+var jobRunSorts = listSorts{
+	"started_at": {"started_at", "timestamptz"},
+}
+
+// GetJobRuns returns a page of job run history, newest first by default
+// (admin only)
+func (h *Handlers) GetJobRuns(c *gin.Context) error {
+	p, err := parsePage(c, jobRunSorts, "-started_at", "id")
+	if err != nil {
+		return err
+	}
+
+	where := ` WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+
+	if v := c.Query("job"); v != "" {
+		where += ` AND job_name = $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+	if v := c.Query("status"); v != "" {
+		switch v {
+		case "running", "succeeded", "failed":
+		default:
+			return apierr.BadRequest("status must be one of running, succeeded, failed")
+		}
+		where += ` AND status = $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+
+	after, afterArgs := p.where(argIndex)
+	rows, err := h.DB.Query(
+		`SELECT id, job_name, attempt, runner, status, error, started_at, finished_at, duration_ms`+p.sortColumn()+`
+		 FROM job_runs`+where+after+p.orderBy(),
+		append(args, afterArgs...)...,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	runs := []models.JobRun{}
+	for rows.Next() {
+		var r models.JobRun
+		var sortValue string
+		if err := rows.Scan(
+			&r.ID, &r.JobName, &r.Attempt, &r.Runner, &r.Status, &r.Error,
+			&r.StartedAt, &r.FinishedAt, &r.DurationMs, &sortValue,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, r.ID) {
+			break
+		}
+		runs = append(runs, r)
+	}
+
+	c.JSON(http.StatusOK, jobRunsPage{Runs: runs, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// RunJob makes a job due immediately; a runner picks it up on its next poll (admin only)
+func (h *Handlers) RunJob(c *gin.Context) error {
+	name := c.Param("name")
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	var nextRunAt time.Time
+	err = tx.QueryRow(
+		`UPDATE scheduled_jobs SET next_run_at = CURRENT_TIMESTAMP WHERE name = $1 RETURNING next_run_at`,
+		name,
+	).Scan(&nextRunAt)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Job not found")
+	}
+	if err == nil {
+		err = audit(c, tx, "job.run", "job", name, nil, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to schedule job", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job scheduled", "next_run_at": nextRunAt})
+	return nil
+}
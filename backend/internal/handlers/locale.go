@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// defaultTimezone is used when a city has no timezone configured
+const defaultTimezone = "Asia/Kolkata"
+
+// bookingWindowDays is how many days ahead of today a ride may be offered
+const bookingWindowDays = 2
+
+var locationCache sync.Map
+
+// loadLocation returns the IANA location for a timezone name, caching lookups
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name = defaultTimezone
+	}
+	if loc, ok := locationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+// cityLocation returns the local timezone of a city
+func (h *Handlers) cityLocation(cityID int) (*time.Location, error) {
+	var tz string
+	if err := h.DB.QueryRow(`SELECT timezone FROM cities WHERE id = $1`, cityID).Scan(&tz); err != nil {
+		return nil, err
+	}
+	return loadLocation(tz)
+}
+
+// corridorLocation returns the local timezone of the city a corridor belongs to
+func (h *Handlers) corridorLocation(corridorID int) (*time.Location, error) {
+	var tz string
+	err := h.DB.QueryRow(
+		`SELECT ci.timezone FROM corridors c JOIN cities ci ON c.city_id = ci.id WHERE c.id = $1`,
+		corridorID,
+	).Scan(&tz)
+	if err != nil {
+		return nil, err
+	}
+	return loadLocation(tz)
+}
+
+// localToday returns midnight of the current day in loc
+func localToday(loc *time.Location) time.Time {
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+}
+
+// parseRideDeparture combines a YYYY-MM-DD date and HH:MM time in the city's local time
+func parseRideDeparture(loc *time.Location, date, clock string) (time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid date format")
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid time format, expected HH:MM")
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, loc), nil
+}
+
+// validateRideSchedule checks that a departure falls within the booking window
+// and has not already passed, both measured in the city's local time
+func validateRideSchedule(loc *time.Location, departure time.Time) error {
+	today := localToday(loc)
+	day := time.Date(departure.Year(), departure.Month(), departure.Day(), 0, 0, 0, 0, loc)
+	if day.Before(today) || day.After(today.AddDate(0, 0, bookingWindowDays)) {
+		return errors.New("Ride date must be today or within next 2 days")
+	}
+	if departure.Before(time.Now()) {
+		return errors.New("Ride time has already passed")
+	}
+	return nil
+}
@@ -1,82 +1,113 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/events"
 	"cpool.ai/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetMessages returns messages for a ride
-func (h *Handlers) GetMessages(c *gin.Context) {
+// messageSorts are the sort keys GetMessages accepts
+var messageSorts = listSorts{
+	"created_at": {"COALESCE(m.created_at, 'epoch')", "timestamp"},
+}
+
+// GetMessages returns a page of a ride's messages, oldest first by default
+func (h *Handlers) GetMessages(c *gin.Context) error {
 	rideID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	p, err := parsePage(c, messageSorts, "created_at", "m.id")
+	if err != nil {
+		return err
+	}
+
+	userID, _ := c.Get("user_id")
+	visible, err := h.rideVisible(rideID, userID)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if !visible {
+		return apierr.NotFound("Ride not found")
 	}
 
 	// Get last message ID for polling (optional query param)
 	lastID := c.Query("last_id")
 
 	query := `
-		SELECT m.id, m.ride_id, m.user_id, u.name as user_name, m.message, m.created_at
+		SELECT m.id, m.ride_id, m.user_id, u.name as user_name, m.message, m.created_at` + p.sortColumn() + `
 		FROM messages m
 		JOIN users u ON m.user_id = u.id
 		WHERE m.ride_id = $1
 	`
 
-	args := []interface{}{rideID}
+	// Hide messages from users the viewer has blocked
+	query += ` AND NOT EXISTS (SELECT 1 FROM user_blocks ub WHERE ub.blocker_id = $2 AND ub.blocked_id = m.user_id)`
+
+	args := []interface{}{rideID, userID}
 	if lastID != "" {
-		query += ` AND m.id > $2`
+		if _, err := strconv.Atoi(lastID); err != nil {
+			return apierr.BadRequest("Invalid last_id")
+		}
+		query += ` AND m.id > $3`
 		args = append(args, lastID)
 	}
 
-	query += ` ORDER BY m.created_at ASC`
+	after, afterArgs := p.where(len(args) + 1)
+	query += after + p.orderBy()
+	args = append(args, afterArgs...)
 
 	rows, err := h.DB.Query(query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var messages []models.Message
+	messages := []models.Message{}
 	for rows.Next() {
 		var msg models.Message
+		var sortValue string
 		if err := rows.Scan(
-			&msg.ID, &msg.RideID, &msg.UserID, &msg.UserName, &msg.Message, &msg.CreatedAt,
+			&msg.ID, &msg.RideID, &msg.UserID, &msg.UserName, &msg.Message, &msg.CreatedAt, &sortValue,
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, msg.ID) {
+			break
 		}
 		messages = append(messages, msg)
 	}
 
-	c.JSON(http.StatusOK, messages)
+	c.JSON(http.StatusOK, messagesPage{Messages: messages, NextCursor: p.nextCursor()})
+	return nil
 }
 
 // CreateMessage creates a new message
-func (h *Handlers) CreateMessage(c *gin.Context) {
+func (h *Handlers) CreateMessage(c *gin.Context) error {
 	rideID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
 	}
 
 	userID, _ := c.Get("user_id")
 
-	var req struct {
-		Message string `json:"message" binding:"required"`
-	}
+	var req createMessageRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
-	// Verify user is part of the ride (either giver or requester)
+	// Verify user is part of the ride (either giver or requester). Only
+	// women are accepted onto women-only rides, so this also covers their
+	// visibility.
 	var isParticipant bool
 	err = h.DB.QueryRow(
 		`SELECT EXISTS(
@@ -88,21 +119,92 @@ func (h *Handlers) CreateMessage(c *gin.Context) {
 	).Scan(&isParticipant)
 
 	if err != nil || !isParticipant {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not part of this ride"})
-		return
+		return apierr.Forbidden("You are not part of this ride")
 	}
 
-	var messageID int
+	// Blocked users can't message each other through a shared ride
+	var blocked bool
 	err = h.DB.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM user_blocks ub
+			JOIN rides r ON r.id = $1
+			WHERE (ub.blocker_id = r.user_id AND ub.blocked_id = $2)
+			   OR (ub.blocker_id = $2 AND ub.blocked_id = r.user_id)
+		)`,
+		rideID, userID,
+	).Scan(&blocked)
+
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if blocked {
+		return apierr.Forbidden("You can't message this ride")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	var messageID int
+	err = tx.QueryRow(
 		`INSERT INTO messages (ride_id, user_id, message) VALUES ($1, $2, $3) RETURNING id`,
 		rideID, userID, req.Message,
 	).Scan(&messageID)
 
+	if err == nil {
+		err = events.Publish(tx, events.MessageSent, events.MessagePayload{
+			MessageID: messageID,
+			RideID:    rideID,
+			SenderID:  userID.(int),
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message"})
-		return
+		return apierr.Internal("Failed to create message", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"id": messageID, "message": "Message sent"})
+	return nil
 }
 
+
+// notifyNewMessage tells the other participants of a ride about a new chat
+// message, skipping anyone with a block against the sender. It returns every
+// recipient's error joined.
+func (h *Handlers) notifyNewMessage(rideID, senderID int, message string) error {
+	_, participants, err := h.rideParticipants(rideID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	preview := []rune(message)
+	if len(preview) > 140 {
+		preview = append(preview[:140], '…')
+	}
+
+	var errs []error
+	for userID := range participants {
+		if userID == senderID {
+			continue
+		}
+		blocked, err := h.isBlocked(userID, senderID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if blocked {
+			continue
+		}
+		if err := h.notifyEvent(userID, "message_received", gin.H{"sender": participants[senderID], "message": string(preview)}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
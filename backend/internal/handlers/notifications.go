@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+	"cpool.ai/backend/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notificationSorts are the sort keys GetNotifications accepts
+var notificationSorts = listSorts{
+	"created_at": {"COALESCE(created_at, 'epoch')", "timestamp"},
+}
+
+// GetNotifications returns a page of the current user's notifications,
+// newest first by default. unread=true limits the inbox to unread ones.
+func (h *Handlers) GetNotifications(c *gin.Context) error {
+	p, err := parsePage(c, notificationSorts, "-created_at", "id")
+	if err != nil {
+		return err
+	}
+
+	userID, _ := c.Get("user_id")
+
+	where := ` WHERE user_id = $1`
+	if c.Query("unread") == "true" {
+		where += ` AND read_at IS NULL`
+	}
+
+	after, afterArgs := p.where(2)
+	rows, err := h.DB.Query(
+		`SELECT id, user_id, type, title, body, read_at, created_at`+p.sortColumn()+`
+		 FROM notifications`+where+after+p.orderBy(),
+		append([]interface{}{userID}, afterArgs...)...,
+	)
+
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var sortValue string
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Title, &n.Body, &n.ReadAt, &n.CreatedAt, &sortValue); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, n.ID) {
+			break
+		}
+		notifications = append(notifications, n)
+	}
+
+	c.JSON(http.StatusOK, notificationsPage{Notifications: notifications, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// GetUnreadNotificationCount returns how many notifications the current user hasn't read
+func (h *Handlers) GetUnreadNotificationCount(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	var unread int
+	err := h.DB.QueryRow(
+		`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`,
+		userID,
+	).Scan(&unread)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": unread})
+	return nil
+}
+
+// MarkNotificationRead marks one of the current user's notifications as read
+func (h *Handlers) MarkNotificationRead(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid notification ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	result, err := h.DB.Exec(
+		`UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		 WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to update notification", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Notification not found")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+	return nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of the current user as read
+func (h *Handlers) MarkAllNotificationsRead(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	result, err := h.DB.Exec(
+		`UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to update notifications", err)
+	}
+	updated, _ := result.RowsAffected()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "updated": updated})
+	return nil
+}
+
+// GetNotificationPreferences returns which channels the current user receives each kind of notification on
+func (h *Handlers) GetNotificationPreferences(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	prefs, err := h.Notifier.Preferences(userID.(int))
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences":     prefs,
+		"push_public_key": h.Notifier.PushPublicKey(),
+	})
+	return nil
+}
+
+// UpdateNotificationPreferences turns channels on or off per kind of
+// notification. Kind "*" applies to every kind without its own setting.
+func (h *Handlers) UpdateNotificationPreferences(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	var req updateNotificationPreferencesRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	err := h.Notifier.SetPreferences(userID.(int), req.Preferences)
+	if errors.Is(err, notify.ErrInvalidPreference) {
+		return apierr.BadRequest(err.Error())
+	}
+	if err != nil {
+		return apierr.Internal("Failed to update preferences", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification preferences updated"})
+	return nil
+}
+
+// CreatePushSubscription registers a browser push subscription for the current user.
+// The body is the browser's PushSubscription serialized with toJSON().
+func (h *Handlers) CreatePushSubscription(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	var req createPushSubscriptionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	if h.Notifier.PushPublicKey() == "" {
+		return apierr.New(http.StatusServiceUnavailable, apierr.CodePushUnavailable, "Push notifications are not configured")
+	}
+
+	var userAgent *string
+	if ua := c.Request.UserAgent(); ua != "" {
+		if len(ua) > 255 {
+			ua = ua[:255]
+		}
+		userAgent = &ua
+	}
+
+	// A browser's endpoint moves to whoever last signed in on it
+	_, err := h.DB.Exec(
+		`INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth, user_agent)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (endpoint) DO UPDATE
+		 SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth,
+		     user_agent = EXCLUDED.user_agent`,
+		userID, req.Endpoint, req.Keys.P256dh, req.Keys.Auth, userAgent,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to save push subscription", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Push subscription saved"})
+	return nil
+}
+
+// DeletePushSubscription removes one of the current user's push subscriptions
+func (h *Handlers) DeletePushSubscription(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	var req deletePushSubscriptionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	result, err := h.DB.Exec(
+		`DELETE FROM push_subscriptions WHERE endpoint = $1 AND user_id = $2`,
+		req.Endpoint, userID,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to delete push subscription", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Push subscription not found")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Push subscription deleted"})
+	return nil
+}
+
+// rideNotificationData returns the ride details notification templates refer to
+func (h *Handlers) rideNotificationData(rideID int) (gin.H, error) {
+	var driver, date, rideTime, pickup string
+	err := h.DB.QueryRow(
+		`SELECT u.name, to_char(r.ride_date, 'DD Mon YYYY'), to_char(r.ride_time, 'HH24:MI'), r.pickup_point
+		 FROM rides r JOIN users u ON r.user_id = u.id
+		 WHERE r.id = $1`,
+		rideID,
+	).Scan(&driver, &date, &rideTime, &pickup)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"driver": driver, "date": date, "time": rideTime, "pickup": pickup}, nil
+}
+
+// notify stores an in-app notification for a user and queues it on the
+// external channels they receive it on
+func (h *Handlers) notify(userID int, kind, title, body string) error {
+	err := h.Notifier.Notify(notify.Notification{UserID: userID, Kind: kind, Title: title, Body: body})
+	if err != nil {
+		log.Printf("Failed to notify user %d (%s): %v", userID, kind, err)
+	}
+	return err
+}
+
+// notifyEvent notifies a user using the template registered for kind
+func (h *Handlers) notifyEvent(userID int, kind string, data gin.H) error {
+	err := h.Notifier.Notify(notify.Notification{UserID: userID, Kind: kind, Data: data})
+	if err != nil {
+		log.Printf("Failed to notify user %d (%s): %v", userID, kind, err)
+	}
+	return err
+}
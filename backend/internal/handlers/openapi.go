@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"cpool.ai/backend/internal/apispec"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	specOnce sync.Once
+	spec     *apispec.Document
+)
+
+// Spec returns the OpenAPI document for the routes RegisterRoutes mounts.
+// Every route must have an entry in endpoints; cmd/apicheck fails if one
+// is missing or a response doesn't match.
+func Spec() *apispec.Document {
+	specOnce.Do(func() {
+		spec = apispec.Build(apispec.Info{
+			Title:       "cpool.ai API",
+			Version:     "1.0.0",
+			Description: "Corridor carpooling: rides, requests, payments, safety and administration.",
+		}, "/api", endpoints)
+	})
+	return spec
+}
+
+// GetOpenAPISpec serves the OpenAPI document
+func GetOpenAPISpec(c *gin.Context) {
+	c.JSON(http.StatusOK, Spec())
+}
+
+func query(name, typ, description string) apispec.Param {
+	return apispec.Param{Name: name, Type: typ, Description: description}
+}
+
+// withCursor adds the cursor pagination parameters of a list sorted by sorts
+func withCursor(sorts listSorts, defaultSort string, params ...apispec.Param) []apispec.Param {
+	return append(append([]apispec.Param{}, params...),
+		query("limit", "integer", "Results per page, 50 by default and at most 200"),
+		query("cursor", "string", "next_cursor from the previous page"),
+		apispec.Param{Name: "sort", Type: "string", Enum: sorts.names(),
+			Description: "Sort key, prefixed with - for descending; " + defaultSort + " by default"},
+	)
+}
+
+// endpoints describes every route in the order RegisterRoutes mounts them
+var endpoints = []apispec.Endpoint{
+	// Public
+	{Method: "GET", Path: "/health", ID: "healthCheck", Summary: "API health status", Tag: "System",
+		Response: healthResponse{}},
+	{Method: "GET", Path: "/openapi.json", ID: "getOpenAPISpec", Summary: "This OpenAPI document", Tag: "System",
+		Response: map[string]interface{}{}},
+	{Method: "POST", Path: "/auth/register", ID: "register", Summary: "Create an account", Tag: "Auth",
+		Body: registerRequest{}, Status: http.StatusCreated, Response: registerResponse{}},
+	{Method: "POST", Path: "/auth/login", ID: "login", Summary: "Log in with email and password", Tag: "Auth",
+		Body: loginRequest{}, Response: loginResponse{}},
+	{Method: "GET", Path: "/share/:token", ID: "getSharedRide", Summary: "Public view of a shared ride", Tag: "Safety",
+		Response: models.SharedRide{}},
+
+	// Auth
+	{Method: "GET", Path: "/auth/profile", ID: "getProfile", Summary: "Current user's profile", Tag: "Auth", Auth: apispec.User,
+		Response: models.User{}},
+	{Method: "PUT", Path: "/auth/profile", ID: "updateProfile", Summary: "Update the current user's profile", Tag: "Auth", Auth: apispec.User,
+		Body: updateProfileRequest{}, Response: messageResponse{}},
+
+	// Stats
+	{Method: "GET", Path: "/stats", ID: "getStats", Summary: "Rides today and users online", Tag: "Stats", Auth: apispec.User,
+		Query:    []apispec.Param{query("city_id", "integer", "Limit to one city")},
+		Response: statsResponse{}},
+
+	// Cities
+	{Method: "GET", Path: "/cities", ID: "getCities", Summary: "All cities", Tag: "Cities", Auth: apispec.User,
+		Response: []models.City{}},
+	{Method: "PUT", Path: "/cities/:id/status", ID: "updateCityStatus", Summary: "Launch, lock or schedule a city", Tag: "Cities", Auth: apispec.Admin,
+		Body: updateCityStatusRequest{}, Response: cityStatusResponse{}},
+	{Method: "POST", Path: "/cities/:id/waitlist", ID: "joinWaitlist", Summary: "Join a locked city's waitlist", Tag: "Cities", Auth: apispec.User,
+		Body: joinWaitlistRequest{}, Status: http.StatusCreated, Response: createdResponse{}},
+	{Method: "DELETE", Path: "/cities/:id/waitlist", ID: "leaveWaitlist", Summary: "Leave a city's waitlist", Tag: "Cities", Auth: apispec.User,
+		Response: messageResponse{}},
+
+	// Notifications
+	{Method: "GET", Path: "/notifications", ID: "getNotifications", Summary: "Current user's notifications, newest first", Tag: "Notifications", Auth: apispec.User,
+		Query:    withCursor(notificationSorts, "-created_at", query("unread", "boolean", "Only unread notifications")),
+		Response: notificationsPage{}},
+	{Method: "GET", Path: "/notifications/unread-count", ID: "getUnreadNotificationCount", Summary: "Number of unread notifications", Tag: "Notifications", Auth: apispec.User,
+		Response: unreadCountResponse{}},
+	{Method: "PUT", Path: "/notifications/read-all", ID: "markAllNotificationsRead", Summary: "Mark every notification read", Tag: "Notifications", Auth: apispec.User,
+		Response: markAllReadResponse{}},
+	{Method: "PUT", Path: "/notifications/:id/read", ID: "markNotificationRead", Summary: "Mark a notification read", Tag: "Notifications", Auth: apispec.User,
+		Response: messageResponse{}},
+	{Method: "GET", Path: "/notifications/preferences", ID: "getNotificationPreferences", Summary: "Channel preferences per kind of notification", Tag: "Notifications", Auth: apispec.User,
+		Response: notificationPreferencesResponse{}},
+	{Method: "PUT", Path: "/notifications/preferences", ID: "updateNotificationPreferences", Summary: "Turn channels on or off per kind of notification", Tag: "Notifications", Auth: apispec.User,
+		Body: updateNotificationPreferencesRequest{}, Response: messageResponse{}},
+	{Method: "POST", Path: "/notifications/push-subscriptions", ID: "createPushSubscription", Summary: "Register a browser for web push", Tag: "Notifications", Auth: apispec.User,
+		Body: createPushSubscriptionRequest{}, Status: http.StatusCreated, Response: messageResponse{}},
+	{Method: "DELETE", Path: "/notifications/push-subscriptions", ID: "deletePushSubscription", Summary: "Unregister a browser from web push", Tag: "Notifications", Auth: apispec.User,
+		Body: deletePushSubscriptionRequest{}, Response: messageResponse{}},
+
+	// Feature flags
+	{Method: "GET", Path: "/features", ID: "getFeatures", Summary: "Feature flags evaluated for the current user", Tag: "Features", Auth: apispec.User,
+		Query: []apispec.Param{
+			query("city_id", "integer", "Evaluate for this city"),
+			query("corridor_id", "integer", "Evaluate for this corridor"),
+		},
+		Response: map[string]bool{}},
+
+	// Corridors
+	{Method: "GET", Path: "/corridors", ID: "getCorridors", Summary: "Corridors, optionally for one city", Tag: "Corridors", Auth: apispec.User,
+		Query: withCursor(corridorSorts, "name",
+			query("city_id", "integer", "Limit to one city"),
+			query("active", "boolean", "Only active corridors"),
+		),
+		Response: corridorsPage{}},
+	{Method: "GET", Path: "/corridors/:id", ID: "getCorridor", Summary: "A corridor", Tag: "Corridors", Auth: apispec.User,
+		Response: models.Corridor{}},
+	{Method: "POST", Path: "/corridors", ID: "createCorridor", Summary: "Create a corridor", Tag: "Corridors", Auth: apispec.Admin,
+		Body: createCorridorRequest{}, Status: http.StatusCreated, Response: createdResponse{}},
+	{Method: "PUT", Path: "/corridors/:id", ID: "updateCorridor", Summary: "Update a corridor", Tag: "Corridors", Auth: apispec.Admin,
+		Body: updateCorridorRequest{}, Response: messageResponse{}},
+	{Method: "DELETE", Path: "/corridors/:id", ID: "deleteCorridor", Summary: "Delete a corridor", Tag: "Corridors", Auth: apispec.Admin,
+		Response: messageResponse{}},
+
+	// User corridors
+	{Method: "GET", Path: "/user/corridors", ID: "getUserCorridors", Summary: "Corridors assigned to the current user", Tag: "Corridors", Auth: apispec.User,
+		Response: []models.Corridor{}},
+	{Method: "POST", Path: "/user/corridors", ID: "assignCorridor", Summary: "Assign a corridor to a user", Tag: "Corridors", Auth: apispec.Admin,
+		Body: assignCorridorRequest{}, Status: http.StatusCreated, Response: messageResponse{}},
+
+	// Blocking and reports
+	{Method: "GET", Path: "/user/blocks", ID: "getBlockedUsers", Summary: "Users the current user has blocked", Tag: "Safety", Auth: apispec.User,
+		Response: []models.BlockedUser{}},
+	{Method: "POST", Path: "/user/blocks", ID: "blockUser", Summary: "Block a user", Tag: "Safety", Auth: apispec.User,
+		Body: blockUserRequest{}, Response: messageResponse{}},
+	{Method: "DELETE", Path: "/user/blocks/:userId", ID: "unblockUser", Summary: "Unblock a user", Tag: "Safety", Auth: apispec.User,
+		Response: messageResponse{}},
+	{Method: "POST", Path: "/reports", ID: "createReport", Summary: "Report a user, message or ride", Tag: "Safety", Auth: apispec.User,
+		Body: createReportRequest{}, Status: http.StatusCreated, Response: createdResponse{}},
+
+	// Safety
+	{Method: "GET", Path: "/user/emergency-contacts", ID: "getEmergencyContacts", Summary: "Current user's emergency contacts", Tag: "Safety", Auth: apispec.User,
+		Response: []models.EmergencyContact{}},
+	{Method: "POST", Path: "/user/emergency-contacts", ID: "createEmergencyContact", Summary: "Add an emergency contact", Tag: "Safety", Auth: apispec.User,
+		Body: createEmergencyContactRequest{}, Status: http.StatusCreated, Response: createdResponse{}},
+	{Method: "DELETE", Path: "/user/emergency-contacts/:contactId", ID: "deleteEmergencyContact", Summary: "Remove an emergency contact", Tag: "Safety", Auth: apispec.User,
+		Response: messageResponse{}},
+	{Method: "POST", Path: "/rides/:id/sos", ID: "raiseSOS", Summary: "Raise an SOS alert", Tag: "Safety", Auth: apispec.User,
+		Body: raiseSOSRequest{}, Status: http.StatusCreated, Response: raiseSOSResponse{}},
+	{Method: "POST", Path: "/rides/:id/share", ID: "createShareLink", Summary: "Create a link to share a ride", Tag: "Safety", Auth: apispec.User,
+		Body: createShareLinkRequest{}, OptionalBody: true, Status: http.StatusCreated, Response: shareLinkResponse{}},
+	{Method: "DELETE", Path: "/rides/:id/share", ID: "revokeShareLinks", Summary: "Revoke a ride's share links", Tag: "Safety", Auth: apispec.User,
+		Response: revokeShareLinksResponse{}},
+
+	// Vehicles
+	{Method: "GET", Path: "/vehicles", ID: "getVehicles", Summary: "Current user's vehicles", Tag: "Vehicles", Auth: apispec.User,
+		Query:    []apispec.Param{query("include_archived", "boolean", "Include archived vehicles")},
+		Response: []models.Vehicle{}},
+	{Method: "GET", Path: "/vehicles/:id", ID: "getVehicle", Summary: "A vehicle", Tag: "Vehicles", Auth: apispec.User,
+		Response: models.Vehicle{}},
+	{Method: "POST", Path: "/vehicles", ID: "createVehicle", Summary: "Add a vehicle", Tag: "Vehicles", Auth: apispec.User,
+		Body: createVehicleRequest{}, Status: http.StatusCreated, Response: vehicleCreatedResponse{}},
+	{Method: "PUT", Path: "/vehicles/:id", ID: "updateVehicle", Summary: "Update a vehicle", Tag: "Vehicles", Auth: apispec.User,
+		Body: updateVehicleRequest{}, Response: messageResponse{}},
+	{Method: "DELETE", Path: "/vehicles/:id", ID: "deleteVehicle", Summary: "Archive a vehicle", Tag: "Vehicles", Auth: apispec.User,
+		Response: messageResponse{}},
+	{Method: "POST", Path: "/vehicles/:id/swap", ID: "swapVehicle", Summary: "Move upcoming rides to another vehicle", Tag: "Vehicles", Auth: apispec.User,
+		Body: swapVehicleRequest{}, Response: swapVehicleResponse{}},
+	{Method: "GET", Path: "/vehicles/:id/documents", ID: "getVehicleDocuments", Summary: "A vehicle's documents", Tag: "Vehicles", Auth: apispec.User,
+		Response: []models.VehicleDocument{}},
+	{Method: "POST", Path: "/vehicles/:id/documents", ID: "uploadVehicleDocument", Summary: "Upload an RC, insurance or driving licence", Tag: "Vehicles", Auth: apispec.User,
+		Form: []apispec.Param{
+			{Name: "doc_type", Enum: []string{"rc", "insurance", "driving_licence"}, Required: true},
+			{Name: "expires_on", Format: "date"},
+			{Name: "file", Type: "file", Required: true, Description: "PDF, JPEG or PNG"},
+		},
+		Status: http.StatusCreated, Response: createdResponse{}},
+	{Method: "GET", Path: "/vehicles/:id/documents/:docId/file", ID: "downloadVehicleDocument", Summary: "Download a document", Tag: "Vehicles", Auth: apispec.User,
+		Produces: "application/octet-stream"},
+
+	// Rides
+	{Method: "GET", Path: "/rides", ID: "getRides", Summary: "Rides visible to the current user", Tag: "Rides", Auth: apispec.User,
+		Query: withCursor(rideSorts, "departure",
+			query("corridor_id", "integer", ""),
+			apispec.Param{Name: "date", Format: "date"},
+			apispec.Param{Name: "status", Type: "string", Enum: []string{"open", "partially_filled", "full", "completed", "cancelled"}},
+			query("user_id", "integer", "Rides offered by this driver"),
+		),
+		Response: ridesPage{}},
+	{Method: "GET", Path: "/rides/:id", ID: "getRide", Summary: "A ride", Tag: "Rides", Auth: apispec.User,
+		Response: models.Ride{}},
+	{Method: "POST", Path: "/rides", ID: "createRide", Summary: "Offer a ride", Tag: "Rides", Auth: apispec.User,
+		Body: createRideRequest{}, Status: http.StatusCreated, Response: createdResponse{}},
+	{Method: "PUT", Path: "/rides/:id", ID: "updateRide", Summary: "Update a ride", Tag: "Rides", Auth: apispec.User,
+		Body: updateRideRequest{}, Response: messageResponse{}},
+	{Method: "DELETE", Path: "/rides/:id", ID: "cancelRide", Summary: "Cancel a ride", Tag: "Rides", Auth: apispec.User,
+		Response: messageResponse{}},
+
+	// Ride requests
+	{Method: "GET", Path: "/rides/:id/requests", ID: "getRideRequests", Summary: "Requests for seats on a ride", Tag: "Rides", Auth: apispec.User,
+		Response: []models.RideRequest{}},
+	{Method: "POST", Path: "/rides/:id/requests", ID: "createRideRequest", Summary: "Request seats on a ride", Tag: "Rides", Auth: apispec.User,
+		Body: createRideRequestBody{}, Status: http.StatusCreated, Response: createdResponse{}},
+	{Method: "PUT", Path: "/rides/:id/requests/:requestId", ID: "updateRideRequest", Summary: "Accept or reject a request", Tag: "Rides", Auth: apispec.User,
+		Body: updateRideRequestBody{}, Response: messageResponse{}},
+
+	// Messages
+	{Method: "GET", Path: "/rides/:id/messages", ID: "getMessages", Summary: "A ride's chat messages", Tag: "Rides", Auth: apispec.User,
+		Query:    withCursor(messageSorts, "created_at", query("last_id", "integer", "Only messages after this one")),
+		Response: messagesPage{}},
+	{Method: "POST", Path: "/rides/:id/messages", ID: "createMessage", Summary: "Send a chat message", Tag: "Rides", Auth: apispec.User,
+		Body: createMessageRequest{}, Status: http.StatusCreated, Response: createdResponse{}},
+
+	// Payments
+	{Method: "GET", Path: "/rides/:id/payments", ID: "getPayments", Summary: "A ride's payments", Tag: "Payments", Auth: apispec.User,
+		Query:    withCursor(paymentSorts, "-created_at"),
+		Response: paymentsPage{}},
+	{Method: "POST", Path: "/rides/:id/payments", ID: "createPayment", Summary: "Record a payment", Tag: "Payments", Auth: apispec.User,
+		Body: createPaymentRequest{}, Status: http.StatusCreated, Response: messageResponse{}},
+	{Method: "PUT", Path: "/rides/:id/payments/:userId", ID: "updatePaymentStatus", Summary: "Mark a payment sent or received", Tag: "Payments", Auth: apispec.User,
+		Body: updatePaymentStatusRequest{}, Response: messageResponse{}},
+
+	// Reviews
+	{Method: "GET", Path: "/rides/:id/reviews", ID: "getRideReviews", Summary: "Reviews the current user gave on a ride and who is left", Tag: "Reviews", Auth: apispec.User,
+		Response: rideReviewsResponse{}},
+	{Method: "POST", Path: "/rides/:id/reviews", ID: "createReview", Summary: "Review another participant", Tag: "Reviews", Auth: apispec.User,
+		Body: createReviewRequest{}, Status: http.StatusCreated, Response: createdResponse{}},
+	{Method: "GET", Path: "/users/:id/reviews", ID: "getUserReviews", Summary: "A user's rating summary", Tag: "Reviews", Auth: apispec.User,
+		Query:    withCursor(reviewSorts, "-created_at"),
+		Response: models.RatingSummary{}},
+
+	// Admin
+	{Method: "GET", Path: "/admin/users", ID: "getAllUsers", Summary: "Search users", Tag: "Admin", Auth: apispec.Admin,
+		Query: withCursor(userSorts, "-created_at",
+			query("q", "string", "Search name, email, city and role"),
+			query("city", "string", "Users in this city"),
+			apispec.Param{Name: "role", Type: "string", Enum: []string{"user", "admin"}},
+		),
+		Response: usersPage{}},
+	{Method: "PUT", Path: "/admin/users/:id", ID: "updateUser", Summary: "Update a user", Tag: "Admin", Auth: apispec.Admin,
+		Body: updateUserRequest{}, Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/rides", ID: "adminGetRides", Summary: "Search rides", Tag: "Admin", Auth: apispec.Admin,
+		Query: withCursor(adminRideSorts, "-departure",
+			query("status", "string", ""),
+			query("city_id", "integer", ""),
+			query("corridor_id", "integer", ""),
+			query("user_id", "integer", "Driver"),
+			query("vehicle_id", "integer", ""),
+			query("rider_id", "integer", "Rides this user requested"),
+			query("q", "string", "Driver, corridor or pickup/drop point"),
+			apispec.Param{Name: "from", Format: "date"},
+			apispec.Param{Name: "to", Format: "date"},
+			query("stale", "boolean", "Only past rides still open"),
+		),
+		Response: adminRidesPage{}},
+	{Method: "POST", Path: "/admin/rides/close-stale", ID: "closeStaleRides", Summary: "Complete or cancel rides left open", Tag: "Admin", Auth: apispec.Admin,
+		Body: closeStaleRidesRequest{}, OptionalBody: true, Response: closeStaleRidesResponse{}},
+	{Method: "GET", Path: "/admin/rides/:id/timeline", ID: "getRideTimeline", Summary: "A ride's full history", Tag: "Admin", Auth: apispec.Admin,
+		Response: models.RideTimeline{}},
+	{Method: "POST", Path: "/admin/rides/:id/cancel", ID: "adminCancelRide", Summary: "Cancel a ride and tell everyone on it", Tag: "Admin", Auth: apispec.Admin,
+		Body: adminCancelRideRequest{}, Response: adminCancelRideResponse{}},
+	{Method: "POST", Path: "/admin/rides/:id/reassign", ID: "reassignRide", Summary: "Give a ride to another driver", Tag: "Admin", Auth: apispec.Admin,
+		Body: reassignRideRequest{}, Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/analytics", ID: "getAnalytics", Summary: "Platform totals", Tag: "Admin", Auth: apispec.Admin,
+		Response: analyticsResponse{}},
+	{Method: "GET", Path: "/admin/analytics/timeseries", ID: "getAnalyticsTimeSeries", Summary: "Ride metrics over time", Tag: "Admin", Auth: apispec.Admin,
+		Query: []apispec.Param{
+			{Name: "granularity", Enum: []string{"day", "week", "month"}},
+			{Name: "group_by", Enum: []string{"city", "corridor"}},
+			{Name: "from", Format: "date"},
+			{Name: "to", Format: "date"},
+			query("city_id", "integer", ""),
+			query("corridor_id", "integer", ""),
+		},
+		Response: timeSeriesResponse{}},
+	{Method: "POST", Path: "/admin/analytics/refresh", ID: "refreshAnalytics", Summary: "Rebuild the analytics rollup", Tag: "Admin", Auth: apispec.Admin,
+		Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/features", ID: "listFeatures", Summary: "All feature flags", Tag: "Admin", Auth: apispec.Admin,
+		Response: []models.FeatureFlag{}},
+	{Method: "POST", Path: "/admin/features", ID: "createFeature", Summary: "Create a feature flag", Tag: "Admin", Auth: apispec.Admin,
+		Body: createFeatureRequest{}, Status: http.StatusCreated, Response: createdResponse{}},
+	{Method: "PUT", Path: "/admin/features/:name", ID: "toggleFeature", Summary: "Update a feature flag", Tag: "Admin", Auth: apispec.Admin,
+		Body: toggleFeatureRequest{}, Response: messageResponse{}},
+	{Method: "DELETE", Path: "/admin/features/:name", ID: "deleteFeature", Summary: "Delete a feature flag", Tag: "Admin", Auth: apispec.Admin,
+		Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/jobs", ID: "getJobs", Summary: "Background jobs", Tag: "Admin", Auth: apispec.Admin,
+		Response: []models.ScheduledJob{}},
+	{Method: "GET", Path: "/admin/jobs/runs", ID: "getJobRuns", Summary: "Background job run history", Tag: "Admin", Auth: apispec.Admin,
+		Query: withCursor(jobRunSorts, "-started_at",
+			query("job", "string", ""),
+			apispec.Param{Name: "status", Type: "string", Enum: []string{"running", "succeeded", "failed"}}),
+		Response: jobRunsPage{}},
+	{Method: "POST", Path: "/admin/jobs/:name/run", ID: "runJob", Summary: "Run a job now", Tag: "Admin", Auth: apispec.Admin,
+		Status: http.StatusAccepted, Response: jobScheduledResponse{}},
+	{Method: "GET", Path: "/admin/webhooks", ID: "getWebhooks", Summary: "Webhook endpoints", Tag: "Webhooks", Auth: apispec.Admin,
+		Response: []models.WebhookEndpoint{}},
+	{Method: "POST", Path: "/admin/webhooks", ID: "createWebhook", Summary: "Register a webhook endpoint", Tag: "Webhooks", Auth: apispec.Admin,
+		Body: createWebhookRequest{}, Status: http.StatusCreated, Response: models.WebhookEndpoint{}},
+	{Method: "GET", Path: "/admin/webhooks/event-types", ID: "getWebhookEventTypes", Summary: "Event types endpoints can subscribe to", Tag: "Webhooks", Auth: apispec.Admin,
+		Response: webhookEventTypesResponse{}},
+	{Method: "GET", Path: "/admin/webhooks/deliveries", ID: "getWebhookDeliveries", Summary: "Webhook deliveries, newest first", Tag: "Webhooks", Auth: apispec.Admin,
+		Query: withCursor(webhookDeliverySorts, "-created_at",
+			query("endpoint_id", "integer", ""),
+			apispec.Param{Name: "status", Enum: []string{"pending", "sending", "succeeded", "failed"}},
+			query("event_type", "string", ""),
+		),
+		Response: webhookDeliveriesPage{}},
+	{Method: "GET", Path: "/admin/webhooks/deliveries/:id", ID: "getWebhookDelivery", Summary: "A delivery with its payload and attempts", Tag: "Webhooks", Auth: apispec.Admin,
+		Response: models.WebhookDelivery{}},
+	{Method: "POST", Path: "/admin/webhooks/deliveries/:id/redeliver", ID: "redeliverWebhook", Summary: "Send a delivery again", Tag: "Webhooks", Auth: apispec.Admin,
+		Status: http.StatusAccepted, Response: messageResponse{}},
+	{Method: "PUT", Path: "/admin/webhooks/:id", ID: "updateWebhook", Summary: "Update or re-enable a webhook endpoint", Tag: "Webhooks", Auth: apispec.Admin,
+		Body: updateWebhookRequest{}, Response: models.WebhookEndpoint{}},
+	{Method: "DELETE", Path: "/admin/webhooks/:id", ID: "deleteWebhook", Summary: "Delete a webhook endpoint", Tag: "Webhooks", Auth: apispec.Admin,
+		Response: messageResponse{}},
+	{Method: "POST", Path: "/admin/webhooks/:id/rotate-secret", ID: "rotateWebhookSecret", Summary: "Replace an endpoint's signing secret", Tag: "Webhooks", Auth: apispec.Admin,
+		Response: webhookSecretResponse{}},
+	{Method: "POST", Path: "/admin/webhooks/:id/test", ID: "testWebhook", Summary: "Send a test ping", Tag: "Webhooks", Auth: apispec.Admin,
+		Status: http.StatusAccepted, Response: webhookTestResponse{}},
+	{Method: "GET", Path: "/admin/audit", ID: "getAuditLog", Summary: "Audit log", Tag: "Admin", Auth: apispec.Admin,
+		Query:    withCursor(auditSorts, "-created_at", auditParams...),
+		Response: auditLogPage{}},
+	{Method: "GET", Path: "/admin/audit/export", ID: "exportAuditLog", Summary: "Audit log as CSV", Tag: "Admin", Auth: apispec.Admin,
+		Query: auditParams, Produces: "text/csv"},
+	{Method: "PUT", Path: "/admin/cities/:id", ID: "updateCitySettings", Summary: "Update a city's timezone, currency and locale", Tag: "Cities", Auth: apispec.Admin,
+		Body: updateCitySettingsRequest{}, Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/cities/:id/waitlist", ID: "getWaitlist", Summary: "A city's waitlist", Tag: "Cities", Auth: apispec.Admin,
+		Query:    withCursor(waitlistSorts, "created_at"),
+		Response: waitlistPage{}},
+	{Method: "GET", Path: "/admin/cities/:id/demand", ID: "getCityDemand", Summary: "Waitlist demand by route", Tag: "Cities", Auth: apispec.Admin,
+		Query:    []apispec.Param{query("min_signups", "integer", "Signups needed to suggest a corridor")},
+		Response: models.DemandReport{}},
+	{Method: "GET", Path: "/admin/vehicles/review", ID: "getVehicleReviewQueue", Summary: "Vehicles awaiting verification", Tag: "Vehicles", Auth: apispec.Admin,
+		Response: []models.VehicleReview{}},
+	{Method: "PUT", Path: "/admin/vehicles/:id/review", ID: "reviewVehicle", Summary: "Approve or reject a vehicle", Tag: "Vehicles", Auth: apispec.Admin,
+		Body: reviewVehicleRequest{}, Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/reviews", ID: "adminGetReviews", Summary: "Reviews for moderation", Tag: "Reviews", Auth: apispec.Admin,
+		Query: withCursor(reviewSorts, "-created_at",
+			query("status", "string", ""),
+			query("reviewer_id", "integer", ""),
+			query("reviewee_id", "integer", ""),
+			query("ride_id", "integer", ""),
+			query("max_rating", "integer", ""),
+			query("has_comment", "boolean", ""),
+		),
+		Response: reviewsPage{}},
+	{Method: "PUT", Path: "/admin/reviews/:id", ID: "moderateReview", Summary: "Hide or restore a review", Tag: "Reviews", Auth: apispec.Admin,
+		Body: moderateReviewRequest{}, Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/reports", ID: "getReports", Summary: "Abuse reports", Tag: "Safety", Auth: apispec.Admin,
+		Query: withCursor(reportSorts, "triage",
+			query("status", "string", ""),
+			query("category", "string", ""),
+			query("target_type", "string", ""),
+			query("reported_user_id", "integer", ""),
+		),
+		Response: reportsPage{}},
+	{Method: "PUT", Path: "/admin/reports/:id", ID: "updateReport", Summary: "Update a report's status", Tag: "Safety", Auth: apispec.Admin,
+		Body: updateReportRequest{}, Response: messageResponse{}},
+	{Method: "GET", Path: "/admin/sos", ID: "getSOSAlerts", Summary: "SOS alerts", Tag: "Safety", Auth: apispec.Admin,
+		Query:    withCursor(sosAlertSorts, "-triage", query("status", "string", "")),
+		Response: sosAlertsPage{}},
+	{Method: "PUT", Path: "/admin/sos/:id/resolve", ID: "resolveSOSAlert", Summary: "Resolve an SOS alert", Tag: "Safety", Auth: apispec.Admin,
+		Body: resolveSOSAlertRequest{}, Response: messageResponse{}},
+}
+
+var auditParams = []apispec.Param{
+	query("actor_id", "integer", ""),
+	query("action", "string", ""),
+	query("target_type", "string", ""),
+	query("target_id", "string", ""),
+	{Name: "from", Format: "date"},
+	{Name: "to", Format: "date"},
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cpool.ai/backend/internal/apierr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cursor pagination for list endpoints. A list accepts limit, sort (one of
+// its sort keys, prefixed with - for descending) and cursor (the previous
+// page's next_cursor), and returns next_cursor, null on the last page.
+// Pages are keyset-based on the sort value and then id, so rows added in
+// the meantime don't shift them.
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// sortKey is a column a list can be sorted by. The expression must never be
+// NULL; cast is the SQL type a cursor's text value is read back as.
+type sortKey struct {
+	expr string
+	cast string
+}
+
+// listSorts maps the sort names an endpoint accepts to columns
+type listSorts map[string]sortKey
+
+// names returns every accepted sort value, ascending and descending
+func (s listSorts) names() []string {
+	names := make([]string, 0, 2*len(s))
+	for name := range s {
+		names = append(names, name, "-"+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cursor is the position after the last row of a page
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// page is one page of a list: its size, order and where it starts
+type page struct {
+	limit  int
+	sort   string
+	key    sortKey
+	desc   bool
+	idExpr string
+	after  *cursor
+
+	// rows and last track the rows read so far for the next cursor
+	rows int
+	last cursor
+}
+
+// parsePage reads limit, sort and cursor from the query string. idExpr is
+// the id column that breaks ties between equal sort values.
+func parsePage(c *gin.Context, sorts listSorts, defaultSort, idExpr string) (*page, error) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultListLimit)))
+	if limit < 1 || limit > maxListLimit {
+		limit = defaultListLimit
+	}
+
+	p := &page{limit: limit, sort: c.DefaultQuery("sort", defaultSort), idExpr: idExpr}
+	key, ok := sorts[strings.TrimPrefix(p.sort, "-")]
+	if !ok {
+		return nil, apierr.BadRequest("sort must be one of " + strings.Join(sorts.names(), ", "))
+	}
+	p.key = key
+	p.desc = strings.HasPrefix(p.sort, "-")
+
+	if v := c.Query("cursor"); v != "" {
+		after, err := decodeCursor(v)
+		if err != nil {
+			return nil, apierr.BadRequest("Invalid cursor")
+		}
+		if after.Sort != p.sort {
+			return nil, apierr.BadRequest("Cursor was issued for a different sort")
+		}
+		p.after = after
+	}
+	return p, nil
+}
+
+// sortColumn is selected after a row's columns so the next cursor can be
+// built from the last row
+func (p *page) sortColumn() string {
+	return `, (` + p.key.expr + `)::text`
+}
+
+// where returns the condition that starts the page after the cursor, with
+// placeholders numbered from argIndex. It is empty on the first page.
+func (p *page) where(argIndex int) (string, []interface{}) {
+	if p.after == nil {
+		return "", nil
+	}
+	op := ">"
+	if p.desc {
+		op = "<"
+	}
+	return ` AND (` + p.key.expr + `, ` + p.idExpr + `) ` + op +
+			` ($` + strconv.Itoa(argIndex) + `::` + p.key.cast + `, $` + strconv.Itoa(argIndex+1) + `)`,
+		[]interface{}{p.after.Value, p.after.ID}
+}
+
+// orderBy returns the ORDER BY and LIMIT clauses. One row more than the
+// limit is fetched to tell whether there is another page.
+func (p *page) orderBy() string {
+	dir := " ASC"
+	if p.desc {
+		dir = " DESC"
+	}
+	return ` ORDER BY ` + p.key.expr + dir + `, ` + p.idExpr + dir + ` LIMIT ` + strconv.Itoa(p.limit+1)
+}
+
+// add records a row read from the query and reports whether it belongs on
+// this page; it is false for the extra row
+func (p *page) add(sortValue string, id int) bool {
+	p.rows++
+	if p.rows > p.limit {
+		return false
+	}
+	p.last = cursor{Sort: p.sort, Value: sortValue, ID: id}
+	return true
+}
+
+// nextCursor returns the cursor for the following page, or nil if this is
+// the last one
+func (p *page) nextCursor() *string {
+	if p.rows <= p.limit {
+		return nil
+	}
+	next := p.last.encode()
+	return &next
+}
@@ -1,74 +1,86 @@
 package handlers
 
 import (
+	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/events"
 	"cpool.ai/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetPayments returns payments for a ride
-func (h *Handlers) GetPayments(c *gin.Context) {
+// paymentSorts are the sort keys GetPayments accepts
+var paymentSorts = listSorts{
+	"created_at": {"COALESCE(p.created_at, 'epoch')", "timestamp"},
+	"amount":     {"p.amount", "numeric"},
+}
+
+// GetPayments returns a page of a ride's payments, newest first by default
+func (h *Handlers) GetPayments(c *gin.Context) error {
 	rideID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	pg, err := parsePage(c, paymentSorts, "-created_at", "p.id")
+	if err != nil {
+		return err
 	}
 
+	after, afterArgs := pg.where(2)
 	rows, err := h.DB.Query(
 		`SELECT p.id, p.ride_id, p.rider_id, u1.name as rider_name, p.ride_giver_id,
 		       u2.name as giver_name, p.amount, p.rider_status, p.giver_status,
-		       p.admin_override, p.created_at, p.updated_at
+		       p.admin_override, p.created_at, p.updated_at`+pg.sortColumn()+`
 		 FROM payments p
 		 JOIN users u1 ON p.rider_id = u1.id
 		 JOIN users u2 ON p.ride_giver_id = u2.id
-		 WHERE p.ride_id = $1
-		 ORDER BY p.created_at DESC`,
-		rideID,
+		 WHERE p.ride_id = $1`+after+pg.orderBy(),
+		append([]interface{}{rideID}, afterArgs...)...,
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var payments []models.Payment
+	payments := []models.Payment{}
 	for rows.Next() {
 		var payment models.Payment
+		var sortValue string
 		if err := rows.Scan(
 			&payment.ID, &payment.RideID, &payment.RiderID, &payment.RiderName,
 			&payment.RideGiverID, &payment.GiverName, &payment.Amount,
 			&payment.RiderStatus, &payment.GiverStatus, &payment.AdminOverride,
-			&payment.CreatedAt, &payment.UpdatedAt,
+			&payment.CreatedAt, &payment.UpdatedAt, &sortValue,
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+			return apierr.Internal("Database error", err)
+		}
+		if !pg.add(sortValue, payment.ID) {
+			break
 		}
 		payments = append(payments, payment)
 	}
 
-	c.JSON(http.StatusOK, payments)
+	c.JSON(http.StatusOK, paymentsPage{Payments: payments, NextCursor: pg.nextCursor()})
+	return nil
 }
 
 // CreatePayment creates a payment record (usually done automatically on request acceptance)
-func (h *Handlers) CreatePayment(c *gin.Context) {
+func (h *Handlers) CreatePayment(c *gin.Context) error {
 	rideID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
 	}
 
-	var req struct {
-		RiderID int     `json:"rider_id" binding:"required"`
-		Amount  float64 `json:"amount" binding:"required,min=0"`
-	}
+	var req createPaymentRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
 	userID, _ := c.Get("user_id")
@@ -77,11 +89,16 @@ func (h *Handlers) CreatePayment(c *gin.Context) {
 	var rideUserID int
 	err = h.DB.QueryRow(`SELECT user_id FROM rides WHERE id = $1`, rideID).Scan(&rideUserID)
 	if err != nil || rideUserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this ride"})
-		return
+		return apierr.Forbidden("You don't own this ride")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
 	}
+	defer tx.Rollback()
 
-	_, err = h.DB.Exec(
+	result, err := tx.Exec(
 		`INSERT INTO payments (ride_id, rider_id, ride_giver_id, amount, rider_status, giver_status)
 		 VALUES ($1, $2, $3, $4, 'pending', 'pending')
 		 ON CONFLICT (ride_id, rider_id) DO NOTHING`,
@@ -89,38 +106,72 @@ func (h *Handlers) CreatePayment(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
-		return
+		return apierr.Internal("Failed to create payment", err)
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		err = audit(c, tx, "payment.create", "payment", fmt.Sprintf("%d:%d", rideID, req.RiderID), nil,
+			snapshot(tx, "payments", "ride_id = $1 AND rider_id = $2", rideID, req.RiderID))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to create payment", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Payment record created"})
+	return nil
+}
+
+// notifyPayment tells recipientID that the payment for riderID on a ride was
+// marked with status. A payment that no longer exists is skipped.
+func (h *Handlers) notifyPayment(rideID, riderID, recipientID int, actor, status string) error {
+	var amount float64
+	var currency, date string
+	err := h.DB.QueryRow(
+		`SELECT p.amount, ci.currency, to_char(r.ride_date, 'DD Mon YYYY')
+		 FROM payments p
+		 JOIN rides r ON p.ride_id = r.id
+		 JOIN corridors c ON r.corridor_id = c.id
+		 JOIN cities ci ON c.city_id = ci.id
+		 WHERE p.ride_id = $1 AND p.rider_id = $2`,
+		rideID, riderID,
+	).Scan(&amount, &currency, &date)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.notifyEvent(recipientID, "payment_marked", gin.H{
+		"actor":  actor,
+		"amount": fmt.Sprintf("%.2f %s", amount, currency),
+		"date":   date,
+		"status": status,
+	})
 }
 
 // UpdatePaymentStatus updates payment status
-func (h *Handlers) UpdatePaymentStatus(c *gin.Context) {
+func (h *Handlers) UpdatePaymentStatus(c *gin.Context) error {
 	rideID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
 	}
 
 	userIDParam, err := strconv.Atoi(c.Param("userId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
+		return apierr.BadRequest("Invalid user ID")
 	}
 
 	currentUserID, _ := c.Get("user_id")
 	currentUserRole, _ := c.Get("user_role")
 
-	var req struct {
-		RiderStatus *string `json:"rider_status"`
-		GiverStatus *string `json:"giver_status"`
-	}
+	var req updatePaymentStatusRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
 	// Get payment record
@@ -131,8 +182,7 @@ func (h *Handlers) UpdatePaymentStatus(c *gin.Context) {
 	).Scan(&riderID, &giverID)
 
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
-		return
+		return apierr.NotFound("Payment not found")
 	}
 
 	// Check permissions
@@ -141,8 +191,7 @@ func (h *Handlers) UpdatePaymentStatus(c *gin.Context) {
 	isAdmin := currentUserRole == "admin"
 
 	if !isRider && !isGiver && !isAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to update this payment"})
-		return
+		return apierr.Forbidden("You don't have permission to update this payment")
 	}
 
 	// Update status based on who is making the request
@@ -163,8 +212,7 @@ func (h *Handlers) UpdatePaymentStatus(c *gin.Context) {
 	}
 
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No status to update"})
-		return
+		return apierr.BadRequest("No status to update")
 	}
 
 	if isAdmin {
@@ -180,12 +228,51 @@ func (h *Handlers) UpdatePaymentStatus(c *gin.Context) {
 	}
 	query += ` WHERE ride_id = $` + strconv.Itoa(argIndex) + ` AND rider_id = $` + strconv.Itoa(argIndex+1)
 
-	_, err = h.DB.Exec(query, args...)
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "payments", "ride_id = $1 AND rider_id = $2", rideID, userIDParam)
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		return apierr.Internal("Failed to update payment", err)
+	}
+
+	// Let the other side know a payment was marked as made or received
+	payment := events.PaymentPayload{
+		RideID:  rideID,
+		RiderID: riderID,
+		GiverID: giverID,
+		ActorID: currentUserID.(int),
+		Admin:   isAdmin && !isRider && !isGiver,
+	}
+	if req.RiderStatus != nil && *req.RiderStatus == "done" && (isRider || isAdmin) {
+		payment.Status = "sent"
+		err = events.Publish(tx, events.PaymentMarked, payment)
+	}
+	if err == nil && req.GiverStatus != nil && *req.GiverStatus == "received" && (isGiver || isAdmin) {
+		payment.Status = "received"
+		err = events.Publish(tx, events.PaymentMarked, payment)
+	}
+	if err == nil {
+		action := "payment.update"
+		if isAdmin && !isRider && !isGiver {
+			action = "payment.override"
+		}
+		err = audit(c, tx, action, "payment", fmt.Sprintf("%d:%d", rideID, userIDParam), before,
+			snapshot(tx, "payments", "ride_id = $1 AND rider_id = $2", rideID, userIDParam))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
-		return
+		return apierr.Internal("Failed to update payment", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Payment status updated"})
+	return nil
 }
 
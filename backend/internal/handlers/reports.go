@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// CreateReport files an abuse report about a user, message or ride
+func (h *Handlers) CreateReport(c *gin.Context) error {
+	userID, _ := c.Get("user_id")
+
+	var req createReportRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	// Resolve who is being reported and keep a copy of reported content
+	var reportedUserID int
+	var evidence *string
+	var err error
+	switch req.TargetType {
+	case "user":
+		reportedUserID = req.TargetID
+		err = h.DB.QueryRow(`SELECT id FROM users WHERE id = $1`, req.TargetID).Scan(&reportedUserID)
+	case "message":
+		var message string
+		err = h.DB.QueryRow(
+			`SELECT m.user_id, m.message FROM messages m
+			 WHERE m.id = $1
+			   AND EXISTS(SELECT 1 FROM rides WHERE id = m.ride_id AND user_id = $2
+			              UNION
+			              SELECT 1 FROM ride_requests WHERE ride_id = m.ride_id AND user_id = $2 AND status = 'accepted')`,
+			req.TargetID, userID,
+		).Scan(&reportedUserID, &message)
+		evidence = &message
+	case "ride":
+		err = h.DB.QueryRow(`SELECT user_id FROM rides WHERE id = $1`, req.TargetID).Scan(&reportedUserID)
+	}
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Reported " + req.TargetType + " not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if reportedUserID == userID.(int) {
+		return apierr.BadRequest("You can't report yourself")
+	}
+
+	var description *string
+	if d := strings.TrimSpace(req.Description); d != "" {
+		description = &d
+	}
+
+	var reportID int
+	err = h.DB.QueryRow(
+		`INSERT INTO reports (reporter_id, target_type, target_id, reported_user_id, category, description, evidence)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		userID, req.TargetType, req.TargetID, reportedUserID, req.Category, description, evidence,
+	).Scan(&reportID)
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return apierr.Conflict(apierr.CodeDuplicateReport, "You have already reported this and it is under review")
+	}
+	if err != nil {
+		return apierr.Internal("Failed to create report", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": reportID, "message": "Report submitted"})
+	return nil
+}
+
+// reportSorts are the sort keys GetReports accepts. triage puts open and
+// reviewing reports before closed ones and orders each group by age; the
+// fixed-width timestamp keeps the text comparison in time order.
+var reportSorts = listSorts{
+	"triage": {`CASE WHEN rp.status IN ('open', 'reviewing') THEN '0' ELSE '1' END ||
+		to_char(COALESCE(rp.created_at, 'epoch'), 'YYYY-MM-DD"T"HH24:MI:SS.US')`, "text"},
+	"created_at": {"COALESCE(rp.created_at, 'epoch')", "timestamp"},
+}
+
+// GetReports returns a page of the abuse report triage queue, open reports
+// first and oldest first by default (admin only)
+func (h *Handlers) GetReports(c *gin.Context) error {
+	p, err := parsePage(c, reportSorts, "triage", "rp.id")
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT rp.id, rp.reporter_id, u1.name, rp.target_type, rp.target_id,
+		       rp.reported_user_id, u2.name,
+		       (SELECT COUNT(*) FROM reports o
+		        WHERE o.reported_user_id = rp.reported_user_id AND o.status IN ('open', 'reviewing')),
+		       rp.category, rp.description, rp.evidence, rp.status, rp.resolution_note,
+		       rp.resolved_at, rp.created_at` + p.sortColumn() + `
+		FROM reports rp
+		JOIN users u1 ON rp.reporter_id = u1.id
+		LEFT JOIN users u2 ON rp.reported_user_id = u2.id
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	argIndex := 1
+
+	for _, field := range []string{"status", "category", "target_type"} {
+		if v := c.Query(field); v != "" {
+			query += ` AND rp.` + field + ` = $` + strconv.Itoa(argIndex)
+			args = append(args, v)
+			argIndex++
+		}
+	}
+	if v := c.Query("reported_user_id"); v != "" {
+		if _, err := strconv.Atoi(v); err != nil {
+			return apierr.BadRequest("Invalid reported_user_id")
+		}
+		query += ` AND rp.reported_user_id = $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+
+	after, afterArgs := p.where(argIndex)
+	rows, err := h.DB.Query(query+after+p.orderBy(), append(args, afterArgs...)...)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		var r models.Report
+		var sortValue string
+		if err := rows.Scan(
+			&r.ID, &r.ReporterID, &r.ReporterName, &r.TargetType, &r.TargetID,
+			&r.ReportedUserID, &r.ReportedUserName, &r.ReportCount,
+			&r.Category, &r.Description, &r.Evidence, &r.Status, &r.ResolutionNote,
+			&r.ResolvedAt, &r.CreatedAt, &sortValue,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, r.ID) {
+			break
+		}
+		reports = append(reports, r)
+	}
+
+	c.JSON(http.StatusOK, reportsPage{Reports: reports, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// UpdateReport moves a report through triage (admin only)
+func (h *Handlers) UpdateReport(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid report ID")
+	}
+
+	adminID, _ := c.Get("user_id")
+
+	var req updateReportRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	var note *string
+	if req.Note != "" {
+		note = &req.Note
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "reports", "id = $1", id)
+
+	var reporterID int
+	err = tx.QueryRow(
+		`UPDATE reports SET status = $1, resolution_note = COALESCE($2, resolution_note),
+		        resolved_by = CASE WHEN $1 IN ('actioned', 'dismissed') THEN $3::int END,
+		        resolved_at = CASE WHEN $1 IN ('actioned', 'dismissed') THEN CURRENT_TIMESTAMP END,
+		        updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $4
+		 RETURNING reporter_id`,
+		req.Status, note, adminID, id,
+	).Scan(&reporterID)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Report not found")
+	}
+	if err == nil {
+		err = audit(c, tx, "report.update", "report", id, before, snapshot(tx, "reports", "id = $1", id))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to update report", err)
+	}
+
+	if req.Status == "actioned" || req.Status == "dismissed" {
+		h.notify(reporterID, "report_resolved", "Your report was reviewed",
+			"Thanks for letting us know. Our team has reviewed your report and closed it.")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report updated"})
+	return nil
+}
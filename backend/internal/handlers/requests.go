@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"time"
+
+	"cpool.ai/backend/internal/flags"
+	"cpool.ai/backend/internal/notify"
+)
+
+// Request bodies bound by the handlers. They are named types rather than
+// inline structs so the OpenAPI document is generated from the same
+// definitions the handlers validate against.
+
+// Auth
+
+type registerRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	Name     string `json:"name" binding:"required"`
+	Phone    string `json:"phone"`
+	City     string `json:"city"`
+	Gender   string `json:"gender" binding:"omitempty,oneof=female male non_binary undisclosed"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type updateProfileRequest struct {
+	Name   *string `json:"name"`
+	Phone  *string `json:"phone"`
+	City   *string `json:"city"`
+	UPIID  *string `json:"upi_id"`
+	Gender *string `json:"gender"`
+}
+
+// Users
+
+type updateUserRequest struct {
+	Name        *string `json:"name"`
+	Phone       *string `json:"phone"`
+	City        *string `json:"city"`
+	Role        *string `json:"role"`
+	CarbCredits *int    `json:"carbon_credits"`
+	UPIID       *string `json:"upi_id"`
+}
+
+// Cities
+
+type updateCityStatusRequest struct {
+	Status   string     `json:"status" binding:"required,oneof=active locked"`
+	LaunchAt *time.Time `json:"launch_at"`
+}
+
+type updateCitySettingsRequest struct {
+	Timezone *string `json:"timezone"`
+	Currency *string `json:"currency" binding:"omitempty,len=3,uppercase"`
+	Locale   *string `json:"locale"`
+}
+
+type joinWaitlistRequest struct {
+	PreferredFrom string `json:"preferred_from" binding:"required"`
+	PreferredTo   string `json:"preferred_to" binding:"required"`
+	CommuteStart  string `json:"commute_start"`
+	CommuteReturn string `json:"commute_return"`
+}
+
+// Notifications
+
+type updateNotificationPreferencesRequest struct {
+	Preferences []notify.Preference `json:"preferences" binding:"required,min=1"`
+}
+
+type createPushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required,url"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys"`
+}
+
+type deletePushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
+
+// Feature flags
+
+type createFeatureRequest struct {
+	Name              string           `json:"name" binding:"required"`
+	Enabled           bool             `json:"enabled"`
+	Description       string           `json:"description"`
+	RolloutPercentage *int             `json:"rollout_percentage" binding:"omitempty,min=0,max=100"`
+	Targeting         *flags.Targeting `json:"targeting"`
+}
+
+type toggleFeatureRequest struct {
+	Enabled           *bool            `json:"enabled"`
+	Description       *string          `json:"description"`
+	RolloutPercentage *int             `json:"rollout_percentage" binding:"omitempty,min=0,max=100"`
+	Targeting         *flags.Targeting `json:"targeting"`
+}
+
+// Corridors
+
+type createCorridorRequest struct {
+	CityID           int    `json:"city_id" binding:"required"`
+	Name             string `json:"name" binding:"required"`
+	LocationFrom     string `json:"location_from" binding:"required"`
+	LocationTo       string `json:"location_to" binding:"required"`
+	PickupPoints     string `json:"pickup_points"`
+	TermsConditions  string `json:"terms_conditions"`
+	IsActive         bool   `json:"is_active"`
+	MapEnabled       bool   `json:"map_enabled"`
+	WomenOnlyAllowed bool   `json:"women_only_allowed"`
+}
+
+type updateCorridorRequest struct {
+	Name             *string `json:"name"`
+	LocationFrom     *string `json:"location_from"`
+	LocationTo       *string `json:"location_to"`
+	PickupPoints     *string `json:"pickup_points"`
+	TermsConditions  *string `json:"terms_conditions"`
+	IsActive         *bool   `json:"is_active"`
+	MapEnabled       *bool   `json:"map_enabled"`
+	WomenOnlyAllowed *bool   `json:"women_only_allowed"`
+}
+
+type assignCorridorRequest struct {
+	UserID     int `json:"user_id" binding:"required"`
+	CorridorID int `json:"corridor_id" binding:"required"`
+}
+
+// Blocking and reports
+
+type blockUserRequest struct {
+	UserID int `json:"user_id" binding:"required"`
+}
+
+type createReportRequest struct {
+	TargetType  string `json:"target_type" binding:"required,oneof=user message ride"`
+	TargetID    int    `json:"target_id" binding:"required"`
+	Category    string `json:"category" binding:"required,oneof=harassment unsafe_driving inappropriate_content spam fraud no_show other"`
+	Description string `json:"description"`
+}
+
+type updateReportRequest struct {
+	Status string `json:"status" binding:"required,oneof=open reviewing actioned dismissed"`
+	Note   string `json:"note"`
+}
+
+// Safety
+
+type createEmergencyContactRequest struct {
+	Name         string `json:"name" binding:"required"`
+	Phone        string `json:"phone" binding:"required"`
+	Email        string `json:"email" binding:"omitempty,email"`
+	Relationship string `json:"relationship"`
+}
+
+type createShareLinkRequest struct {
+	Hours int `json:"hours" binding:"omitempty,min=1,max=24"`
+}
+
+type raiseSOSRequest struct {
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	AccuracyM *float64 `json:"accuracy_m" binding:"omitempty,min=0"`
+	Message   string   `json:"message"`
+}
+
+type resolveSOSAlertRequest struct {
+	Note string `json:"note" binding:"required"`
+}
+
+// Vehicles
+
+type createVehicleRequest struct {
+	VehicleType           string `json:"vehicle_type" binding:"required,oneof=car bike"`
+	Make                  string `json:"make" binding:"required"`
+	Model                 string `json:"model" binding:"required"`
+	Color                 string `json:"color"`
+	VehicleNumber         string `json:"vehicle_number" binding:"required"`
+	TotalSeats            int    `json:"total_seats" binding:"required,min=1"`
+	DefaultAvailableSeats int    `json:"default_available_seats" binding:"required,min=1"`
+}
+
+type updateVehicleRequest struct {
+	Make                  *string `json:"make"`
+	Model                 *string `json:"model"`
+	Color                 *string `json:"color"`
+	VehicleNumber         *string `json:"vehicle_number"`
+	TotalSeats            *int    `json:"total_seats"`
+	DefaultAvailableSeats *int    `json:"default_available_seats"`
+}
+
+type swapVehicleRequest struct {
+	VehicleID int   `json:"vehicle_id" binding:"required"`
+	RideIDs   []int `json:"ride_ids"`
+}
+
+type reviewVehicleRequest struct {
+	Status string `json:"status" binding:"required,oneof=approved rejected"`
+	Note   string `json:"note"`
+}
+
+// Rides
+
+type createRideRequest struct {
+	CorridorID       int     `json:"corridor_id" binding:"required"`
+	VehicleID        int     `json:"vehicle_id" binding:"required"`
+	RideDate         string  `json:"ride_date" binding:"required"`
+	RideTime         string  `json:"ride_time" binding:"required"`
+	PickupPoint      string  `json:"pickup_point" binding:"required"`
+	DropPoint        string  `json:"drop_point" binding:"required"`
+	RouteDescription string  `json:"route_description"`
+	PricePerSeat     float64 `json:"price_per_seat" binding:"required,min=0"`
+	AvailableSeats   int     `json:"available_seats" binding:"required,min=1"`
+	Visibility       string  `json:"visibility" binding:"omitempty,oneof=everyone women_only"`
+}
+
+type updateRideRequest struct {
+	RideTime         *string  `json:"ride_time"`
+	PickupPoint      *string  `json:"pickup_point"`
+	DropPoint        *string  `json:"drop_point"`
+	RouteDescription *string  `json:"route_description"`
+	PricePerSeat     *float64 `json:"price_per_seat"`
+	AvailableSeats   *int     `json:"available_seats"`
+	Status           *string  `json:"status" binding:"omitempty,oneof=open full completed"`
+	Visibility       *string  `json:"visibility" binding:"omitempty,oneof=everyone women_only"`
+}
+
+type adminCancelRideRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type reassignRideRequest struct {
+	UserID    int `json:"user_id" binding:"required"`
+	VehicleID int `json:"vehicle_id" binding:"required"`
+}
+
+type closeStaleRidesRequest struct {
+	RideIDs []int `json:"ride_ids"`
+}
+
+// Ride requests
+
+type createRideRequestBody struct {
+	SeatsRequested int    `json:"seats_requested" binding:"required,min=1"`
+	Comment        string `json:"comment"`
+}
+
+type updateRideRequestBody struct {
+	Status string `json:"status" binding:"required,oneof=accepted rejected"`
+}
+
+// Messages
+
+type createMessageRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// Payments
+
+type createPaymentRequest struct {
+	RiderID int     `json:"rider_id" binding:"required"`
+	Amount  float64 `json:"amount" binding:"required,min=0"`
+}
+
+type updatePaymentStatusRequest struct {
+	RiderStatus *string `json:"rider_status" binding:"omitempty,oneof=pending done"`
+	GiverStatus *string `json:"giver_status" binding:"omitempty,oneof=pending received"`
+}
+
+// Reviews
+
+type createReviewRequest struct {
+	RevieweeID int      `json:"reviewee_id" binding:"required"`
+	Rating     int      `json:"rating" binding:"required,min=1,max=5"`
+	Tags       []string `json:"tags"`
+	Comment    string   `json:"comment"`
+}
+
+type moderateReviewRequest struct {
+	Status string `json:"status" binding:"required,oneof=visible hidden"`
+	Note   string `json:"note"`
+}
+
+// Webhooks
+
+type createWebhookRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types" binding:"required,min=1"`
+}
+
+type updateWebhookRequest struct {
+	URL         *string  `json:"url"`
+	Description *string  `json:"description"`
+	EventTypes  []string `json:"event_types"`
+	IsActive    *bool    `json:"is_active"`
+}
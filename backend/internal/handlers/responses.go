@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"time"
+
+	"cpool.ai/backend/internal/models"
+	"cpool.ai/backend/internal/notify"
+)
+
+// Response bodies that aren't a model or a list of models. Handlers mostly
+// write these as gin.H; the types describe them for the OpenAPI document
+// and the contract check keeps the two in step.
+
+type healthResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type createdResponse struct {
+	ID      int    `json:"id"`
+	Message string `json:"message"`
+}
+
+// Auth
+
+type registeredUser struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+}
+
+type registerResponse struct {
+	Token string         `json:"token"`
+	User  registeredUser `json:"user"`
+}
+
+type loginResponse struct {
+	Token string      `json:"token"`
+	User  models.User `json:"user"`
+}
+
+// Stats and analytics
+
+type statsResponse struct {
+	RidesToday          int                  `json:"rides_today"`
+	RidesTakenToday     int                  `json:"rides_taken_today"`
+	UsersOnline         int                  `json:"users_online"`
+	OnlineWindowMinutes float64              `json:"online_window_minutes"`
+	OnlineByCity        []models.OnlineCount `json:"online_by_city"`
+	OnlineByCorridor    []models.OnlineCount `json:"online_by_corridor"`
+}
+
+type analyticsResponse struct {
+	TotalUsers      int     `json:"total_users"`
+	TotalRides      int     `json:"total_rides"`
+	ActiveRides     int     `json:"active_rides"`
+	CompletedRides  int     `json:"completed_rides"`
+	TotalRevenue    float64 `json:"total_revenue"`
+	TotalCredits    int     `json:"total_credits"`
+	ActiveCorridors int     `json:"active_corridors"`
+}
+
+type timeSeriesResponse struct {
+	Granularity string                 `json:"granularity"`
+	GroupBy     string                 `json:"group_by"`
+	From        string                 `json:"from"`
+	To          string                 `json:"to"`
+	Buckets     []models.MetricsBucket `json:"buckets"`
+	RefreshedAt *time.Time             `json:"refreshed_at,omitempty"`
+}
+
+// Cities
+
+type cityStatusResponse struct {
+	Message          string     `json:"message"`
+	LaunchAt         *time.Time `json:"launch_at,omitempty"`
+	WaitlistNotified int        `json:"waitlist_notified,omitempty"`
+}
+
+// Notifications
+
+type unreadCountResponse struct {
+	Unread int `json:"unread"`
+}
+
+type markAllReadResponse struct {
+	Message string `json:"message"`
+	Updated int64  `json:"updated"`
+}
+
+type notificationPreferencesResponse struct {
+	Preferences   []notify.Preference `json:"preferences"`
+	PushPublicKey string              `json:"push_public_key"`
+}
+
+// Safety
+
+type shareLinkResponse struct {
+	Token     string    `json:"token"`
+	Path      string    `json:"path"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type revokeShareLinksResponse struct {
+	Message string `json:"message"`
+	Revoked int64  `json:"revoked"`
+}
+
+type raiseSOSResponse struct {
+	ID               int    `json:"id"`
+	Message          string `json:"message"`
+	ContactsNotified int    `json:"contacts_notified"`
+}
+
+// Vehicles
+
+type vehicleCreatedResponse struct {
+	ID            int    `json:"id"`
+	VehicleNumber string `json:"vehicle_number"`
+	Message       string `json:"message"`
+}
+
+type swapVehicleResponse struct {
+	Message      string `json:"message"`
+	RidesUpdated int    `json:"rides_updated"`
+}
+
+// Rides
+
+type adminRidesPage struct {
+	Rides      []models.Ride `json:"rides"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+type adminCancelRideResponse struct {
+	Message        string `json:"message"`
+	RidersNotified int    `json:"riders_notified"`
+}
+
+type closeStaleRidesResponse struct {
+	Completed []int64 `json:"completed"`
+	Cancelled []int64 `json:"cancelled"`
+}
+
+// Reviews
+
+type reviewCandidate struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+type rideReviewsResponse struct {
+	Reviews  []models.Review   `json:"reviews"`
+	ToReview []reviewCandidate `json:"to_review"`
+}
+
+// Audit and jobs
+
+type auditLogPage struct {
+	Entries    []models.AuditEntry `json:"entries"`
+	NextCursor *string             `json:"next_cursor"`
+}
+
+type jobScheduledResponse struct {
+	Message   string    `json:"message"`
+	NextRunAt time.Time `json:"next_run_at"`
+}
+
+// Webhooks
+
+type webhookEventTypesResponse struct {
+	EventTypes []string `json:"event_types"`
+}
+
+type webhookSecretResponse struct {
+	Secret string `json:"secret"`
+}
+
+type webhookTestResponse struct {
+	Message    string `json:"message"`
+	DeliveryID int    `json:"delivery_id"`
+}
+
+type webhookDeliveriesPage struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+	NextCursor *string                  `json:"next_cursor"`
+}
+
+// Pages of cursor-paginated lists; NextCursor is null on the last page
+
+type usersPage struct {
+	Users      []models.User `json:"users"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+type ridesPage struct {
+	Rides      []models.Ride `json:"rides"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+type messagesPage struct {
+	Messages   []models.Message `json:"messages"`
+	NextCursor *string          `json:"next_cursor"`
+}
+
+type corridorsPage struct {
+	Corridors  []models.Corridor `json:"corridors"`
+	NextCursor *string           `json:"next_cursor"`
+}
+
+type paymentsPage struct {
+	Payments   []models.Payment `json:"payments"`
+	NextCursor *string          `json:"next_cursor"`
+}
+
+type notificationsPage struct {
+	Notifications []models.Notification `json:"notifications"`
+	NextCursor    *string               `json:"next_cursor"`
+}
+
+type reviewsPage struct {
+	Reviews    []models.Review `json:"reviews"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+type reportsPage struct {
+	Reports    []models.Report `json:"reports"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+type sosAlertsPage struct {
+	Alerts     []models.SOSAlert `json:"alerts"`
+	NextCursor *string           `json:"next_cursor"`
+}
+
+type waitlistPage struct {
+	Entries    []models.WaitlistEntry `json:"entries"`
+	NextCursor *string                `json:"next_cursor"`
+}
+
+type jobRunsPage struct {
+	Runs       []models.JobRun `json:"runs"`
+	NextCursor *string         `json:"next_cursor"`
+}
@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// reviewTags are the tags a review may carry
+var reviewTags = map[string]bool{
+	"on_time":            true,
+	"safe_driving":       true,
+	"friendly":           true,
+	"clean_vehicle":      true,
+	"good_communication": true,
+	"punctual_payment":   true,
+	"late":               true,
+	"unsafe_driving":     true,
+	"rude":               true,
+	"no_show":            true,
+}
+
+// maxReviewComment caps review comment length
+const maxReviewComment = 1000
+
+// ratingSubquery aggregates visible reviews for the user in userColumn, for
+// use in a LATERAL join exposing average and total
+func ratingSubquery(userColumn string) string {
+	return `SELECT ROUND(AVG(rating), 2)::float8 AS average, COUNT(*) AS total
+	        FROM reviews WHERE reviewee_id = ` + userColumn + ` AND status = 'visible'`
+}
+
+// driverRatingQuery aggregates the rating of a ride's driver
+var driverRatingQuery = ratingSubquery("r.user_id")
+
+// rideParticipants returns the ride's status and the names of its driver and accepted riders
+func (h *Handlers) rideParticipants(rideID int) (string, map[int]string, error) {
+	var status string
+	var driverID int
+	var driverName string
+	err := h.DB.QueryRow(
+		`SELECT r.status, r.user_id, u.name FROM rides r JOIN users u ON r.user_id = u.id WHERE r.id = $1`,
+		rideID,
+	).Scan(&status, &driverID, &driverName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	participants := map[int]string{driverID: driverName}
+
+	rows, err := h.DB.Query(
+		`SELECT rr.user_id, u.name FROM ride_requests rr
+		 JOIN users u ON rr.user_id = u.id
+		 WHERE rr.ride_id = $1 AND rr.status = 'accepted'`,
+		rideID,
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return "", nil, err
+		}
+		participants[id] = name
+	}
+	return status, participants, rows.Err()
+}
+
+// CreateReview rates another participant of a completed ride, once per ride
+func (h *Handlers) CreateReview(c *gin.Context) error {
+	rideID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	userID, _ := c.Get("user_id")
+	reviewerID := userID.(int)
+
+	var req createReviewRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	if req.RevieweeID == reviewerID {
+		return apierr.BadRequest("You can't review yourself")
+	}
+
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range req.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !reviewTags[tag] {
+			return apierr.BadRequest("Unknown review tag: " + tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	req.Comment = strings.TrimSpace(req.Comment)
+	if len(req.Comment) > maxReviewComment {
+		return apierr.BadRequest("Comment is too long")
+	}
+	var comment *string
+	if req.Comment != "" {
+		comment = &req.Comment
+	}
+
+	status, participants, err := h.rideParticipants(rideID)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Ride not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if status != "completed" {
+		return apierr.BadRequest("Rides can only be reviewed once completed")
+	}
+	if _, ok := participants[reviewerID]; !ok {
+		return apierr.Forbidden("You are not part of this ride")
+	}
+	if _, ok := participants[req.RevieweeID]; !ok {
+		return apierr.BadRequest("That user was not part of this ride")
+	}
+
+	var reviewID int
+	err = h.DB.QueryRow(
+		`INSERT INTO reviews (ride_id, reviewer_id, reviewee_id, rating, tags, comment)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (ride_id, reviewer_id, reviewee_id) DO NOTHING
+		 RETURNING id`,
+		rideID, reviewerID, req.RevieweeID, req.Rating, pq.Array(tags), comment,
+	).Scan(&reviewID)
+
+	if err == sql.ErrNoRows {
+		return apierr.Conflict(apierr.CodeDuplicateReview, "You have already reviewed this user for this ride")
+	}
+	if err != nil {
+		return apierr.Internal("Failed to create review", err)
+	}
+
+	h.notify(req.RevieweeID, "review_received", "New review",
+		participants[reviewerID]+" rated you "+strconv.Itoa(req.Rating)+"/5 for a recent ride.")
+
+	c.JSON(http.StatusCreated, gin.H{"id": reviewID, "message": "Review submitted"})
+	return nil
+}
+
+// GetRideReviews returns the reviews the current user left on a ride and who is still to be reviewed
+func (h *Handlers) GetRideReviews(c *gin.Context) error {
+	rideID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	status, participants, err := h.rideParticipants(rideID)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Ride not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if _, ok := participants[userID.(int)]; !ok {
+		return apierr.Forbidden("You are not part of this ride")
+	}
+
+	reviews, err := h.queryReviews(`WHERE rv.ride_id = $1 AND rv.reviewer_id = $2 ORDER BY rv.created_at`, rideID, userID)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	reviewed := map[int]bool{}
+	for _, r := range reviews {
+		reviewed[r.RevieweeID] = true
+	}
+
+	toReview := []gin.H{}
+	if status == "completed" {
+		for id, name := range participants {
+			if id != userID.(int) && !reviewed[id] {
+				toReview = append(toReview, gin.H{"user_id": id, "name": name})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reviews": reviews, "to_review": toReview})
+	return nil
+}
+
+// GetUserReviews returns a user's rating summary and a page of their visible
+// reviews, newest first by default
+func (h *Handlers) GetUserReviews(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid user ID")
+	}
+
+	p, err := parsePage(c, reviewSorts, "-created_at", "rv.id")
+	if err != nil {
+		return err
+	}
+
+	summary := models.RatingSummary{UserID: id, Stars: map[int]int{}, Tags: map[string]int{}}
+
+	err = h.DB.QueryRow(`SELECT average, total FROM (`+ratingSubquery("$1")+`) rt`, id).Scan(&summary.Average, &summary.Count)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	rows, err := h.DB.Query(
+		`SELECT rating, COUNT(*) FROM reviews WHERE reviewee_id = $1 AND status = 'visible' GROUP BY rating`,
+		id,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stars, count int
+		if rows.Scan(&stars, &count) == nil {
+			summary.Stars[stars] = count
+		}
+	}
+
+	tagRows, err := h.DB.Query(
+		`SELECT tag, COUNT(*) FROM reviews, unnest(tags) AS tag
+		 WHERE reviewee_id = $1 AND status = 'visible' GROUP BY tag`,
+		id,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var tag string
+		var count int
+		if tagRows.Scan(&tag, &count) == nil {
+			summary.Tags[tag] = count
+		}
+	}
+
+	summary.Reviews, err = h.pageReviews(p, `WHERE rv.reviewee_id = $1 AND rv.status = 'visible'`, []interface{}{id})
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	summary.NextCursor = p.nextCursor()
+	for i := range summary.Reviews {
+		// Moderation state is only for admins
+		summary.Reviews[i].Status = ""
+		summary.Reviews[i].ModerationNote = nil
+		summary.Reviews[i].ModeratedAt = nil
+	}
+
+	c.JSON(http.StatusOK, summary)
+	return nil
+}
+
+// AdminGetReviews returns a page of reviews for moderation, newest first by
+// default (admin only)
+func (h *Handlers) AdminGetReviews(c *gin.Context) error {
+	p, err := parsePage(c, reviewSorts, "-created_at", "rv.id")
+	if err != nil {
+		return err
+	}
+
+	where := `WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+
+	if status := c.Query("status"); status != "" {
+		where += ` AND rv.status = $` + strconv.Itoa(argIndex)
+		args = append(args, status)
+		argIndex++
+	}
+	for _, filter := range []struct{ param, column string }{
+		{"reviewer_id", "rv.reviewer_id"},
+		{"reviewee_id", "rv.reviewee_id"},
+		{"ride_id", "rv.ride_id"},
+	} {
+		if v := c.Query(filter.param); v != "" {
+			if _, err := strconv.Atoi(v); err != nil {
+				return apierr.BadRequest("Invalid " + filter.param)
+			}
+			where += ` AND ` + filter.column + ` = $` + strconv.Itoa(argIndex)
+			args = append(args, v)
+			argIndex++
+		}
+	}
+	if v := c.Query("max_rating"); v != "" {
+		if _, err := strconv.Atoi(v); err != nil {
+			return apierr.BadRequest("Invalid max_rating")
+		}
+		where += ` AND rv.rating <= $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+	if c.Query("has_comment") == "true" {
+		where += ` AND rv.comment IS NOT NULL`
+	}
+
+	reviews, err := h.pageReviews(p, where, args)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	c.JSON(http.StatusOK, reviewsPage{Reviews: reviews, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// ModerateReview hides or restores a review (admin only)
+func (h *Handlers) ModerateReview(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid review ID")
+	}
+
+	adminID, _ := c.Get("user_id")
+
+	var req moderateReviewRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	var note *string
+	if req.Note != "" {
+		note = &req.Note
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "reviews", "id = $1", id)
+
+	result, err := tx.Exec(
+		`UPDATE reviews SET status = $1, moderation_note = $2, moderated_by = $3,
+		                   moderated_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $4`,
+		req.Status, note, adminID, id,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to moderate review", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Review not found")
+	}
+
+	err = audit(c, tx, "review.moderate", "review", id, before, snapshot(tx, "reviews", "id = $1", id))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to moderate review", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review updated"})
+	return nil
+}
+
+// queryReviews selects reviews with reviewer and reviewee names; clause follows the FROM
+const reviewSelect = `SELECT rv.id, rv.ride_id, rv.reviewer_id, u1.name, rv.reviewee_id, u2.name,
+	        rv.rating, rv.tags, rv.comment, rv.status, rv.moderation_note, rv.moderated_at, rv.created_at`
+
+const reviewFrom = `
+	 FROM reviews rv
+	 JOIN users u1 ON rv.reviewer_id = u1.id
+	 JOIN users u2 ON rv.reviewee_id = u2.id `
+
+func scanReview(scan func(...interface{}) error) (models.Review, error) {
+	var r models.Review
+	var tags pq.StringArray
+	err := scan(
+		&r.ID, &r.RideID, &r.ReviewerID, &r.ReviewerName, &r.RevieweeID, &r.RevieweeName,
+		&r.Rating, &tags, &r.Comment, &r.Status, &r.ModerationNote, &r.ModeratedAt, &r.CreatedAt,
+	)
+	r.Tags = []string(tags)
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+	return r, err
+}
+
+func (h *Handlers) queryReviews(clause string, args ...interface{}) ([]models.Review, error) {
+	rows, err := h.DB.Query(reviewSelect+reviewFrom+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.Review{}
+	for rows.Next() {
+		r, err := scanReview(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
+
+// reviewSorts are the sort keys the review lists accept
+var reviewSorts = listSorts{
+	"created_at": {"COALESCE(rv.created_at, 'epoch')", "timestamp"},
+	"rating":     {"rv.rating", "integer"},
+}
+
+// pageReviews reads one page of reviews matching where, whose placeholders
+// are numbered up to len(args)
+func (h *Handlers) pageReviews(p *page, where string, args []interface{}) ([]models.Review, error) {
+	after, afterArgs := p.where(len(args) + 1)
+	rows, err := h.DB.Query(
+		reviewSelect+p.sortColumn()+reviewFrom+where+after+p.orderBy(),
+		append(args, afterArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.Review{}
+	for rows.Next() {
+		var sortValue string
+		r, err := scanReview(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &sortValue)...)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !p.add(sortValue, r.ID) {
+			break
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
@@ -5,17 +5,27 @@ import (
 	"net/http"
 	"strconv"
 
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/events"
 	"cpool.ai/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetRideRequests returns requests for a ride
-func (h *Handlers) GetRideRequests(c *gin.Context) {
+func (h *Handlers) GetRideRequests(c *gin.Context) error {
 	rideID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	userID, _ := c.Get("user_id")
+	visible, err := h.rideVisible(rideID, userID)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if !visible {
+		return apierr.NotFound("Ride not found")
 	}
 
 	rows, err := h.DB.Query(
@@ -29,72 +39,81 @@ func (h *Handlers) GetRideRequests(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var requests []models.RideRequest
+	requests := []models.RideRequest{}
 	for rows.Next() {
 		var req models.RideRequest
 		if err := rows.Scan(
 			&req.ID, &req.RideID, &req.UserID, &req.UserName, &req.SeatsRequested,
 			&req.Comment, &req.Status, &req.CreatedAt, &req.UpdatedAt,
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+			return apierr.Internal("Database error", err)
 		}
 		requests = append(requests, req)
 	}
 
 	c.JSON(http.StatusOK, requests)
+	return nil
 }
 
 // CreateRideRequest creates a ride request
-func (h *Handlers) CreateRideRequest(c *gin.Context) {
+func (h *Handlers) CreateRideRequest(c *gin.Context) error {
 	rideID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
 	}
 
 	userID, _ := c.Get("user_id")
 
-	var req struct {
-		SeatsRequested int    `json:"seats_requested" binding:"required,min=1"`
-		Comment        string `json:"comment"`
-	}
+	var req createRideRequestBody
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
 	// Check if ride exists and has available seats
 	var availableSeats int
 	var rideUserID int
+	var visibility string
 	err = h.DB.QueryRow(
-		`SELECT available_seats, user_id FROM rides WHERE id = $1 AND status IN ('open', 'partially_filled')`,
+		`SELECT available_seats, user_id, visibility FROM rides WHERE id = $1 AND status IN ('open', 'partially_filled')`,
 		rideID,
-	).Scan(&availableSeats, &rideUserID)
+	).Scan(&availableSeats, &rideUserID, &visibility)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found or not available"})
-		return
+		return apierr.NotFound("Ride not found or not available")
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 
 	if rideUserID == userID {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot request your own ride"})
-		return
+		return apierr.BadRequest("Cannot request your own ride")
+	}
+
+	blocked, err := h.isBlocked(userID, rideUserID)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if blocked {
+		return apierr.Forbidden("You can't request this ride")
+	}
+
+	if visibility == visibilityWomenOnly {
+		female, err := h.isFemale(userID)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !female {
+			return apierr.New(http.StatusForbidden, apierr.CodeWomenOnly, "This ride is only offered to women")
+		}
 	}
 
 	if req.SeatsRequested > availableSeats {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough available seats"})
-		return
+		return apierr.New(http.StatusBadRequest, apierr.CodeInsufficientSeats, "Not enough available seats")
 	}
 
 	// Check if user already has a pending/accepted request
@@ -106,8 +125,7 @@ func (h *Handlers) CreateRideRequest(c *gin.Context) {
 
 	if err == nil {
 		if existingStatus == "pending" || existingStatus == "accepted" {
-			c.JSON(http.StatusConflict, gin.H{"error": "You already have a request for this ride"})
-			return
+			return apierr.Conflict(apierr.CodeDuplicateRequest, "You already have a request for this ride")
 		}
 	}
 
@@ -116,128 +134,175 @@ func (h *Handlers) CreateRideRequest(c *gin.Context) {
 		comment = &req.Comment
 	}
 
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
 	var requestID int
-	err = h.DB.QueryRow(
+	err = tx.QueryRow(
 		`INSERT INTO ride_requests (ride_id, user_id, seats_requested, comment, status)
 		 VALUES ($1, $2, $3, $4, 'pending') RETURNING id`,
 		rideID, userID, req.SeatsRequested, comment,
 	).Scan(&requestID)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-		return
+		return apierr.Internal("Failed to create request", err)
+	}
+
+	err = events.Publish(tx, events.RequestCreated, events.RequestPayload{
+		RequestID: requestID,
+		RideID:    rideID,
+		RiderID:   userID.(int),
+		DriverID:  rideUserID,
+		Seats:     req.SeatsRequested,
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to create request", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"id": requestID, "message": "Ride request created"})
+	return nil
 }
 
 // UpdateRideRequest updates a ride request (accept/reject)
-func (h *Handlers) UpdateRideRequest(c *gin.Context) {
+func (h *Handlers) UpdateRideRequest(c *gin.Context) error {
 	rideID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
 	}
 
 	requestID, err := strconv.Atoi(c.Param("requestId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
-		return
+		return apierr.BadRequest("Invalid request ID")
 	}
 
 	userID, _ := c.Get("user_id")
 
-	var req struct {
-		Status string `json:"status" binding:"required,oneof=accepted rejected"`
-	}
+	var req updateRideRequestBody
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
+	}
+
+	// Status, seats, the payment record and the event commit together
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
 	}
+	defer tx.Rollback()
 
 	// Verify user owns the ride
 	var rideUserID int
-	err = h.DB.QueryRow(`SELECT user_id FROM rides WHERE id = $1`, rideID).Scan(&rideUserID)
+	err = tx.QueryRow(`SELECT user_id FROM rides WHERE id = $1 FOR UPDATE`, rideID).Scan(&rideUserID)
 	if err != nil || rideUserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this ride"})
-		return
+		return apierr.Forbidden("You don't own this ride")
 	}
 
+	before := snapshot(tx, "ride_requests", "id = $1 AND ride_id = $2", requestID, rideID)
+
 	// Get request details
 	var seatsRequested int
 	var currentStatus string
-	err = h.DB.QueryRow(
-		`SELECT seats_requested, status FROM ride_requests WHERE id = $1 AND ride_id = $2`,
+	var riderID int
+	err = tx.QueryRow(
+		`SELECT seats_requested, status, user_id FROM ride_requests WHERE id = $1 AND ride_id = $2 FOR UPDATE`,
 		requestID, rideID,
-	).Scan(&seatsRequested, &currentStatus)
+	).Scan(&seatsRequested, &currentStatus, &riderID)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
-		return
+		return apierr.NotFound("Request not found")
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 
 	// Update request status
-	_, err = h.DB.Exec(
+	_, err = tx.Exec(
 		`UPDATE ride_requests SET status = $1, updated_at = CURRENT_TIMESTAMP 
 		 WHERE id = $2`,
 		req.Status, requestID,
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update request"})
-		return
+		return apierr.Internal("Failed to update request", err)
 	}
 
-	// Update ride available seats if accepted
+	// Take the seats if accepted
 	if req.Status == "accepted" && currentStatus != "accepted" {
-		_, err = h.DB.Exec(
-			`UPDATE rides SET available_seats = available_seats - $1, updated_at = CURRENT_TIMESTAMP
-			 WHERE id = $2`,
+		var pricePerSeat float64
+		err = tx.QueryRow(
+			`UPDATE rides SET available_seats = available_seats - $1,
+			                 status = CASE WHEN available_seats - $1 = 0 THEN 'full' ELSE 'partially_filled' END,
+			                 updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $2 AND available_seats >= $1
+			 RETURNING price_per_seat`,
 			seatsRequested, rideID,
-		)
+		).Scan(&pricePerSeat)
 
+		if err == sql.ErrNoRows {
+			return apierr.Conflict(apierr.CodeInsufficientSeats, "Not enough available seats")
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ride seats"})
-			return
+			return apierr.Internal("Failed to update ride seats", err)
 		}
 
-		// Update ride status
-		h.DB.Exec(`
-			UPDATE rides SET status = CASE
-				WHEN available_seats = 0 THEN 'full'
-				ELSE 'partially_filled'
-			END
-			WHERE id = $1
-		`, rideID)
-
 		// Create payment record
-		var pricePerSeat float64
-		h.DB.QueryRow(`SELECT price_per_seat FROM rides WHERE id = $1`, rideID).Scan(&pricePerSeat)
-		totalAmount := pricePerSeat * float64(seatsRequested)
-
-		var riderID int
-		h.DB.QueryRow(`SELECT user_id FROM ride_requests WHERE id = $1`, requestID).Scan(&riderID)
-
-		h.DB.Exec(
+		_, err = tx.Exec(
 			`INSERT INTO payments (ride_id, rider_id, ride_giver_id, amount, rider_status, giver_status)
 			 VALUES ($1, $2, $3, $4, 'pending', 'pending')
 			 ON CONFLICT (ride_id, rider_id) DO NOTHING`,
-			rideID, riderID, userID, totalAmount,
+			rideID, riderID, userID, pricePerSeat*float64(seatsRequested),
 		)
+		if err != nil {
+			return apierr.Internal("Failed to create payment", err)
+		}
 	} else if req.Status == "rejected" && currentStatus == "accepted" {
-		// If rejecting an accepted request, restore seats
-		_, err = h.DB.Exec(
-			`UPDATE rides SET available_seats = available_seats + $1, updated_at = CURRENT_TIMESTAMP
+		// If rejecting an accepted request, restore seats and reopen the ride
+		_, err = tx.Exec(
+			`UPDATE rides SET available_seats = available_seats + $1,
+			                 status = CASE WHEN status IN ('full', 'partially_filled')
+			                               THEN CASE WHEN available_seats + $1 >= total_seats THEN 'open' ELSE 'partially_filled' END
+			                               ELSE status END,
+			                 updated_at = CURRENT_TIMESTAMP
 			 WHERE id = $2`,
 			seatsRequested, rideID,
 		)
+		if err != nil {
+			return apierr.Internal("Failed to update ride seats", err)
+		}
+	}
+
+	if req.Status != currentStatus {
+		eventType := events.RequestAccepted
+		if req.Status == "rejected" {
+			eventType = events.RequestRejected
+		}
+		err = events.Publish(tx, eventType, events.RequestPayload{
+			RequestID: requestID,
+			RideID:    rideID,
+			RiderID:   riderID,
+			DriverID:  rideUserID,
+			Seats:     seatsRequested,
+		})
+		if err != nil {
+			return apierr.Internal("Failed to update request", err)
+		}
+	}
+
+	err = audit(c, tx, "ride_request."+req.Status, "ride_request", requestID, before, snapshot(tx, "ride_requests", "id = $1", requestID))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to update request", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Request updated"})
+	return nil
 }
 
@@ -6,26 +6,57 @@ import (
 	"strconv"
 	"time"
 
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/events"
 	"cpool.ai/backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetRides returns rides (filtered by various criteria)
-func (h *Handlers) GetRides(c *gin.Context) {
+// rideSorts are the sort keys GetRides accepts
+var rideSorts = listSorts{
+	"departure":       {"r.ride_date + r.ride_time", "timestamp"},
+	"price_per_seat":  {"r.price_per_seat", "numeric"},
+	"available_seats": {"r.available_seats", "integer"},
+}
+
+// GetRides returns a page of rides (filtered by various criteria), soonest
+// departure first by default
+func (h *Handlers) GetRides(c *gin.Context) error {
+	p, err := parsePage(c, rideSorts, "departure", "r.id")
+	if err != nil {
+		return err
+	}
+
 	corridorID := c.Query("corridor_id")
 	date := c.Query("date")
 	status := c.Query("status")
 	userIDParam := c.Query("user_id")
 
+	for _, filter := range []struct{ param, value string }{{"corridor_id", corridorID}, {"user_id", userIDParam}} {
+		if _, err := strconv.Atoi(filter.value); filter.value != "" && err != nil {
+			return apierr.BadRequest("Invalid " + filter.param)
+		}
+	}
+	if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+		return apierr.BadRequest("Invalid date, expected YYYY-MM-DD")
+	}
+	switch status {
+	case "", "open", "partially_filled", "full", "completed", "cancelled":
+	default:
+		return apierr.BadRequest("status must be one of open, partially_filled, full, completed, cancelled")
+	}
+
 	query := `
 		SELECT r.id, r.user_id, u.name as user_name, r.corridor_id, c.name as corridor_name,
-		       r.vehicle_id, r.ride_date, r.ride_time, r.pickup_point, r.drop_point,
+		       r.vehicle_id, r.ride_date, to_char(r.ride_time, 'HH24:MI'), r.pickup_point, r.drop_point,
 		       r.route_description, r.price_per_seat, r.available_seats, r.total_seats,
-		       r.status, r.created_at, r.updated_at
+		       r.status, r.visibility, ci.timezone, ci.currency, dr.average, dr.total, r.created_at, r.updated_at` + p.sortColumn() + `
 		FROM rides r
 		JOIN users u ON r.user_id = u.id
 		JOIN corridors c ON r.corridor_id = c.id
+		JOIN cities ci ON c.city_id = ci.id
+		CROSS JOIN LATERAL (` + driverRatingQuery + `) dr
 		WHERE 1=1
 	`
 	args := []interface{}{}
@@ -42,13 +73,9 @@ func (h *Handlers) GetRides(c *gin.Context) {
 		args = append(args, date)
 		argIndex++
 	} else {
-		// Default to today + next 2 days
-		today := time.Now().Format("2006-01-02")
-		tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
-		dayAfter := time.Now().AddDate(0, 0, 2).Format("2006-01-02")
-		query += ` AND r.ride_date IN ($` + strconv.Itoa(argIndex) + `, $` + strconv.Itoa(argIndex+1) + `, $` + strconv.Itoa(argIndex+2) + `)`
-		args = append(args, today, tomorrow, dayAfter)
-		argIndex += 3
+		// Default to today + next 2 days, in each ride's city local time
+		query += ` AND r.ride_date BETWEEN (CURRENT_TIMESTAMP AT TIME ZONE ci.timezone)::date
+		                              AND (CURRENT_TIMESTAMP AT TIME ZONE ci.timezone)::date + ` + strconv.Itoa(bookingWindowDays)
 	}
 
 	if status != "" {
@@ -65,66 +92,81 @@ func (h *Handlers) GetRides(c *gin.Context) {
 		argIndex++
 	}
 
-	query += ` ORDER BY r.ride_date, r.ride_time`
+	// Hide rides from anyone the viewer has blocked or been blocked by, and
+	// women-only rides from viewers they aren't offered to
+	viewerID, _ := c.Get("user_id")
+	query += notBlockedClause("r.user_id", `$`+strconv.Itoa(argIndex))
+	query += visibleRideClause(`$` + strconv.Itoa(argIndex))
+	args = append(args, viewerID)
+	argIndex++
+
+	after, afterArgs := p.where(argIndex)
+	query += after + p.orderBy()
 
-	rows, err := h.DB.Query(query, args...)
+	rows, err := h.DB.Query(query, append(args, afterArgs...)...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var rides []models.Ride
+	rides := []models.Ride{}
 	for rows.Next() {
 		var ride models.Ride
+		var sortValue string
 		if err := rows.Scan(
 			&ride.ID, &ride.UserID, &ride.UserName, &ride.CorridorID, &ride.CorridorName,
 			&ride.VehicleID, &ride.RideDate, &ride.RideTime, &ride.PickupPoint, &ride.DropPoint,
 			&ride.RouteDescription, &ride.PricePerSeat, &ride.AvailableSeats, &ride.TotalSeats,
-			&ride.Status, &ride.CreatedAt, &ride.UpdatedAt,
+			&ride.Status, &ride.Visibility, &ride.Timezone, &ride.Currency, &ride.DriverRating, &ride.DriverReviews,
+			&ride.CreatedAt, &ride.UpdatedAt, &sortValue,
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, ride.ID) {
+			break
 		}
 		rides = append(rides, ride)
 	}
 
-	c.JSON(http.StatusOK, rides)
+	c.JSON(http.StatusOK, ridesPage{Rides: rides, NextCursor: p.nextCursor()})
+	return nil
 }
 
 // GetRide returns a single ride with details
-func (h *Handlers) GetRide(c *gin.Context) {
+func (h *Handlers) GetRide(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
 	}
 
+	viewerID, _ := c.Get("user_id")
+
 	var ride models.Ride
 	err = h.DB.QueryRow(
 		`SELECT r.id, r.user_id, u.name as user_name, r.corridor_id, c.name as corridor_name,
-		       r.vehicle_id, r.ride_date, r.ride_time, r.pickup_point, r.drop_point,
+		       r.vehicle_id, r.ride_date, to_char(r.ride_time, 'HH24:MI'), r.pickup_point, r.drop_point,
 		       r.route_description, r.price_per_seat, r.available_seats, r.total_seats,
-		       r.status, r.created_at, r.updated_at
+		       r.status, r.visibility, ci.timezone, ci.currency, dr.average, dr.total, r.created_at, r.updated_at
 		 FROM rides r
 		 JOIN users u ON r.user_id = u.id
 		 JOIN corridors c ON r.corridor_id = c.id
-		 WHERE r.id = $1`,
-		id,
+		 JOIN cities ci ON c.city_id = ci.id
+		 CROSS JOIN LATERAL (`+driverRatingQuery+`) dr
+		 WHERE r.id = $1`+visibleRideClause("$2"),
+		id, viewerID,
 	).Scan(
 		&ride.ID, &ride.UserID, &ride.UserName, &ride.CorridorID, &ride.CorridorName,
 		&ride.VehicleID, &ride.RideDate, &ride.RideTime, &ride.PickupPoint, &ride.DropPoint,
 		&ride.RouteDescription, &ride.PricePerSeat, &ride.AvailableSeats, &ride.TotalSeats,
-		&ride.Status, &ride.CreatedAt, &ride.UpdatedAt,
+		&ride.Status, &ride.Visibility, &ride.Timezone, &ride.Currency, &ride.DriverRating, &ride.DriverReviews,
+		&ride.CreatedAt, &ride.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
-		return
+		return apierr.NotFound("Ride not found")
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 
 	// Get vehicle info if available
@@ -146,62 +188,59 @@ func (h *Handlers) GetRide(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, ride)
+	return nil
 }
 
 // CreateRide creates a new ride
-func (h *Handlers) CreateRide(c *gin.Context) {
+func (h *Handlers) CreateRide(c *gin.Context) error {
 	userID, _ := c.Get("user_id")
 
-	var req struct {
-		CorridorID       int     `json:"corridor_id" binding:"required"`
-		VehicleID        int     `json:"vehicle_id" binding:"required"`
-		RideDate         string  `json:"ride_date" binding:"required"`
-		RideTime         string  `json:"ride_time" binding:"required"`
-		PickupPoint      string  `json:"pickup_point" binding:"required"`
-		DropPoint        string  `json:"drop_point" binding:"required"`
-		RouteDescription string  `json:"route_description"`
-		PricePerSeat     float64 `json:"price_per_seat" binding:"required,min=0"`
-		AvailableSeats   int     `json:"available_seats" binding:"required,min=1"`
-	}
+	var req createRideRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
-	// Validate date (today or next 2 days only)
-	today := time.Now()
-	rideDate, err := time.Parse("2006-01-02", req.RideDate)
+	// Validate date (today or next 2 days only) in the corridor's city time
+	loc, err := h.corridorLocation(req.CorridorID)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Corridor not found")
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 
-	daysDiff := int(rideDate.Sub(today).Hours() / 24)
-	if daysDiff < 0 || daysDiff > 2 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ride date must be today or within next 2 days"})
-		return
+	departure, err := parseRideDeparture(loc, req.RideDate, req.RideTime)
+	if err != nil {
+		return apierr.BadRequest(err.Error())
+	}
+
+	if err := validateRideSchedule(loc, departure); err != nil {
+		return apierr.BadRequest(err.Error())
 	}
 
 	// Get vehicle to get total seats
 	var totalSeats int
+	var verificationStatus string
 	err = h.DB.QueryRow(
-		`SELECT total_seats FROM vehicles WHERE id = $1 AND user_id = $2`,
+		`SELECT total_seats, verification_status FROM vehicles
+		 WHERE id = $1 AND user_id = $2 AND archived_at IS NULL`,
 		req.VehicleID, userID,
-	).Scan(&totalSeats)
+	).Scan(&totalSeats, &verificationStatus)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
-		return
+		return apierr.NotFound("Vehicle not found")
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
+	}
+
+	if verificationStatus != "verified" {
+		return apierr.New(http.StatusForbidden, apierr.CodeVehicleUnverified, "Vehicle must be verified before offering rides")
 	}
 
 	if req.AvailableSeats > totalSeats {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Available seats cannot exceed vehicle capacity"})
-		return
+		return apierr.BadRequest("Available seats cannot exceed vehicle capacity")
 	}
 
 	// Verify user has access to corridor
@@ -212,8 +251,20 @@ func (h *Handlers) CreateRide(c *gin.Context) {
 	).Scan(&hasAccess)
 
 	if err != nil || !hasAccess {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this corridor"})
-		return
+		return apierr.Forbidden("You don't have access to this corridor")
+	}
+
+	if req.Visibility == "" {
+		req.Visibility = visibilityEveryone
+	}
+	if req.Visibility == visibilityWomenOnly {
+		denial, err := h.womenOnlyDenial(req.CorridorID, userID)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if denial != "" {
+			return apierr.New(http.StatusForbidden, apierr.CodeWomenOnly, denial)
+		}
 	}
 
 	var routeDesc *string
@@ -221,48 +272,56 @@ func (h *Handlers) CreateRide(c *gin.Context) {
 		routeDesc = &req.RouteDescription
 	}
 
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
 	var rideID int
-	err = h.DB.QueryRow(
+	err = tx.QueryRow(
 		`INSERT INTO rides (user_id, corridor_id, vehicle_id, ride_date, ride_time,
 		                   pickup_point, drop_point, route_description, price_per_seat,
-		                   available_seats, total_seats, status)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 'open') RETURNING id`,
+		                   available_seats, total_seats, status, visibility)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 'open', $12) RETURNING id`,
 		userID, req.CorridorID, req.VehicleID, req.RideDate, req.RideTime,
 		req.PickupPoint, req.DropPoint, routeDesc, req.PricePerSeat,
-		req.AvailableSeats, totalSeats,
+		req.AvailableSeats, totalSeats, req.Visibility,
 	).Scan(&rideID)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ride"})
-		return
+		return apierr.Internal("Failed to create ride", err)
+	}
+
+	err = events.Publish(tx, events.RideCreated, events.RidePayload{
+		RideID:   rideID,
+		DriverID: userID.(int),
+		RideDate: departure.Format("2006-01-02"),
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to create ride", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"id": rideID, "message": "Ride created"})
+	return nil
 }
 
 // UpdateRide updates a ride
-func (h *Handlers) UpdateRide(c *gin.Context) {
+func (h *Handlers) UpdateRide(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
 	}
 
 	userID, _ := c.Get("user_id")
 
-	var req struct {
-		RideTime         *string  `json:"ride_time"`
-		PickupPoint      *string  `json:"pickup_point"`
-		DropPoint        *string  `json:"drop_point"`
-		RouteDescription *string  `json:"route_description"`
-		PricePerSeat     *float64 `json:"price_per_seat"`
-		AvailableSeats   *int     `json:"available_seats"`
-		Status           *string  `json:"status"`
-	}
+	var req updateRideRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
 	updates := []string{}
@@ -270,7 +329,10 @@ func (h *Handlers) UpdateRide(c *gin.Context) {
 	argIndex := 1
 
 	if req.RideTime != nil {
-		updates = append(updates, "ride_time = $"+strconv.Itoa(argIndex))
+		if _, err := time.Parse("15:04", *req.RideTime); err != nil {
+			return apierr.BadRequest("Invalid time format, expected HH:MM")
+		}
+		updates = append(updates, "ride_time = $"+strconv.Itoa(argIndex), "reminder_sent_at = NULL")
 		args = append(args, *req.RideTime)
 		argIndex++
 	}
@@ -304,10 +366,43 @@ func (h *Handlers) UpdateRide(c *gin.Context) {
 		args = append(args, *req.Status)
 		argIndex++
 	}
+	if req.Visibility != nil {
+		if *req.Visibility == visibilityWomenOnly {
+			var corridorID int
+			var otherRiders bool
+			err := h.DB.QueryRow(
+				`SELECT r.corridor_id,
+				        EXISTS(SELECT 1 FROM ride_requests rr JOIN users u ON rr.user_id = u.id
+				               WHERE rr.ride_id = r.id AND rr.status IN ('pending', 'accepted')
+				                 AND u.gender IS DISTINCT FROM 'female')
+				 FROM rides r WHERE r.id = $1 AND r.user_id = $2`,
+				id, userID,
+			).Scan(&corridorID, &otherRiders)
+			if err == sql.ErrNoRows {
+				return apierr.NotFound("Ride not found")
+			}
+			if err != nil {
+				return apierr.Internal("Database error", err)
+			}
+
+			denial, err := h.womenOnlyDenial(corridorID, userID)
+			if err != nil {
+				return apierr.Internal("Database error", err)
+			}
+			if denial == "" && otherRiders {
+				denial = "This ride already has requests from riders it wouldn't be offered to"
+			}
+			if denial != "" {
+				return apierr.New(http.StatusForbidden, apierr.CodeWomenOnly, denial)
+			}
+		}
+		updates = append(updates, "visibility = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.Visibility)
+		argIndex++
+	}
 
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
-		return
+		return apierr.BadRequest("No fields to update")
 	}
 
 	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
@@ -319,48 +414,164 @@ func (h *Handlers) UpdateRide(c *gin.Context) {
 	}
 	query += ` WHERE id = $` + strconv.Itoa(argIndex) + ` AND user_id = $` + strconv.Itoa(argIndex+1)
 
-	_, err = h.DB.Exec(query, args...)
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "rides", "id = $1 AND user_id = $2", id, userID)
+
+	var status, rideDate, timezone string
+	err = tx.QueryRow(
+		`SELECT r.status, to_char(r.ride_date, 'YYYY-MM-DD'), ci.timezone
+		 FROM rides r
+		 JOIN corridors co ON r.corridor_id = co.id
+		 JOIN cities ci ON co.city_id = ci.id
+		 WHERE r.id = $1 AND r.user_id = $2
+		 FOR UPDATE OF r`,
+		id, userID,
+	).Scan(&status, &rideDate, &timezone)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Ride not found")
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ride"})
-		return
+		return apierr.Internal("Database error", err)
+	}
+	if req.Status != nil && *req.Status != status && (status == "completed" || status == "cancelled") {
+		return apierr.Conflict(apierr.CodeRideClosed, "Ride is already "+status)
+	}
+
+	// A new time must still leave the ride in the booking window, as on CreateRide
+	if req.RideTime != nil {
+		loc, err := loadLocation(timezone)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		departure, err := parseRideDeparture(loc, rideDate, *req.RideTime)
+		if err != nil {
+			return apierr.BadRequest(err.Error())
+		}
+		if err := validateRideSchedule(loc, departure); err != nil {
+			return apierr.BadRequest(err.Error())
+		}
 	}
 
-	// Update status based on available seats
-	if req.AvailableSeats != nil || req.Status == nil {
-		h.DB.Exec(`
+	if _, err := tx.Exec(query, args...); err != nil {
+		return apierr.Internal("Failed to update ride", err)
+	}
+
+	// Update status based on available seats unless the driver set it
+	if req.Status == nil {
+		_, err = tx.Exec(`
 			UPDATE rides SET status = CASE
 				WHEN available_seats = 0 THEN 'full'
 				WHEN available_seats < total_seats THEN 'partially_filled'
 				ELSE 'open'
 			END
-			WHERE id = $1
+			WHERE id = $1 AND status NOT IN ('completed', 'cancelled')
 		`, id)
+		if err != nil {
+			return apierr.Internal("Failed to update ride", err)
+		}
+	}
+
+	// Completed rides award carbon credits and reach metrics and webhooks
+	// through RideCompleted
+	if req.Status != nil && *req.Status == "completed" && status != "completed" {
+		riderIDs, err := rideRiders(tx, id, "accepted")
+		if err == nil {
+			err = events.Publish(tx, events.RideCompleted, events.RidePayload{
+				RideID:   id,
+				DriverID: userID.(int),
+				RideDate: rideDate,
+				RiderIDs: riderIDs,
+			})
+		}
+		if err != nil {
+			return apierr.Internal("Failed to update ride", err)
+		}
+	}
+
+	if err := audit(c, tx, "ride.update", "ride", id, before, snapshot(tx, "rides", "id = $1", id)); err != nil {
+		return apierr.Internal("Failed to update ride", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apierr.Internal("Failed to update ride", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Ride updated"})
+	return nil
 }
 
 // CancelRide cancels a ride
-func (h *Handlers) CancelRide(c *gin.Context) {
+func (h *Handlers) CancelRide(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
-		return
+		return apierr.BadRequest("Invalid ride ID")
 	}
 
 	userID, _ := c.Get("user_id")
 
-	_, err = h.DB.Exec(
-		`UPDATE rides SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP 
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "rides", "id = $1 AND user_id = $2", id, userID)
+
+	var status, rideDate string
+	err = tx.QueryRow(
+		`SELECT status, to_char(ride_date, 'YYYY-MM-DD') FROM rides WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		id, userID,
+	).Scan(&status, &rideDate)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"message": "Ride cancelled"})
+		return nil
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE rides SET status = 'cancelled', cancelled_by = $2, cancelled_at = CURRENT_TIMESTAMP,
+		                 updated_at = CURRENT_TIMESTAMP
 		 WHERE id = $1 AND user_id = $2`,
 		id, userID,
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel ride"})
-		return
+		return apierr.Internal("Failed to cancel ride", err)
+	}
+
+	if status != "cancelled" {
+		riderIDs, err := rideRiders(tx, id, "pending", "accepted")
+		if err == nil {
+			err = events.Publish(tx, events.RideCancelled, events.RidePayload{
+				RideID:      id,
+				DriverID:    userID.(int),
+				RideDate:    rideDate,
+				RiderIDs:    riderIDs,
+				CancelledBy: "driver",
+			})
+		}
+		if err != nil {
+			return apierr.Internal("Failed to cancel ride", err)
+		}
+	}
+
+	if err := audit(c, tx, "ride.cancel", "ride", id, before, snapshot(tx, "rides", "id = $1", id)); err != nil {
+		return apierr.Internal("Failed to cancel ride", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apierr.Internal("Failed to cancel ride", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Ride cancelled"})
+	return nil
 }
 
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/middleware"
+	"cpool.ai/backend/internal/presence"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the API under /api. Routes added here need an entry
+// in endpoints so they appear in the OpenAPI document.
+func (h *Handlers) RegisterRoutes(router *gin.Engine, tracker *presence.Tracker) {
+	router.NoRoute(handle(func(c *gin.Context) error {
+		return apierr.NotFound("Route not found")
+	}))
+
+	// Public routes
+	api := router.Group("/api")
+	{
+		api.GET("/health", HealthCheck)
+		api.GET("/openapi.json", GetOpenAPISpec)
+		api.POST("/auth/register", middleware.RateLimit(h.Limiter, "register", registerLimits), handle(h.Register))
+		api.POST("/auth/login", middleware.RateLimit(h.Limiter, "login", loginLimits), handle(h.Login))
+		api.GET("/share/:token", handle(h.GetSharedRide))
+	}
+
+	// Protected routes
+	protected := api.Group("")
+	protected.Use(middleware.AuthMiddleware(h.Config.JWTSecret), middleware.PresenceMiddleware(tracker))
+	{
+		// Auth
+		protected.GET("/auth/profile", handle(h.GetProfile))
+		protected.PUT("/auth/profile", handle(h.UpdateProfile))
+
+		// Stats
+		protected.GET("/stats", handle(h.GetStats))
+
+		// Cities
+		protected.GET("/cities", handle(h.GetCities))
+		protected.PUT("/cities/:id/status", middleware.AdminMiddleware(), handle(h.UpdateCityStatus))
+		protected.POST("/cities/:id/waitlist", handle(h.JoinWaitlist))
+		protected.DELETE("/cities/:id/waitlist", handle(h.LeaveWaitlist))
+
+		// Notifications
+		protected.GET("/notifications", handle(h.GetNotifications))
+		protected.GET("/notifications/unread-count", handle(h.GetUnreadNotificationCount))
+		protected.PUT("/notifications/read-all", handle(h.MarkAllNotificationsRead))
+		protected.PUT("/notifications/:id/read", handle(h.MarkNotificationRead))
+		protected.GET("/notifications/preferences", handle(h.GetNotificationPreferences))
+		protected.PUT("/notifications/preferences", handle(h.UpdateNotificationPreferences))
+		protected.POST("/notifications/push-subscriptions", handle(h.CreatePushSubscription))
+		protected.DELETE("/notifications/push-subscriptions", handle(h.DeletePushSubscription))
+
+		// Feature flags evaluated for the current user
+		protected.GET("/features", handle(h.GetFeatures))
+
+		// Corridors
+		protected.GET("/corridors", handle(h.GetCorridors))
+		protected.GET("/corridors/:id", handle(h.GetCorridor))
+		protected.POST("/corridors", middleware.AdminMiddleware(), handle(h.CreateCorridor))
+		protected.PUT("/corridors/:id", middleware.AdminMiddleware(), handle(h.UpdateCorridor))
+		protected.DELETE("/corridors/:id", middleware.AdminMiddleware(), handle(h.DeleteCorridor))
+
+		// User corridors
+		protected.GET("/user/corridors", handle(h.GetUserCorridors))
+		protected.POST("/user/corridors", middleware.AdminMiddleware(), handle(h.AssignCorridor))
+
+		// Blocking and reports
+		protected.GET("/user/blocks", handle(h.GetBlockedUsers))
+		protected.POST("/user/blocks", handle(h.BlockUser))
+		protected.DELETE("/user/blocks/:userId", handle(h.UnblockUser))
+		protected.POST("/reports", handle(h.CreateReport))
+
+		// Safety
+		protected.GET("/user/emergency-contacts", handle(h.GetEmergencyContacts))
+		protected.POST("/user/emergency-contacts", handle(h.CreateEmergencyContact))
+		protected.DELETE("/user/emergency-contacts/:contactId", handle(h.DeleteEmergencyContact))
+		protected.POST("/rides/:id/sos", handle(h.RaiseSOS))
+		protected.POST("/rides/:id/share", handle(h.CreateShareLink))
+		protected.DELETE("/rides/:id/share", handle(h.RevokeShareLinks))
+
+		// Vehicles
+		protected.GET("/vehicles", handle(h.GetVehicles))
+		protected.GET("/vehicles/:id", handle(h.GetVehicle))
+		protected.POST("/vehicles", handle(h.CreateVehicle))
+		protected.PUT("/vehicles/:id", handle(h.UpdateVehicle))
+		protected.DELETE("/vehicles/:id", handle(h.DeleteVehicle))
+		protected.POST("/vehicles/:id/swap", handle(h.SwapVehicle))
+		protected.GET("/vehicles/:id/documents", handle(h.GetVehicleDocuments))
+		protected.POST("/vehicles/:id/documents", handle(h.UploadVehicleDocument))
+		protected.GET("/vehicles/:id/documents/:docId/file", handle(h.DownloadVehicleDocument))
+
+		// Rides
+		protected.GET("/rides", handle(h.GetRides))
+		protected.GET("/rides/:id", handle(h.GetRide))
+		protected.POST("/rides", handle(h.CreateRide))
+		protected.PUT("/rides/:id", handle(h.UpdateRide))
+		protected.DELETE("/rides/:id", handle(h.CancelRide))
+
+		// Ride requests
+		protected.GET("/rides/:id/requests", handle(h.GetRideRequests))
+		protected.POST("/rides/:id/requests", middleware.RateLimit(h.Limiter, "ride_requests", rideRequestLimits), handle(h.CreateRideRequest))
+		protected.PUT("/rides/:id/requests/:requestId", handle(h.UpdateRideRequest))
+
+		// Messages
+		protected.GET("/rides/:id/messages", handle(h.GetMessages))
+		protected.POST("/rides/:id/messages", middleware.RateLimit(h.Limiter, "messages", messageLimits), handle(h.CreateMessage))
+
+		// Payments
+		protected.GET("/rides/:id/payments", handle(h.GetPayments))
+		protected.POST("/rides/:id/payments", handle(h.CreatePayment))
+		protected.PUT("/rides/:id/payments/:userId", handle(h.UpdatePaymentStatus))
+
+		// Reviews
+		protected.GET("/rides/:id/reviews", handle(h.GetRideReviews))
+		protected.POST("/rides/:id/reviews", handle(h.CreateReview))
+		protected.GET("/users/:id/reviews", handle(h.GetUserReviews))
+
+		// Admin routes
+		admin := protected.Group("/admin")
+		admin.Use(middleware.AdminMiddleware())
+		{
+			admin.GET("/users", handle(h.GetAllUsers))
+			admin.PUT("/users/:id", handle(h.UpdateUser))
+			admin.GET("/rides", handle(h.AdminGetRides))
+			admin.POST("/rides/close-stale", handle(h.CloseStaleRides))
+			admin.GET("/rides/:id/timeline", handle(h.GetRideTimeline))
+			admin.POST("/rides/:id/cancel", handle(h.AdminCancelRide))
+			admin.POST("/rides/:id/reassign", handle(h.ReassignRide))
+			admin.GET("/analytics", handle(h.GetAnalytics))
+			admin.GET("/analytics/timeseries", handle(h.GetAnalyticsTimeSeries))
+			admin.POST("/analytics/refresh", handle(h.RefreshAnalytics))
+			admin.GET("/features", handle(h.ListFeatures))
+			admin.POST("/features", handle(h.CreateFeature))
+			admin.PUT("/features/:name", handle(h.ToggleFeature))
+			admin.DELETE("/features/:name", handle(h.DeleteFeature))
+			admin.GET("/jobs", handle(h.GetJobs))
+			admin.GET("/jobs/runs", handle(h.GetJobRuns))
+			admin.POST("/jobs/:name/run", handle(h.RunJob))
+			admin.GET("/webhooks", handle(h.GetWebhooks))
+			admin.POST("/webhooks", handle(h.CreateWebhook))
+			admin.GET("/webhooks/event-types", handle(h.GetWebhookEventTypes))
+			admin.GET("/webhooks/deliveries", handle(h.GetWebhookDeliveries))
+			admin.GET("/webhooks/deliveries/:id", handle(h.GetWebhookDelivery))
+			admin.POST("/webhooks/deliveries/:id/redeliver", handle(h.RedeliverWebhook))
+			admin.PUT("/webhooks/:id", handle(h.UpdateWebhook))
+			admin.DELETE("/webhooks/:id", handle(h.DeleteWebhook))
+			admin.POST("/webhooks/:id/rotate-secret", handle(h.RotateWebhookSecret))
+			admin.POST("/webhooks/:id/test", handle(h.TestWebhook))
+			admin.GET("/audit", handle(h.GetAuditLog))
+			admin.GET("/audit/export", handle(h.ExportAuditLog))
+			admin.PUT("/cities/:id", handle(h.UpdateCitySettings))
+			admin.GET("/cities/:id/waitlist", handle(h.GetWaitlist))
+			admin.GET("/cities/:id/demand", handle(h.GetCityDemand))
+			admin.GET("/vehicles/review", handle(h.GetVehicleReviewQueue))
+			admin.PUT("/vehicles/:id/review", handle(h.ReviewVehicle))
+			admin.GET("/reviews", handle(h.AdminGetReviews))
+			admin.PUT("/reviews/:id", handle(h.ModerateReview))
+			admin.GET("/reports", handle(h.GetReports))
+			admin.PUT("/reports/:id", handle(h.UpdateReport))
+			admin.GET("/sos", handle(h.GetSOSAlerts))
+			admin.PUT("/sos/:id/resolve", handle(h.ResolveSOSAlert))
+		}
+	}
+}
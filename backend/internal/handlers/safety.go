@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/flags"
+	"cpool.ai/backend/internal/models"
+	"cpool.ai/backend/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultShareHours is how long a share link lasts when no duration is given
+	defaultShareHours = 4
+	// maxShareHours caps how long a share link can last
+	maxShareHours = 24
+	// sosShareHours is how long the share link sent with an SOS lasts
+	sosShareHours = 12
+)
+
+// newShareToken returns a random URL-safe token and the hash stored for it
+func newShareToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashShareToken(token), nil
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createShareLink stores a share link for a ride and returns its token and expiry
+func (h *Handlers) createShareLink(rideID, userID int, hours int) (string, time.Time, error) {
+	token, hash, err := newShareToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var expiresAt time.Time
+	err = h.DB.QueryRow(
+		`INSERT INTO ride_shares (token_hash, ride_id, user_id, expires_at)
+		 VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(hours => $4))
+		 RETURNING expires_at`,
+		hash, rideID, userID, hours,
+	).Scan(&expiresAt)
+	return token, expiresAt, err
+}
+
+// sharePath is the public API path for a share token
+func sharePath(token string) string {
+	return "/api/share/" + token
+}
+
+// shareURL is the absolute link for a share token, for use outside the app
+func (h *Handlers) shareURL(token string) string {
+	return h.Config.PublicURL + sharePath(token)
+}
+
+// CreateShareLink creates a time-limited public link to a ride for a trusted contact
+func (h *Handlers) CreateShareLink(c *gin.Context) error {
+	rideID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var req createShareLinkRequest
+
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return apierr.Validation(err)
+		}
+	}
+	if req.Hours == 0 {
+		req.Hours = defaultShareHours
+	}
+
+	status, participants, err := h.rideParticipants(rideID)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Ride not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if _, ok := participants[userID.(int)]; !ok {
+		return apierr.Forbidden("You are not part of this ride")
+	}
+	if status == "cancelled" {
+		return apierr.BadRequest("Ride has been cancelled")
+	}
+
+	token, expiresAt, err := h.createShareLink(rideID, userID.(int), req.Hours)
+	if err != nil {
+		return apierr.Internal("Failed to create share link", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"path":       sharePath(token),
+		"url":        h.shareURL(token),
+		"expires_at": expiresAt,
+	})
+	return nil
+}
+
+// RevokeShareLinks revokes every active share link the current user created for a ride
+func (h *Handlers) RevokeShareLinks(c *gin.Context) error {
+	rideID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	result, err := h.DB.Exec(
+		`UPDATE ride_shares SET revoked_at = CURRENT_TIMESTAMP
+		 WHERE ride_id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`,
+		rideID, userID,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to revoke share links", err)
+	}
+
+	n, _ := result.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{"message": "Share links revoked", "revoked": n})
+	return nil
+}
+
+// GetSharedRide shows a ride to anyone holding a valid share link (no authentication)
+//
+// Women-only visibility doesn't apply: the viewer is anonymous, and the
+// link only exists because a participant chose to share the ride.
+func (h *Handlers) GetSharedRide(c *gin.Context) error {
+	hash := hashShareToken(c.Param("token"))
+
+	var shared models.SharedRide
+	var corridorID, cityID int
+	var vehicleID *int
+	err := h.DB.QueryRow(
+		`SELECT r.id, sharer.name, driver.name, dr.average, co.id, co.city_id, co.name,
+		        to_char(r.ride_date, 'YYYY-MM-DD'), to_char(r.ride_time, 'HH24:MI'), ci.timezone,
+		        r.pickup_point, r.drop_point, r.status, r.vehicle_id, rs.expires_at,
+		        EXISTS(SELECT 1 FROM sos_alerts WHERE ride_id = r.id AND status = 'open')
+		 FROM ride_shares rs
+		 JOIN rides r ON rs.ride_id = r.id
+		 JOIN users sharer ON rs.user_id = sharer.id
+		 JOIN users driver ON r.user_id = driver.id
+		 JOIN corridors co ON r.corridor_id = co.id
+		 JOIN cities ci ON co.city_id = ci.id
+		 CROSS JOIN LATERAL (`+driverRatingQuery+`) dr
+		 WHERE rs.token_hash = $1 AND rs.revoked_at IS NULL AND rs.expires_at > CURRENT_TIMESTAMP`,
+		hash,
+	).Scan(
+		&shared.RideID, &shared.SharedBy, &shared.DriverName, &shared.DriverRating,
+		&corridorID, &cityID, &shared.CorridorName,
+		&shared.RideDate, &shared.RideTime, &shared.Timezone,
+		&shared.PickupPoint, &shared.DropPoint, &shared.Status, &vehicleID, &shared.ExpiresAt,
+		&shared.SOSActive,
+	)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("This link is invalid or has expired")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	shared.Phase = shared.Status
+	if shared.Status != "cancelled" && shared.Status != "completed" {
+		shared.Phase = "scheduled"
+		if loc, err := loadLocation(shared.Timezone); err == nil {
+			if departure, err := parseRideDeparture(loc, shared.RideDate, shared.RideTime); err == nil && time.Now().After(departure) {
+				shared.Phase = "in_progress"
+			}
+		}
+	}
+
+	if vehicleID != nil {
+		var vehicle models.Vehicle
+		err = h.DB.QueryRow(
+			`SELECT vehicle_type, make, model, color, vehicle_number FROM vehicles WHERE id = $1`,
+			*vehicleID,
+		).Scan(&vehicle.VehicleType, &vehicle.Make, &vehicle.Model, &vehicle.Color, &vehicle.VehicleNumber)
+		if err == nil {
+			shared.Vehicle = &vehicle
+		}
+	}
+
+	// Live location is gated by the live_tracking flag for the ride's corridor
+	if h.Flags.Enabled("live_tracking", flags.Subject{CityID: cityID, CorridorID: corridorID}) {
+		var location models.Location
+		err = h.DB.QueryRow(
+			`SELECT latitude, longitude, accuracy_m, created_at FROM sos_alerts
+			 WHERE ride_id = $1 AND latitude IS NOT NULL AND longitude IS NOT NULL
+			 ORDER BY created_at DESC LIMIT 1`,
+			shared.RideID,
+		).Scan(&location.Latitude, &location.Longitude, &location.AccuracyM, &location.RecordedAt)
+		if err == nil {
+			shared.LastLocation = &location
+		}
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, shared)
+	return nil
+}
+
+// RaiseSOS records an emergency during a ride and alerts the user's emergency contacts and admins
+func (h *Handlers) RaiseSOS(c *gin.Context) error {
+	rideID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid ride ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var req raiseSOSRequest
+
+	// An SOS must never be rejected for a malformed body; record what we can
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("SOS from user %v on ride %d had an invalid body: %v", userID, rideID, err)
+		req.Latitude, req.Longitude, req.AccuracyM = nil, nil, nil
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		req.Latitude, req.Longitude = nil, nil
+	}
+
+	_, participants, err := h.rideParticipants(rideID)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Ride not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	userName, ok := participants[userID.(int)]
+	if !ok {
+		return apierr.Forbidden("You are not part of this ride")
+	}
+
+	var message *string
+	if m := strings.TrimSpace(req.Message); m != "" {
+		message = &m
+	}
+
+	var alertID int
+	err = h.DB.QueryRow(
+		`INSERT INTO sos_alerts (user_id, ride_id, latitude, longitude, accuracy_m, message)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		userID, rideID, req.Latitude, req.Longitude, req.AccuracyM, message,
+	).Scan(&alertID)
+	if err != nil {
+		return apierr.Internal("Failed to record SOS", err)
+	}
+
+	body := userName + " raised an SOS during a ride."
+	if req.Latitude != nil {
+		body += fmt.Sprintf(" Last known location: https://maps.google.com/?q=%.6f,%.6f.", *req.Latitude, *req.Longitude)
+	}
+	if token, _, err := h.createShareLink(rideID, userID.(int), sosShareHours); err == nil {
+		body += " Follow the ride: " + h.shareURL(token)
+	} else {
+		log.Printf("Failed to create share link for SOS %d: %v", alertID, err)
+	}
+	if message != nil {
+		body += " Message: " + *message
+	}
+
+	notified := h.alertEmergencyContacts(userID.(int), alertID, body)
+
+	h.DB.Exec(`UPDATE sos_alerts SET contacts_notified = $1 WHERE id = $2`, notified, alertID)
+
+	admins, err := h.DB.Query(`SELECT id FROM users WHERE role = 'admin'`)
+	if err == nil {
+		defer admins.Close()
+		for admins.Next() {
+			var adminID int
+			if admins.Scan(&adminID) == nil {
+				h.notify(adminID, "sos", "SOS alert #"+strconv.Itoa(alertID), body)
+			}
+		}
+	} else {
+		log.Printf("Failed to notify admins of SOS %d: %v", alertID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":                alertID,
+		"message":           "SOS raised. Your emergency contacts and our safety team have been alerted.",
+		"contacts_notified": notified,
+	})
+	return nil
+}
+
+// alertEmergencyContacts emails a user's emergency contacts about an SOS at
+// the address the user stored for them, and returns how many were reached.
+// Contacts are never matched to accounts: phone numbers and emails on
+// accounts are self-declared, so matching would let anyone claim to be
+// someone's contact and receive their location. Contacts without an email are
+// logged for the operations team to call.
+func (h *Handlers) alertEmergencyContacts(userID, alertID int, body string) int {
+	contacts, err := h.emergencyContacts(userID)
+	if err != nil {
+		log.Printf("Failed to load emergency contacts for SOS %d: %v", alertID, err)
+		return 0
+	}
+
+	notified := 0
+	for _, contact := range contacts {
+		if contact.Email != nil {
+			err := h.Notifier.NotifyAddress(notify.ChannelEmail, *contact.Email, "sos", "Emergency alert", body)
+			if err == nil {
+				notified++
+				continue
+			}
+			log.Printf("SOS %d: failed to email emergency contact %d: %v", alertID, contact.ID, err)
+		}
+		log.Printf("SOS %d: emergency contact %d (%s) could not be reached; alert: %s", alertID, contact.ID, contact.Phone, body)
+	}
+	return notified
+}
+
+// sosAlertSorts are the sort keys GetSOSAlerts accepts. triage ranks open
+// alerts above resolved ones and orders each group by time, so -triage
+// lists open alerts first, newest first.
+var sosAlertSorts = listSorts{
+	"triage": {`CASE WHEN s.status = 'open' THEN '1' ELSE '0' END ||
+		to_char(COALESCE(s.created_at, 'epoch'), 'YYYY-MM-DD"T"HH24:MI:SS.US')`, "text"},
+	"created_at": {"COALESCE(s.created_at, 'epoch')", "timestamp"},
+}
+
+// GetSOSAlerts returns a page of SOS alerts, open ones first by default (admin only)
+func (h *Handlers) GetSOSAlerts(c *gin.Context) error {
+	p, err := parsePage(c, sosAlertSorts, "-triage", "s.id")
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT s.id, s.user_id, u.name, u.phone, s.ride_id, s.latitude, s.longitude, s.accuracy_m,
+		       s.message, s.contacts_notified, s.status, s.resolution_note, s.resolved_at, s.created_at` + p.sortColumn() + `
+		FROM sos_alerts s
+		JOIN users u ON s.user_id = u.id
+		WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+	if status := c.Query("status"); status != "" {
+		query += ` AND s.status = $1`
+		args = append(args, status)
+		argIndex++
+	}
+
+	after, afterArgs := p.where(argIndex)
+	rows, err := h.DB.Query(query+after+p.orderBy(), append(args, afterArgs...)...)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	alerts := []models.SOSAlert{}
+	for rows.Next() {
+		var a models.SOSAlert
+		var sortValue string
+		if err := rows.Scan(
+			&a.ID, &a.UserID, &a.UserName, &a.UserPhone, &a.RideID, &a.Latitude, &a.Longitude, &a.AccuracyM,
+			&a.Message, &a.ContactsNotified, &a.Status, &a.ResolutionNote, &a.ResolvedAt, &a.CreatedAt, &sortValue,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, a.ID) {
+			break
+		}
+		alerts = append(alerts, a)
+	}
+
+	c.JSON(http.StatusOK, sosAlertsPage{Alerts: alerts, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// ResolveSOSAlert closes an SOS alert with a note (admin only)
+func (h *Handlers) ResolveSOSAlert(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid alert ID")
+	}
+
+	adminID, _ := c.Get("user_id")
+
+	var req resolveSOSAlertRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "sos_alerts", "id = $1", id)
+
+	result, err := tx.Exec(
+		`UPDATE sos_alerts SET status = 'resolved', resolution_note = $1, resolved_by = $2,
+		                      resolved_at = CURRENT_TIMESTAMP
+		 WHERE id = $3 AND status = 'open'`,
+		req.Note, adminID, id,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to resolve alert", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Open alert not found")
+	}
+
+	err = audit(c, tx, "sos.resolve", "sos_alert", id, before, snapshot(tx, "sos_alerts", "id = $1", id))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to resolve alert", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert resolved"})
+	return nil
+}
@@ -2,42 +2,176 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+
 	"github.com/gin-gonic/gin"
 )
 
-// GetStats returns live statistics
-func (h *Handlers) GetStats(c *gin.Context) {
-	today := time.Now().Format("2006-01-02")
+// statsCacheTTL is how long home counters are served from memory
+const statsCacheTTL = 30 * time.Second
+
+type cachedStats struct {
+	expires time.Time
+	body    gin.H
+}
+
+// statsCache holds computed stats keyed by city_id ("" for all cities)
+var statsCache sync.Map
+
+// GetStats returns live statistics.
+// "Today" is evaluated in each city's local timezone; pass city_id to scope to one city.
+// Users count as online if seen within the configured online window.
+func (h *Handlers) GetStats(c *gin.Context) error {
+	cityID := c.Query("city_id")
+	if cityID != "" {
+		if _, err := strconv.Atoi(cityID); err != nil {
+			return apierr.BadRequest("Invalid city_id")
+		}
+	}
+
+	if v, ok := statsCache.Load(cityID); ok {
+		if cached := v.(cachedStats); time.Now().Before(cached.expires) {
+			c.JSON(http.StatusOK, cached.body)
+			return nil
+		}
+	}
+
+	body, err := h.computeStats(cityID)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	statsCache.Store(cityID, cachedStats{expires: time.Now().Add(statsCacheTTL), body: body})
+
+	c.JSON(http.StatusOK, body)
+	return nil
+}
+
+func (h *Handlers) computeStats(cityID string) (gin.H, error) {
+	cityFilter := ""
+	args := []interface{}{}
+	if cityID != "" {
+		cityFilter = ` AND ci.id = $1`
+		args = append(args, cityID)
+	}
+
+	// Presence queries take the online window as $1
+	window := h.Config.OnlineWindow.Seconds()
+	presenceFilter := ""
+	presenceArgs := []interface{}{window}
+	if cityID != "" {
+		presenceFilter = ` AND ci.id = $2`
+		presenceArgs = append(presenceArgs, cityID)
+	}
 
 	var ridesToday, ridesTakenToday, usersOnline int
 
 	// Count rides today
-	h.DB.QueryRow(
-		`SELECT COUNT(*) FROM rides WHERE ride_date = $1 AND status != 'cancelled'`,
-		today,
+	err := h.DB.QueryRow(
+		`SELECT COUNT(*) FROM rides r
+		 JOIN corridors co ON r.corridor_id = co.id
+		 JOIN cities ci ON co.city_id = ci.id
+		 WHERE r.ride_date = (CURRENT_TIMESTAMP AT TIME ZONE ci.timezone)::date
+		   AND r.status != 'cancelled'`+cityFilter,
+		args...,
 	).Scan(&ridesToday)
+	if err != nil {
+		return nil, err
+	}
 
 	// Count rides taken today (accepted requests)
-	h.DB.QueryRow(
-		`SELECT COUNT(*) FROM ride_requests 
-		 WHERE DATE(created_at) = $1 AND status = 'accepted'`,
-		today,
+	err = h.DB.QueryRow(
+		`SELECT COUNT(*) FROM ride_requests rr
+		 JOIN rides r ON rr.ride_id = r.id
+		 JOIN corridors co ON r.corridor_id = co.id
+		 JOIN cities ci ON co.city_id = ci.id
+		 WHERE (rr.created_at AT TIME ZONE current_setting('TimeZone') AT TIME ZONE ci.timezone)::date
+		       = (CURRENT_TIMESTAMP AT TIME ZONE ci.timezone)::date
+		   AND rr.status = 'accepted'`+cityFilter,
+		args...,
 	).Scan(&ridesTakenToday)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count users seen within the online window
+	if cityID == "" {
+		err = h.DB.QueryRow(
+			`SELECT COUNT(*) FROM users WHERE last_seen_at > CURRENT_TIMESTAMP - make_interval(secs => $1)`,
+			window,
+		).Scan(&usersOnline)
+	} else {
+		err = h.DB.QueryRow(
+			`SELECT COUNT(*) FROM users u
+			 JOIN cities ci ON LOWER(ci.name) = LOWER(u.city)
+			 WHERE u.last_seen_at > CURRENT_TIMESTAMP - make_interval(secs => $1)`+presenceFilter,
+			presenceArgs...,
+		).Scan(&usersOnline)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// Count users online (active in last 15 minutes)
-	// For now, we'll use a simple count of users who logged in today
-	h.DB.QueryRow(
-		`SELECT COUNT(DISTINCT user_id) FROM rides 
-		 WHERE DATE(created_at) = $1 OR DATE(updated_at) = $1`,
-		today,
-	).Scan(&usersOnline)
-
-	c.JSON(http.StatusOK, gin.H{
-		"rides_today":      ridesToday,
-		"rides_taken_today": ridesTakenToday,
-		"users_online":     usersOnline,
-	})
+	byCity, err := h.onlineCounts(
+		`SELECT ci.id, ci.name, NULL::int, COUNT(u.id)
+		 FROM cities ci
+		 LEFT JOIN users u ON LOWER(u.city) = LOWER(ci.name)
+		      AND u.last_seen_at > CURRENT_TIMESTAMP - make_interval(secs => $1)
+		 WHERE 1=1`+presenceFilter+`
+		 GROUP BY ci.id, ci.name
+		 ORDER BY ci.name`,
+		presenceArgs...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	byCorridor, err := h.onlineCounts(
+		`SELECT co.id, co.name, co.city_id, COUNT(u.id)
+		 FROM corridors co
+		 JOIN cities ci ON co.city_id = ci.id
+		 LEFT JOIN user_corridors uc ON uc.corridor_id = co.id
+		 LEFT JOIN users u ON u.id = uc.user_id
+		      AND u.last_seen_at > CURRENT_TIMESTAMP - make_interval(secs => $1)
+		 WHERE co.is_active = true`+presenceFilter+`
+		 GROUP BY co.id, co.name, co.city_id
+		 ORDER BY co.name`,
+		presenceArgs...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"rides_today":           ridesToday,
+		"rides_taken_today":     ridesTakenToday,
+		"users_online":          usersOnline,
+		"online_window_minutes": h.Config.OnlineWindow.Minutes(),
+		"online_by_city":        byCity,
+		"online_by_corridor":    byCorridor,
+	}, nil
 }
 
+// onlineCounts runs a breakdown query returning id, name, city_id and count
+func (h *Handlers) onlineCounts(query string, args ...interface{}) ([]models.OnlineCount, error) {
+	rows, err := h.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.OnlineCount{}
+	for rows.Next() {
+		var oc models.OnlineCount
+		if err := rows.Scan(&oc.ID, &oc.Name, &oc.CityID, &oc.UsersOnline); err != nil {
+			return nil, err
+		}
+		counts = append(counts, oc)
+	}
+	return counts, rows.Err()
+}
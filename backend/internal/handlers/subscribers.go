@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cpool.ai/backend/internal/events"
+	"cpool.ai/backend/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// creditsPerSeat is how many carbon credits each shared seat earns the rider and the driver
+const creditsPerSeat = 1
+
+// Business counters for /metrics. They count events as this replica
+// dispatches them, so sum them across replicas.
+var (
+	ridesCreated     = metrics.NewCounter("cpool_rides_created_total", "Rides offered by drivers")
+	requestsAccepted = metrics.NewCounter("cpool_ride_requests_accepted_total", "Ride requests accepted by drivers")
+	paymentsSettled  = metrics.NewCounter("cpool_payments_settled_total", "Payments the driver confirmed receiving")
+)
+
+// RegisterSubscribers hooks notifications, carbon credits, analytics,
+// metrics and partner webhooks up to domain events
+func (h *Handlers) RegisterSubscribers() {
+	h.Events.Subscribe("notify_ride_requests", h.notifyRideRequestEvent,
+		events.RequestCreated, events.RequestAccepted, events.RequestRejected)
+	h.Events.Subscribe("notify_ride_cancelled", h.notifyRideCancelledEvent, events.RideCancelled)
+	h.Events.Subscribe("notify_ride_reminders", h.notifyRideDepartingEvent, events.RideDeparting)
+	h.Events.Subscribe("notify_payments", h.notifyPaymentEvent, events.PaymentMarked)
+	h.Events.Subscribe("notify_messages", h.notifyMessageEvent, events.MessageSent)
+	h.Events.Subscribe("notify_city_launch", h.notifyCityLaunchedEvent, events.CityLaunched)
+	h.Events.Subscribe("carbon_credits", h.awardRideCredits, events.RideCompleted)
+	h.Events.Subscribe("ride_metrics", h.refreshRideEventMetrics, events.RideCompleted, events.RideCancelled)
+	h.Events.Subscribe("business_metrics", countEvent, events.RideCreated, events.RequestAccepted, events.PaymentMarked)
+	h.Events.Subscribe("webhooks", h.Webhooks.Enqueue, events.Types...)
+}
+
+// countEvent updates the business counters
+func countEvent(ctx context.Context, e events.Event) error {
+	switch e.Type {
+	case events.RideCreated:
+		ridesCreated.Inc()
+	case events.RequestAccepted:
+		requestsAccepted.Inc()
+	case events.PaymentMarked:
+		var p events.PaymentPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		if p.Status == "received" {
+			paymentsSettled.Inc()
+		}
+	}
+	return nil
+}
+
+// notifyRideRequestEvent tells the driver about a new request and the rider
+// about the driver's decision
+func (h *Handlers) notifyRideRequestEvent(ctx context.Context, e events.Event) error {
+	var p events.RequestPayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+
+	data, err := h.rideNotificationData(p.RideID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data["seats"] = p.Seats
+
+	switch e.Type {
+	case events.RequestCreated:
+		var riderName string
+		if err := h.DB.QueryRowContext(ctx, `SELECT name FROM users WHERE id = $1`, p.RiderID).Scan(&riderName); err != nil {
+			return err
+		}
+		data["rider"] = riderName
+		return h.notifyEvent(p.DriverID, "ride_request_created", data)
+	case events.RequestAccepted:
+		return h.notifyEvent(p.RiderID, "ride_request_accepted", data)
+	case events.RequestRejected:
+		kind := "ride_request_rejected"
+		if p.Reason == events.ReasonExpired {
+			kind = "ride_request_expired"
+		}
+		return h.notifyEvent(p.RiderID, kind, data)
+	}
+	return nil
+}
+
+// notifyRideCancelledEvent tells riders that the driver cancelled, or everyone
+// on the ride that an administrator did. Rides closed by the system had no
+// accepted riders to tell.
+func (h *Handlers) notifyRideCancelledEvent(ctx context.Context, e events.Event) error {
+	var p events.RidePayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+	if p.CancelledBy == "system" {
+		return nil
+	}
+
+	data, err := h.rideNotificationData(p.RideID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	recipients := p.RiderIDs
+	body := fmt.Sprintf("%s cancelled the ride on %s at %s.", data["driver"], data["date"], data["time"])
+	if p.CancelledBy == "admin" {
+		recipients = append([]int{p.DriverID}, recipients...)
+		body = fmt.Sprintf("Your ride on %s at %s was cancelled by an administrator: %s", data["date"], data["time"], p.Reason)
+	}
+
+	var errs []error
+	for _, userID := range recipients {
+		if err := h.notify(userID, "ride_cancelled", "Ride cancelled", body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notifyRideDepartingEvent reminds the driver and accepted riders that a
+// ride leaves soon
+func (h *Handlers) notifyRideDepartingEvent(ctx context.Context, e events.Event) error {
+	var p events.RidePayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+
+	data, err := h.rideNotificationData(p.RideID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, userID := range append([]int{p.DriverID}, p.RiderIDs...) {
+		if err := h.notifyEvent(userID, "ride_reminder", data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notifyPaymentEvent lets the other side of a payment know it was marked as sent or received
+func (h *Handlers) notifyPaymentEvent(ctx context.Context, e events.Event) error {
+	var p events.PaymentPayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+
+	actor := "An administrator"
+	if !p.Admin {
+		if err := h.DB.QueryRowContext(ctx, `SELECT name FROM users WHERE id = $1`, p.ActorID).Scan(&actor); err != nil {
+			return err
+		}
+	}
+
+	recipient := p.GiverID
+	if p.Status == "received" {
+		recipient = p.RiderID
+	}
+	return h.notifyPayment(p.RideID, p.RiderID, recipient, actor, p.Status)
+}
+
+// notifyMessageEvent tells the other participants of a ride about a chat message
+func (h *Handlers) notifyMessageEvent(ctx context.Context, e events.Event) error {
+	var p events.MessagePayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+
+	var message string
+	err := h.DB.QueryRowContext(ctx, `SELECT message FROM messages WHERE id = $1`, p.MessageID).Scan(&message)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.notifyNewMessage(p.RideID, p.SenderID, message)
+}
+
+// notifyCityLaunchedEvent tells a launched city's waitlist that it is open
+func (h *Handlers) notifyCityLaunchedEvent(ctx context.Context, e events.Event) error {
+	var p events.CityPayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, userID := range p.UserIDs {
+		if err := h.notifyEvent(userID, "city_launched", gin.H{"city": p.Name}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// awardRideCredits issues carbon credits for a completed ride: each accepted
+// rider earns credits for the seats they took and the driver for every seat
+// shared. Credits already issued for the ride are not issued again.
+func (h *Handlers) awardRideCredits(ctx context.Context, e events.Event) error {
+	var p events.RidePayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT user_id, SUM(seats_requested) FROM ride_requests
+		 WHERE ride_id = $1 AND status = 'accepted'
+		 GROUP BY user_id`,
+		p.RideID,
+	)
+	if err != nil {
+		return err
+	}
+	seats := map[int]int{}
+	total := 0
+	for rows.Next() {
+		var userID, n int
+		if err := rows.Scan(&userID, &n); err != nil {
+			rows.Close()
+			return err
+		}
+		seats[userID] = n
+		total += n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var awarded []events.CreditAward
+	award := func(userID, credits int, reason string) error {
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO carbon_credits (user_id, ride_id, credits, reason) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (ride_id, user_id) WHERE ride_id IS NOT NULL DO NOTHING`,
+			userID, p.RideID, credits, reason,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE users SET carbon_credits = COALESCE(carbon_credits, 0) + $1, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $2`,
+			credits, userID,
+		)
+		if err != nil {
+			return err
+		}
+		awarded = append(awarded, events.CreditAward{UserID: userID, Credits: credits, Reason: reason})
+		return nil
+	}
+
+	if err := award(p.DriverID, total*creditsPerSeat, "Shared a ride"); err != nil {
+		return err
+	}
+	for userID, n := range seats {
+		if err := award(userID, n*creditsPerSeat, "Took a shared ride"); err != nil {
+			return err
+		}
+	}
+
+	if len(awarded) > 0 {
+		err := events.Publish(tx, events.CreditsAwarded, events.CreditsPayload{RideID: p.RideID, Awards: awarded})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// refreshRideEventMetrics brings the analytics rollup up to date from the day
+// of a ride that was completed or cancelled
+func (h *Handlers) refreshRideEventMetrics(ctx context.Context, e events.Event) error {
+	var p events.RidePayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+
+	since, err := time.Parse("2006-01-02", p.RideDate)
+	if err != nil {
+		return err
+	}
+	return h.refreshRideMetrics(&since)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"cpool.ai/backend/internal/middleware"
+	"cpool.ai/backend/internal/ratelimit"
+)
+
+// Request limits for the routes most open to abuse. Login and registration
+// are limited per client IP. Messages and ride requests are limited per
+// user, and more loosely per IP since riders often share an office network.
+var (
+	loginLimits = middleware.Limits{
+		PerIP: ratelimit.Rate{Limit: 10, Period: time.Minute},
+	}
+	registerLimits = middleware.Limits{
+		PerIP: ratelimit.Rate{Limit: 5, Period: time.Hour},
+	}
+	messageLimits = middleware.Limits{
+		PerIP:   ratelimit.Rate{Limit: 120, Period: time.Minute},
+		PerUser: ratelimit.Rate{Limit: 30, Period: time.Minute},
+	}
+	rideRequestLimits = middleware.Limits{
+		PerIP:   ratelimit.Rate{Limit: 60, Period: time.Hour},
+		PerUser: ratelimit.Rate{Limit: 20, Period: time.Hour},
+	}
+)
+
+const (
+	// lockoutThreshold is how many failed logins in a row lock an account.
+	// Each further failure doubles the lock, from lockoutBase up to
+	// lockoutMax.
+	lockoutThreshold = 5
+	lockoutBase      = time.Minute
+	lockoutMax       = 15 * time.Minute
+	// loginFailureWindow is how long failures count towards a lockout
+	loginFailureWindow = 24 * time.Hour
+)
+
+// normalizeEmail is how logins look up accounts and count failures.
+// Addresses with no account are counted too, so lockouts don't reveal
+// which exist.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// lockoutDuration is how long an account stays locked after its nth
+// failure in a row
+func lockoutDuration(failures int) time.Duration {
+	extra := failures - lockoutThreshold
+	if extra < 0 {
+		return 0
+	}
+	if extra > 10 {
+		return lockoutMax
+	}
+	if d := lockoutBase << extra; d < lockoutMax {
+		return d
+	}
+	return lockoutMax
+}
+
+// accountLockedFor returns how much longer logins to email, normalized,
+// are refused
+func (h *Handlers) accountLockedFor(ctx context.Context, email string) (time.Duration, error) {
+	var seconds float64
+	err := h.DB.QueryRowContext(ctx,
+		`SELECT EXTRACT(EPOCH FROM locked_until - CURRENT_TIMESTAMP)::float8
+		 FROM login_failures WHERE email = $1 AND locked_until > CURRENT_TIMESTAMP`,
+		email,
+	).Scan(&seconds)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// recordLoginFailure counts a failed login to email, locking the account
+// once there have been enough in a row. It returns how long it is locked.
+func (h *Handlers) recordLoginFailure(ctx context.Context, email string) (time.Duration, error) {
+	var failures int
+	err := h.DB.QueryRowContext(ctx,
+		`INSERT INTO login_failures (email, failures) VALUES ($1, 1)
+		 ON CONFLICT (email) DO UPDATE SET
+		     failures = CASE
+		         WHEN login_failures.last_failed_at < CURRENT_TIMESTAMP - $2::int * INTERVAL '1 second' THEN 1
+		         ELSE login_failures.failures + 1
+		     END,
+		     last_failed_at = CURRENT_TIMESTAMP
+		 RETURNING failures`,
+		email, int(loginFailureWindow.Seconds()),
+	).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	lock := lockoutDuration(failures)
+	if lock == 0 {
+		return 0, nil
+	}
+	_, err = h.DB.ExecContext(ctx,
+		`UPDATE login_failures SET locked_until = CURRENT_TIMESTAMP + $2::int * INTERVAL '1 second' WHERE email = $1`,
+		email, int(lock.Seconds()),
+	)
+	return lock, err
+}
+
+// clearLoginFailures forgets failed logins to email after a successful one
+func (h *Handlers) clearLoginFailures(ctx context.Context, email string) error {
+	_, err := h.DB.ExecContext(ctx, `DELETE FROM login_failures WHERE email = $1`, email)
+	return err
+}
+
+// pruneRateLimits drops idle rate limit buckets and failed logins that no
+// longer count
+func (h *Handlers) pruneRateLimits(ctx context.Context) error {
+	if err := h.Limiter.Prune(ctx); err != nil {
+		return err
+	}
+	_, err := h.DB.ExecContext(ctx,
+		`DELETE FROM login_failures
+		 WHERE last_failed_at < CURRENT_TIMESTAMP - $1::int * INTERVAL '1 second'
+		   AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)`,
+		int(loginFailureWindow.Seconds()),
+	)
+	return err
+}
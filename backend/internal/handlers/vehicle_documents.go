@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDocumentSize is the largest accepted document upload
+const maxDocumentSize = 10 << 20
+
+// expiryReminderDays is how far ahead drivers are reminded about expiring documents
+const expiryReminderDays = 30
+
+// documentLabels are the required vehicle documents and their display names
+var documentLabels = map[string]string{
+	"rc":              "RC",
+	"insurance":       "insurance",
+	"driving_licence": "driving licence",
+}
+
+// documentExtensions maps accepted upload content types to file extensions
+var documentExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// GetVehicleDocuments returns uploaded documents for a vehicle (owner or admin)
+func (h *Handlers) GetVehicleDocuments(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid vehicle ID")
+	}
+
+	if ok, err := h.canAccessVehicle(c, id); err != nil {
+		return apierr.Internal("Database error", err)
+	} else if !ok {
+		return apierr.NotFound("Vehicle not found")
+	}
+
+	documents, err := h.vehicleDocuments(`vehicle_id = $1`, id)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	c.JSON(http.StatusOK, documents)
+	return nil
+}
+
+// UploadVehicleDocument uploads an RC, insurance or driving licence for review
+func (h *Handlers) UploadVehicleDocument(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid vehicle ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var exists bool
+	err = h.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM vehicles WHERE id = $1 AND user_id = $2 AND archived_at IS NULL)`,
+		id, userID,
+	).Scan(&exists)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	if !exists {
+		return apierr.NotFound("Vehicle not found")
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentSize+(1<<20))
+
+	docType := c.PostForm("doc_type")
+	label, ok := documentLabels[docType]
+	if !ok {
+		return apierr.BadRequest("doc_type must be one of rc, insurance, driving_licence")
+	}
+
+	var expiresOn *string
+	if v := c.PostForm("expires_on"); v != "" {
+		expiry, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return apierr.BadRequest("Invalid expires_on, expected YYYY-MM-DD")
+		}
+		if expiry.Before(time.Now()) {
+			return apierr.BadRequest("Document has already expired")
+		}
+		expiresOn = &v
+	} else if docType != "rc" {
+		return apierr.BadRequest("expires_on is required for " + label)
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		return apierr.BadRequest("File is required")
+	}
+	defer file.Close()
+
+	if header.Size > maxDocumentSize {
+		return apierr.New(http.StatusRequestEntityTooLarge, apierr.CodePayloadTooLarge, "File exceeds 10 MB limit")
+	}
+
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	contentType := http.DetectContentType(sniff[:n])
+	ext, ok := documentExtensions[contentType]
+	if !ok {
+		return apierr.BadRequest("Only PDF, JPEG and PNG files are accepted")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return apierr.Internal("Failed to read file", err)
+	}
+
+	key := fmt.Sprintf("vehicles/%d/%s-%d%s", id, docType, time.Now().UnixNano(), ext)
+	if err := h.Store.Put(key, file); err != nil {
+		return apierr.Internal("Failed to store file", err)
+	}
+
+	documentID, err := h.saveVehicleDocument(id, docType, key, header.Filename, contentType, header.Size, expiresOn)
+	if err != nil {
+		h.Store.Delete(key)
+		return apierr.Internal("Failed to save document", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": documentID, "message": "Document uploaded for review"})
+	return nil
+}
+
+// saveVehicleDocument records an upload, replacing earlier unreviewed copies of the same document
+func (h *Handlers) saveVehicleDocument(vehicleID int, docType, key, fileName, contentType string, size int64, expiresOn *string) (int, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE vehicle_documents SET status = 'superseded', updated_at = CURRENT_TIMESTAMP
+		 WHERE vehicle_id = $1 AND doc_type = $2 AND status IN ('pending', 'rejected')`,
+		vehicleID, docType,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var documentID int
+	err = tx.QueryRow(
+		`INSERT INTO vehicle_documents (vehicle_id, doc_type, storage_key, file_name, content_type, size_bytes, expires_on)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		vehicleID, docType, key, fileName, contentType, size, expiresOn,
+	).Scan(&documentID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE vehicles SET verification_status = 'pending', updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $1 AND verification_status IN ('unverified', 'rejected', 'expired')`,
+		vehicleID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return documentID, tx.Commit()
+}
+
+// DownloadVehicleDocument streams an uploaded document (owner or admin)
+func (h *Handlers) DownloadVehicleDocument(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid vehicle ID")
+	}
+
+	docID, err := strconv.Atoi(c.Param("docId"))
+	if err != nil {
+		return apierr.BadRequest("Invalid document ID")
+	}
+
+	if ok, err := h.canAccessVehicle(c, id); err != nil {
+		return apierr.Internal("Database error", err)
+	} else if !ok {
+		return apierr.NotFound("Vehicle not found")
+	}
+
+	var key, fileName, contentType string
+	var size int64
+	err = h.DB.QueryRow(
+		`SELECT storage_key, file_name, content_type, size_bytes
+		 FROM vehicle_documents WHERE id = $1 AND vehicle_id = $2`,
+		docID, id,
+	).Scan(&key, &fileName, &contentType, &size)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Document not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	reader, err := h.Store.Open(key)
+	if err != nil {
+		return apierr.NotFound("Document file not found")
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, size, contentType, reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("inline; filename=%q", fileName),
+	})
+	return nil
+}
+
+// GetVehicleReviewQueue returns vehicles with documents awaiting review (admin only)
+func (h *Handlers) GetVehicleReviewQueue(c *gin.Context) error {
+	rows, err := h.DB.Query(
+		`SELECT v.id, v.user_id, u.name, v.vehicle_type, v.make, v.model, v.color, v.vehicle_number,
+		       v.state_code, v.rto_code, v.total_seats, v.default_available_seats, v.verification_status,
+		       v.verification_note, v.verified_at, v.created_at, v.updated_at
+		 FROM vehicles v
+		 JOIN users u ON v.user_id = u.id
+		 WHERE v.archived_at IS NULL
+		   AND EXISTS(SELECT 1 FROM vehicle_documents d WHERE d.vehicle_id = v.id AND d.status = 'pending')
+		 ORDER BY v.updated_at`,
+	)
+
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	queue := []models.VehicleReview{}
+	for rows.Next() {
+		var review models.VehicleReview
+		vehicle := &review.Vehicle
+		if err := rows.Scan(
+			&vehicle.ID, &vehicle.UserID, &review.OwnerName, &vehicle.VehicleType, &vehicle.Make,
+			&vehicle.Model, &vehicle.Color, &vehicle.VehicleNumber, &vehicle.StateCode, &vehicle.RTOCode,
+			&vehicle.TotalSeats, &vehicle.DefaultAvailableSeats, &vehicle.VerificationStatus,
+			&vehicle.VerificationNote, &vehicle.VerifiedAt, &vehicle.CreatedAt, &vehicle.UpdatedAt,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		queue = append(queue, review)
+	}
+
+	for i := range queue {
+		documents, err := h.vehicleDocuments(`vehicle_id = $1 AND status != 'superseded'`, queue[i].Vehicle.ID)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		queue[i].Documents = documents
+	}
+
+	c.JSON(http.StatusOK, queue)
+	return nil
+}
+
+// ReviewVehicle approves or rejects a vehicle's pending documents (admin only)
+func (h *Handlers) ReviewVehicle(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid vehicle ID")
+	}
+
+	adminID, _ := c.Get("user_id")
+
+	var req reviewVehicleRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	if req.Status == "rejected" && req.Note == "" {
+		return apierr.BadRequest("A note is required when rejecting")
+	}
+
+	var note *string
+	if req.Note != "" {
+		note = &req.Note
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "vehicles", "id = $1", id)
+
+	var ownerID int
+	var vehicleNumber string
+	err = tx.QueryRow(
+		`SELECT user_id, vehicle_number FROM vehicles WHERE id = $1 FOR UPDATE`, id,
+	).Scan(&ownerID, &vehicleNumber)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Vehicle not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if req.Status == "approved" {
+		// Approved renewals replace the previously approved copy
+		_, err = tx.Exec(
+			`UPDATE vehicle_documents d SET status = 'superseded', updated_at = CURRENT_TIMESTAMP
+			 WHERE d.vehicle_id = $1 AND d.status = 'approved'
+			   AND EXISTS(SELECT 1 FROM vehicle_documents p
+			              WHERE p.vehicle_id = d.vehicle_id AND p.doc_type = d.doc_type AND p.status = 'pending')`,
+			id,
+		)
+		if err != nil {
+			return apierr.Internal("Failed to review vehicle", err)
+		}
+	}
+
+	result, err := tx.Exec(
+		`UPDATE vehicle_documents SET status = $1, review_note = $2, reviewed_by = $3,
+		        reviewed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE vehicle_id = $4 AND status = 'pending'`,
+		req.Status, note, adminID, id,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to review vehicle", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.BadRequest("No documents awaiting review")
+	}
+
+	if req.Status == "approved" {
+		var approvedTypes int
+		err = tx.QueryRow(
+			`SELECT COUNT(DISTINCT doc_type) FROM vehicle_documents
+			 WHERE vehicle_id = $1 AND status = 'approved'
+			   AND (expires_on IS NULL OR expires_on >= CURRENT_DATE)`,
+			id,
+		).Scan(&approvedTypes)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+
+		if approvedTypes < len(documentLabels) {
+			return apierr.BadRequest("Vehicle needs a valid RC, insurance and driving licence before it can be verified")
+		}
+
+		_, err = tx.Exec(
+			`UPDATE vehicles SET verification_status = 'verified', verification_note = $1,
+			        verified_at = CURRENT_TIMESTAMP, verified_by = $2, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $3`,
+			note, adminID, id,
+		)
+	} else {
+		// A rejected renewal leaves an already verified vehicle verified
+		_, err = tx.Exec(
+			`UPDATE vehicles SET verification_status = CASE WHEN verification_status = 'verified' THEN 'verified' ELSE 'rejected' END,
+			        verification_note = $1, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $2`,
+			note, id,
+		)
+	}
+	if err != nil {
+		return apierr.Internal("Failed to review vehicle", err)
+	}
+
+	if err := audit(c, tx, "vehicle.review", "vehicle", id, before, snapshot(tx, "vehicles", "id = $1", id)); err != nil {
+		return apierr.Internal("Failed to review vehicle", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apierr.Internal("Failed to review vehicle", err)
+	}
+
+	if req.Status == "approved" {
+		h.notify(ownerID, "vehicle_verified", "Vehicle verified",
+			"Your vehicle "+vehicleNumber+" has been verified. You can now offer rides with it.")
+	} else {
+		h.notify(ownerID, "vehicle_rejected", "Vehicle documents rejected",
+			"Documents for "+vehicleNumber+" were rejected: "+req.Note)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vehicle " + req.Status})
+	return nil
+}
+
+// processDocumentExpiry reminds drivers about documents expiring soon and
+// revokes verification for vehicles whose approved documents have expired.
+// Failed reminders stay unsent and are returned so the job is retried.
+func (h *Handlers) processDocumentExpiry(ctx context.Context) error {
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT d.id, v.user_id, v.vehicle_number, d.doc_type, to_char(d.expires_on, 'YYYY-MM-DD')
+		 FROM vehicle_documents d
+		 JOIN vehicles v ON d.vehicle_id = v.id
+		 WHERE d.status = 'approved' AND d.reminder_sent_at IS NULL AND v.archived_at IS NULL
+		   AND d.expires_on BETWEEN CURRENT_DATE AND CURRENT_DATE + $1::int`,
+		expiryReminderDays,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query expiring documents: %w", err)
+	}
+
+	type reminder struct {
+		documentID, userID            int
+		vehicleNumber, docType, until string
+	}
+	var reminders []reminder
+	for rows.Next() {
+		var r reminder
+		if err := rows.Scan(&r.documentID, &r.userID, &r.vehicleNumber, &r.docType, &r.until); err != nil {
+			rows.Close()
+			return err
+		}
+		reminders = append(reminders, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, r := range reminders {
+		label := documentLabels[r.docType]
+		// Leave the reminder unsent on failure so the next run retries it
+		if err := h.notify(r.userID, "document_expiring", "Upload a renewed "+label,
+			"The "+label+" for "+r.vehicleNumber+" expires on "+r.until+". Upload a renewed copy to keep offering rides."); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remind about document %d: %w", r.documentID, err))
+			continue
+		}
+		if _, err := h.DB.ExecContext(ctx,
+			`UPDATE vehicle_documents SET reminder_sent_at = CURRENT_TIMESTAMP WHERE id = $1`, r.documentID,
+		); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	expired, err := h.DB.QueryContext(ctx,
+		`UPDATE vehicles v SET verification_status = 'expired', updated_at = CURRENT_TIMESTAMP
+		 WHERE v.verification_status = 'verified' AND v.archived_at IS NULL
+		   AND EXISTS(SELECT 1 FROM vehicle_documents d
+		              WHERE d.vehicle_id = v.id AND d.status = 'approved' AND d.expires_on < CURRENT_DATE)
+		 RETURNING v.user_id, v.vehicle_number`,
+	)
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("failed to expire vehicle verifications: %w", err))...)
+	}
+
+	type expiry struct {
+		userID        int
+		vehicleNumber string
+	}
+	var expiries []expiry
+	for expired.Next() {
+		var e expiry
+		if err := expired.Scan(&e.userID, &e.vehicleNumber); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		expiries = append(expiries, e)
+	}
+	expired.Close()
+	if err := expired.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, e := range expiries {
+		if err := h.notify(e.userID, "vehicle_expired", "Vehicle verification expired",
+			"A document for "+e.vehicleNumber+" has expired. Upload a renewed copy to offer rides again."); err != nil {
+			errs = append(errs, fmt.Errorf("failed to notify about expired vehicle %s: %w", e.vehicleNumber, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// canAccessVehicle reports whether the current user owns the vehicle or is an admin
+func (h *Handlers) canAccessVehicle(c *gin.Context, vehicleID int) (bool, error) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("user_role")
+
+	var exists bool
+	err := h.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM vehicles WHERE id = $1 AND (user_id = $2 OR $3))`,
+		vehicleID, userID, role == "admin",
+	).Scan(&exists)
+	return exists, err
+}
+
+// vehicleDocuments lists documents matching a WHERE clause on vehicle_documents
+func (h *Handlers) vehicleDocuments(where string, args ...interface{}) ([]models.VehicleDocument, error) {
+	rows, err := h.DB.Query(
+		`SELECT id, vehicle_id, doc_type, file_name, content_type, size_bytes,
+		        to_char(expires_on, 'YYYY-MM-DD'), status, review_note, reviewed_at, created_at, updated_at
+		 FROM vehicle_documents WHERE `+where+`
+		 ORDER BY created_at DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := []models.VehicleDocument{}
+	for rows.Next() {
+		var doc models.VehicleDocument
+		if err := rows.Scan(
+			&doc.ID, &doc.VehicleID, &doc.DocType, &doc.FileName, &doc.ContentType, &doc.SizeBytes,
+			&doc.ExpiresOn, &doc.Status, &doc.ReviewNote, &doc.ReviewedAt, &doc.CreatedAt, &doc.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+	return documents, rows.Err()
+}
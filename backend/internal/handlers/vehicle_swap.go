@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cpool.ai/backend/internal/apierr"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// upcomingRide is a not-yet-departed ride with the seats already booked on it
+type upcomingRide struct {
+	id     int
+	booked int
+}
+
+// SwapVehicle moves upcoming rides from one of the driver's vehicles to another
+func (h *Handlers) SwapVehicle(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid vehicle ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var req swapVehicleRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	if req.VehicleID == id {
+		return apierr.BadRequest("Replacement vehicle must be different")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(
+		`SELECT true FROM vehicles WHERE id = $1 AND user_id = $2 FOR UPDATE`, id, userID,
+	).Scan(&exists)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Vehicle not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	var totalSeats int
+	var verificationStatus, description string
+	err = tx.QueryRow(
+		`SELECT total_seats, verification_status, make || ' ' || model || ' (' || vehicle_number || ')'
+		 FROM vehicles WHERE id = $1 AND user_id = $2 AND archived_at IS NULL FOR UPDATE`,
+		req.VehicleID, userID,
+	).Scan(&totalSeats, &verificationStatus, &description)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Replacement vehicle not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if verificationStatus != "verified" {
+		return apierr.New(http.StatusForbidden, apierr.CodeVehicleUnverified, "Replacement vehicle must be verified")
+	}
+
+	rides, err := upcomingVehicleRides(tx, id)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if len(req.RideIDs) > 0 {
+		byID := map[int]upcomingRide{}
+		for _, r := range rides {
+			byID[r.id] = r
+		}
+		rides = rides[:0]
+		for _, rideID := range req.RideIDs {
+			r, ok := byID[rideID]
+			if !ok {
+				return apierr.BadRequest(fmt.Sprintf("Ride %d is not an upcoming ride for this vehicle", rideID))
+			}
+			rides = append(rides, r)
+		}
+	}
+
+	if len(rides) == 0 {
+		return apierr.BadRequest("No upcoming rides to swap")
+	}
+
+	if booked := maxBookedSeats(rides); booked > totalSeats {
+		return apierr.Conflict(apierr.CodeSeatsBooked, "Replacement vehicle doesn't have enough seats for riders already booked").
+			With("booked_seats", booked)
+	}
+
+	if err := resizeRides(tx, rides, req.VehicleID, totalSeats); err != nil {
+		return apierr.Internal("Failed to swap vehicle", err)
+	}
+
+	ids := make([]int64, len(rides))
+	for i, r := range rides {
+		ids[i] = int64(r.id)
+	}
+
+	if err := audit(c, tx, "vehicle.swap", "vehicle", id, nil, auditData(gin.H{"to_vehicle_id": req.VehicleID, "ride_ids": ids})); err != nil {
+		return apierr.Internal("Failed to swap vehicle", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apierr.Internal("Failed to swap vehicle", err)
+	}
+
+	riders, err := h.DB.Query(
+		`SELECT DISTINCT user_id FROM ride_requests WHERE ride_id = ANY($1) AND status = 'accepted'`,
+		pq.Array(ids),
+	)
+	if err == nil {
+		defer riders.Close()
+		for riders.Next() {
+			var riderID int
+			if riders.Scan(&riderID) == nil {
+				h.notify(riderID, "ride_vehicle_changed", "Vehicle changed for your ride",
+					"Your driver will now be travelling in "+description+".")
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vehicle swapped", "rides_updated": len(rides)})
+	return nil
+}
+
+// upcomingVehicleRides locks and returns rides using a vehicle that have not yet
+// departed, measured in each ride's city local date
+func upcomingVehicleRides(tx *sql.Tx, vehicleID int) ([]upcomingRide, error) {
+	rows, err := tx.Query(
+		`SELECT r.id,
+		        COALESCE((SELECT SUM(rr.seats_requested) FROM ride_requests rr
+		                  WHERE rr.ride_id = r.id AND rr.status = 'accepted'), 0)
+		 FROM rides r
+		 JOIN corridors co ON r.corridor_id = co.id
+		 JOIN cities ci ON co.city_id = ci.id
+		 WHERE r.vehicle_id = $1
+		   AND r.status IN ('open', 'partially_filled', 'full')
+		   AND r.ride_date >= (CURRENT_TIMESTAMP AT TIME ZONE ci.timezone)::date
+		 FOR UPDATE OF r`,
+		vehicleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rides []upcomingRide
+	for rows.Next() {
+		var r upcomingRide
+		if err := rows.Scan(&r.id, &r.booked); err != nil {
+			return nil, err
+		}
+		rides = append(rides, r)
+	}
+	return rides, rows.Err()
+}
+
+// maxBookedSeats returns the most seats booked on any single ride
+func maxBookedSeats(rides []upcomingRide) int {
+	max := 0
+	for _, r := range rides {
+		if r.booked > max {
+			max = r.booked
+		}
+	}
+	return max
+}
+
+// resizeRides assigns rides to a vehicle with the given capacity, capping
+// available seats so booked riders keep their places
+func resizeRides(tx *sql.Tx, rides []upcomingRide, vehicleID, totalSeats int) error {
+	for _, r := range rides {
+		_, err := tx.Exec(
+			`UPDATE rides SET vehicle_id = $1, total_seats = $2::int,
+			        available_seats = LEAST(available_seats, $2::int - $3::int),
+			        status = CASE
+			            WHEN LEAST(available_seats, $2::int - $3::int) = 0 THEN 'full'
+			            WHEN LEAST(available_seats, $2::int - $3::int) < $2::int THEN 'partially_filled'
+			            ELSE 'open'
+			        END,
+			        updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $4`,
+			vehicleID, totalSeats, r.booked, r.id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
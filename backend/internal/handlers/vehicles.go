@@ -5,103 +5,125 @@ import (
 	"net/http"
 	"strconv"
 
+	"cpool.ai/backend/internal/apierr"
 	"cpool.ai/backend/internal/models"
+	"cpool.ai/backend/internal/regno"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
-// GetVehicles returns vehicles for current user
-func (h *Handlers) GetVehicles(c *gin.Context) {
+// maxSeatsByType caps passenger seats for each vehicle type
+var maxSeatsByType = map[string]int{
+	"car":  7,
+	"bike": 1,
+}
+
+// seatLimitError reports that a vehicle type can't take that many seats
+func seatLimitError(vehicleType string) *apierr.Error {
+	seats := "seats"
+	if maxSeatsByType[vehicleType] == 1 {
+		seats = "seat"
+	}
+	return apierr.BadRequest("A " + vehicleType + " can have at most " + strconv.Itoa(maxSeatsByType[vehicleType]) + " " + seats)
+}
+
+// GetVehicles returns vehicles for current user.
+// Archived vehicles are only included with include_archived=true.
+func (h *Handlers) GetVehicles(c *gin.Context) error {
 	userID, _ := c.Get("user_id")
 
-	rows, err := h.DB.Query(
-		`SELECT id, user_id, vehicle_type, make, model, color, vehicle_number, 
-		       total_seats, default_available_seats, created_at, updated_at
-		 FROM vehicles WHERE user_id = $1 ORDER BY created_at DESC`,
-		userID,
-	)
+	query := `SELECT id, user_id, vehicle_type, make, model, color, vehicle_number, state_code, rto_code,
+		       total_seats, default_available_seats, verification_status,
+		       verification_note, verified_at, archived_at, created_at, updated_at
+		 FROM vehicles WHERE user_id = $1`
+	if c.Query("include_archived") != "true" {
+		query += ` AND archived_at IS NULL`
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := h.DB.Query(query, userID)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 	defer rows.Close()
 
-	var vehicles []models.Vehicle
+	vehicles := []models.Vehicle{}
 	for rows.Next() {
 		var vehicle models.Vehicle
 		if err := rows.Scan(
 			&vehicle.ID, &vehicle.UserID, &vehicle.VehicleType, &vehicle.Make,
-			&vehicle.Model, &vehicle.Color, &vehicle.VehicleNumber,
-			&vehicle.TotalSeats, &vehicle.DefaultAvailableSeats,
+			&vehicle.Model, &vehicle.Color, &vehicle.VehicleNumber, &vehicle.StateCode, &vehicle.RTOCode,
+			&vehicle.TotalSeats, &vehicle.DefaultAvailableSeats, &vehicle.VerificationStatus,
+			&vehicle.VerificationNote, &vehicle.VerifiedAt, &vehicle.ArchivedAt,
 			&vehicle.CreatedAt, &vehicle.UpdatedAt,
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
+			return apierr.Internal("Database error", err)
 		}
 		vehicles = append(vehicles, vehicle)
 	}
 
 	c.JSON(http.StatusOK, vehicles)
+	return nil
 }
 
 // GetVehicle returns a single vehicle
-func (h *Handlers) GetVehicle(c *gin.Context) {
+func (h *Handlers) GetVehicle(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
-		return
+		return apierr.BadRequest("Invalid vehicle ID")
 	}
 
 	userID, _ := c.Get("user_id")
 
 	var vehicle models.Vehicle
 	err = h.DB.QueryRow(
-		`SELECT id, user_id, vehicle_type, make, model, color, vehicle_number, 
-		       total_seats, default_available_seats, created_at, updated_at
+		`SELECT id, user_id, vehicle_type, make, model, color, vehicle_number, state_code, rto_code,
+		       total_seats, default_available_seats, verification_status,
+		       verification_note, verified_at, archived_at, created_at, updated_at
 		 FROM vehicles WHERE id = $1 AND user_id = $2`,
 		id, userID,
 	).Scan(
 		&vehicle.ID, &vehicle.UserID, &vehicle.VehicleType, &vehicle.Make,
-		&vehicle.Model, &vehicle.Color, &vehicle.VehicleNumber,
-		&vehicle.TotalSeats, &vehicle.DefaultAvailableSeats,
+		&vehicle.Model, &vehicle.Color, &vehicle.VehicleNumber, &vehicle.StateCode, &vehicle.RTOCode,
+		&vehicle.TotalSeats, &vehicle.DefaultAvailableSeats, &vehicle.VerificationStatus,
+		&vehicle.VerificationNote, &vehicle.VerifiedAt, &vehicle.ArchivedAt,
 		&vehicle.CreatedAt, &vehicle.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
-		return
+		return apierr.NotFound("Vehicle not found")
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return apierr.Internal("Database error", err)
 	}
 
 	c.JSON(http.StatusOK, vehicle)
+	return nil
 }
 
 // CreateVehicle creates a new vehicle
-func (h *Handlers) CreateVehicle(c *gin.Context) {
+func (h *Handlers) CreateVehicle(c *gin.Context) error {
 	userID, _ := c.Get("user_id")
 
-	var req struct {
-		VehicleType           string `json:"vehicle_type" binding:"required,oneof=car bike"`
-		Make                  string `json:"make" binding:"required"`
-		Model                 string `json:"model" binding:"required"`
-		Color                 string `json:"color"`
-		VehicleNumber         string `json:"vehicle_number" binding:"required"`
-		TotalSeats            int    `json:"total_seats" binding:"required,min=1"`
-		DefaultAvailableSeats int    `json:"default_available_seats" binding:"required,min=1"`
-	}
+	var req createVehicleRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
 	}
 
 	if req.DefaultAvailableSeats > req.TotalSeats {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Available seats cannot exceed total seats"})
-		return
+		return apierr.BadRequest("Available seats cannot exceed total seats")
+	}
+
+	if req.TotalSeats > maxSeatsByType[req.VehicleType] {
+		return seatLimitError(req.VehicleType)
+	}
+
+	plate, err := regno.Parse(req.VehicleNumber)
+	if err != nil {
+		return apierr.BadRequest("Invalid vehicle number: " + err.Error())
 	}
 
 	var color *string
@@ -109,50 +131,101 @@ func (h *Handlers) CreateVehicle(c *gin.Context) {
 		color = &req.Color
 	}
 
+	var stateCode, rtoCode *string
+	if !plate.BHSeries {
+		stateCode, rtoCode = &plate.StateCode, &plate.RTOCode
+	}
+
 	var vehicleID int
-	err := h.DB.QueryRow(
+	err = h.DB.QueryRow(
 		`INSERT INTO vehicles (user_id, vehicle_type, make, model, color, vehicle_number, 
-		                       total_seats, default_available_seats)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		                       state_code, rto_code, total_seats, default_available_seats)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
 		userID, req.VehicleType, req.Make, req.Model, color,
-		req.VehicleNumber, req.TotalSeats, req.DefaultAvailableSeats,
+		plate.Number, stateCode, rtoCode, req.TotalSeats, req.DefaultAvailableSeats,
 	).Scan(&vehicleID)
 
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return apierr.Conflict(apierr.CodeVehicleNumberTaken, "Vehicle number already exists")
+	}
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Vehicle number already exists"})
-		return
+		return apierr.Internal("Failed to create vehicle", err)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": vehicleID, "message": "Vehicle created"})
+	c.JSON(http.StatusCreated, gin.H{"id": vehicleID, "vehicle_number": plate.Number, "message": "Vehicle created"})
+	return nil
 }
 
 // UpdateVehicle updates a vehicle
-func (h *Handlers) UpdateVehicle(c *gin.Context) {
+func (h *Handlers) UpdateVehicle(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
-		return
+		return apierr.BadRequest("Invalid vehicle ID")
 	}
 
 	userID, _ := c.Get("user_id")
 
-	var req struct {
-		Make                  *string `json:"make"`
-		Model                 *string `json:"model"`
-		Color                 *string `json:"color"`
-		TotalSeats            *int    `json:"total_seats"`
-		DefaultAvailableSeats *int    `json:"default_available_seats"`
-	}
+	var req updateVehicleRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return apierr.Validation(err)
+	}
+
+	// Load current seating so partial updates are validated against the stored values
+	var vehicleType string
+	var totalSeats, defaultSeats int
+	err = h.DB.QueryRow(
+		`SELECT vehicle_type, total_seats, default_available_seats FROM vehicles
+		 WHERE id = $1 AND user_id = $2 AND archived_at IS NULL`,
+		id, userID,
+	).Scan(&vehicleType, &totalSeats, &defaultSeats)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Vehicle not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if req.TotalSeats != nil {
+		totalSeats = *req.TotalSeats
+	}
+	if req.DefaultAvailableSeats != nil {
+		defaultSeats = *req.DefaultAvailableSeats
+	}
+	if totalSeats < 1 || defaultSeats < 1 {
+		return apierr.BadRequest("Seats must be at least 1")
+	}
+	if defaultSeats > totalSeats {
+		return apierr.BadRequest("Available seats cannot exceed total seats")
+	}
+	if totalSeats > maxSeatsByType[vehicleType] {
+		return seatLimitError(vehicleType)
 	}
 
 	updates := []string{}
 	args := []interface{}{}
 	argIndex := 1
 
+	if req.VehicleNumber != nil {
+		plate, err := regno.Parse(*req.VehicleNumber)
+		if err != nil {
+			return apierr.BadRequest("Invalid vehicle number: " + err.Error())
+		}
+		var stateCode, rtoCode *string
+		if !plate.BHSeries {
+			stateCode, rtoCode = &plate.StateCode, &plate.RTOCode
+		}
+		// A new registration number invalidates the existing verification
+		updates = append(updates,
+			"verification_status = CASE WHEN vehicle_number = $"+strconv.Itoa(argIndex)+" THEN verification_status ELSE 'unverified' END",
+			"vehicle_number = $"+strconv.Itoa(argIndex),
+			"state_code = $"+strconv.Itoa(argIndex+1),
+			"rto_code = $"+strconv.Itoa(argIndex+2),
+		)
+		args = append(args, plate.Number, stateCode, rtoCode)
+		argIndex += 3
+	}
 	if req.Make != nil {
 		updates = append(updates, "make = $"+strconv.Itoa(argIndex))
 		args = append(args, *req.Make)
@@ -180,8 +253,7 @@ func (h *Handlers) UpdateVehicle(c *gin.Context) {
 	}
 
 	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
-		return
+		return apierr.BadRequest("No fields to update")
 	}
 
 	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
@@ -193,31 +265,107 @@ func (h *Handlers) UpdateVehicle(c *gin.Context) {
 	}
 	query += ` WHERE id = $` + strconv.Itoa(argIndex) + ` AND user_id = $` + strconv.Itoa(argIndex+1)
 
-	_, err = h.DB.Exec(query, args...)
+	tx, err := h.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vehicle"})
-		return
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := snapshot(tx, "vehicles", "id = $1", id)
+
+	// Capacity can't drop below seats already booked on upcoming rides
+	var upcoming []upcomingRide
+	if req.TotalSeats != nil {
+		upcoming, err = upcomingVehicleRides(tx, id)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if booked := maxBookedSeats(upcoming); totalSeats < booked {
+			return apierr.Conflict(apierr.CodeSeatsBooked, "Total seats cannot be less than seats already booked on upcoming rides").
+				With("booked_seats", booked)
+		}
+	}
+
+	_, err = tx.Exec(query, args...)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return apierr.Conflict(apierr.CodeVehicleNumberTaken, "Vehicle number already exists")
+	}
+	if err != nil {
+		return apierr.Internal("Failed to update vehicle", err)
+	}
+
+	if err := resizeRides(tx, upcoming, id, totalSeats); err != nil {
+		return apierr.Internal("Failed to update upcoming rides", err)
+	}
+
+	if err := audit(c, tx, "vehicle.update", "vehicle", id, before, snapshot(tx, "vehicles", "id = $1", id)); err != nil {
+		return apierr.Internal("Failed to update vehicle", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apierr.Internal("Failed to update vehicle", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Vehicle updated"})
+	return nil
 }
 
-// DeleteVehicle deletes a vehicle
-func (h *Handlers) DeleteVehicle(c *gin.Context) {
+// DeleteVehicle archives a vehicle that has no upcoming rides
+func (h *Handlers) DeleteVehicle(c *gin.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
-		return
+		return apierr.BadRequest("Invalid vehicle ID")
 	}
 
 	userID, _ := c.Get("user_id")
 
-	_, err = h.DB.Exec(`DELETE FROM vehicles WHERE id = $1 AND user_id = $2`, id, userID)
+	tx, err := h.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete vehicle"})
-		return
+		return apierr.Internal("Database error", err)
 	}
+	defer tx.Rollback()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Vehicle deleted"})
-}
+	before := snapshot(tx, "vehicles", "id = $1", id)
+
+	var exists bool
+	err = tx.QueryRow(
+		`SELECT true FROM vehicles WHERE id = $1 AND user_id = $2 AND archived_at IS NULL FOR UPDATE`,
+		id, userID,
+	).Scan(&exists)
 
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Vehicle not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	rides, err := upcomingVehicleRides(tx, id)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if len(rides) > 0 {
+		return apierr.Conflict(apierr.CodeVehicleInUse, "Vehicle is assigned to upcoming rides. Swap the vehicle or cancel those rides first").
+			With("upcoming_rides", len(rides))
+	}
+
+	_, err = tx.Exec(
+		`UPDATE vehicles SET archived_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to delete vehicle", err)
+	}
+
+	if err := audit(c, tx, "vehicle.archive", "vehicle", id, before, snapshot(tx, "vehicles", "id = $1", id)); err != nil {
+		return apierr.Internal("Failed to delete vehicle", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return apierr.Internal("Failed to delete vehicle", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vehicle archived"})
+	return nil
+}
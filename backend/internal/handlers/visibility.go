@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"database/sql"
+)
+
+// Ride visibility options
+const (
+	visibilityEveryone  = "everyone"
+	visibilityWomenOnly = "women_only"
+)
+
+// visibleRideClause restricts women-only rides to their driver, admins and
+// viewers who have declared themselves female, with the viewer bound to placeholder
+func visibleRideClause(placeholder string) string {
+	return ` AND (r.visibility = 'everyone' OR r.user_id = ` + placeholder + `
+	          OR EXISTS(SELECT 1 FROM users v WHERE v.id = ` + placeholder + `
+	                    AND (v.gender = 'female' OR v.role = 'admin')))`
+}
+
+// rideVisible reports whether a ride exists and viewerID may see it. Routes
+// under a ride check it so a women-only ride's requests and messages are
+// hidden the same way the ride is.
+func (h *Handlers) rideVisible(rideID int, viewerID interface{}) (bool, error) {
+	var visible bool
+	err := h.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM rides r WHERE r.id = $1`+visibleRideClause("$2")+`)`,
+		rideID, viewerID,
+	).Scan(&visible)
+	return visible, err
+}
+
+// isFemale reports whether a user has declared themselves female
+func (h *Handlers) isFemale(userID interface{}) (bool, error) {
+	var female bool
+	err := h.DB.QueryRow(
+		`SELECT COALESCE(gender = 'female', false) FROM users WHERE id = $1`,
+		userID,
+	).Scan(&female)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return female, err
+}
+
+// womenOnlyDenial explains why a driver can't offer a women-only ride on a
+// corridor, or returns an empty string when they can
+func (h *Handlers) womenOnlyDenial(corridorID int, driverID interface{}) (string, error) {
+	var allowed bool
+	err := h.DB.QueryRow(`SELECT women_only_allowed FROM corridors WHERE id = $1`, corridorID).Scan(&allowed)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "Women-only rides are not enabled on this corridor", nil
+	}
+
+	female, err := h.isFemale(driverID)
+	if err != nil {
+		return "", err
+	}
+	if !female {
+		return "Only drivers who have declared their gender as female can offer women-only rides", nil
+	}
+	return "", nil
+}
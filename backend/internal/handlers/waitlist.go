@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/events"
+	"cpool.ai/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMinSignups is the demand needed before a route is suggested as a corridor
+const defaultMinSignups = 5
+
+// JoinWaitlist registers interest in a locked city
+func (h *Handlers) JoinWaitlist(c *gin.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid city ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	var req joinWaitlistRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	commuteStart, ok := parseCommuteTime(req.CommuteStart)
+	if !ok {
+		return apierr.BadRequest("Invalid commute_start, expected HH:MM")
+	}
+	commuteReturn, ok := parseCommuteTime(req.CommuteReturn)
+	if !ok {
+		return apierr.BadRequest("Invalid commute_return, expected HH:MM")
+	}
+
+	var status string
+	err = h.DB.QueryRow(`SELECT status FROM cities WHERE id = $1`, cityID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("City not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	if status != "locked" {
+		return apierr.BadRequest("City is already active")
+	}
+
+	var entryID int
+	err = h.DB.QueryRow(
+		`INSERT INTO city_waitlist (city_id, user_id, preferred_from, preferred_to, commute_start, commute_return)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (city_id, user_id) DO UPDATE SET
+		     preferred_from = EXCLUDED.preferred_from,
+		     preferred_to = EXCLUDED.preferred_to,
+		     commute_start = EXCLUDED.commute_start,
+		     commute_return = EXCLUDED.commute_return,
+		     updated_at = CURRENT_TIMESTAMP
+		 RETURNING id`,
+		cityID, userID, strings.TrimSpace(req.PreferredFrom), strings.TrimSpace(req.PreferredTo),
+		commuteStart, commuteReturn,
+	).Scan(&entryID)
+
+	if err != nil {
+		return apierr.Internal("Failed to join waitlist", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": entryID, "message": "Added to waitlist"})
+	return nil
+}
+
+// LeaveWaitlist removes the current user from a city's waitlist
+func (h *Handlers) LeaveWaitlist(c *gin.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid city ID")
+	}
+
+	userID, _ := c.Get("user_id")
+
+	_, err = h.DB.Exec(`DELETE FROM city_waitlist WHERE city_id = $1 AND user_id = $2`, cityID, userID)
+	if err != nil {
+		return apierr.Internal("Failed to leave waitlist", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from waitlist"})
+	return nil
+}
+
+// waitlistSorts are the sort keys GetWaitlist accepts
+var waitlistSorts = listSorts{
+	"created_at": {"COALESCE(w.created_at, 'epoch')", "timestamp"},
+}
+
+// GetWaitlist returns a page of a city's waitlist signups, earliest first by
+// default (admin only)
+func (h *Handlers) GetWaitlist(c *gin.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid city ID")
+	}
+
+	p, err := parsePage(c, waitlistSorts, "created_at", "w.id")
+	if err != nil {
+		return err
+	}
+
+	after, afterArgs := p.where(2)
+	rows, err := h.DB.Query(
+		`SELECT w.id, w.city_id, w.user_id, u.name, u.email, w.preferred_from, w.preferred_to,
+		       to_char(w.commute_start, 'HH24:MI'), to_char(w.commute_return, 'HH24:MI'),
+		       w.notified_at, w.created_at, w.updated_at`+p.sortColumn()+`
+		 FROM city_waitlist w
+		 JOIN users u ON w.user_id = u.id
+		 WHERE w.city_id = $1`+after+p.orderBy(),
+		append([]interface{}{cityID}, afterArgs...)...,
+	)
+
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	entries := []models.WaitlistEntry{}
+	for rows.Next() {
+		var entry models.WaitlistEntry
+		var sortValue string
+		if err := rows.Scan(
+			&entry.ID, &entry.CityID, &entry.UserID, &entry.UserName, &entry.UserEmail,
+			&entry.PreferredFrom, &entry.PreferredTo, &entry.CommuteStart, &entry.CommuteReturn,
+			&entry.NotifiedAt, &entry.CreatedAt, &entry.UpdatedAt, &sortValue,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, entry.ID) {
+			break
+		}
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, waitlistPage{Entries: entries, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// GetCityDemand aggregates waitlist signups into suggested corridors (admin only)
+func (h *Handlers) GetCityDemand(c *gin.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid city ID")
+	}
+
+	minSignups := defaultMinSignups
+	if v := c.Query("min_signups"); v != "" {
+		minSignups, err = strconv.Atoi(v)
+		if err != nil || minSignups < 1 {
+			return apierr.BadRequest("Invalid min_signups")
+		}
+	}
+
+	report := models.DemandReport{CityID: cityID, MinSignups: minSignups, Routes: []models.DemandRoute{}}
+	err = h.DB.QueryRow(
+		`SELECT ci.name, ci.status, (SELECT COUNT(*) FROM city_waitlist WHERE city_id = ci.id)
+		 FROM cities ci WHERE ci.id = $1`,
+		cityID,
+	).Scan(&report.CityName, &report.Status, &report.TotalSignups)
+
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("City not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+
+	// Group signups by normalised from/to areas and find the most common commute hours
+	rows, err := h.DB.Query(
+		`WITH demand AS (
+			SELECT LOWER(TRIM(preferred_from)) AS from_key, LOWER(TRIM(preferred_to)) AS to_key,
+			       MIN(TRIM(preferred_from)) AS location_from, MIN(TRIM(preferred_to)) AS location_to,
+			       COUNT(*) AS signups,
+			       (MODE() WITHIN GROUP (ORDER BY EXTRACT(HOUR FROM commute_start)))::int AS peak_departure_hour,
+			       (MODE() WITHIN GROUP (ORDER BY EXTRACT(HOUR FROM commute_return)))::int AS peak_return_hour
+			FROM city_waitlist
+			WHERE city_id = $1
+			GROUP BY 1, 2
+		)
+		SELECT d.location_from, d.location_to, d.signups, d.peak_departure_hour, d.peak_return_hour,
+		       EXISTS(
+		           SELECT 1 FROM corridors c
+		           WHERE c.city_id = $1 AND LOWER(c.location_from) = d.from_key AND LOWER(c.location_to) = d.to_key
+		       )
+		FROM demand d
+		ORDER BY d.signups DESC, d.location_from, d.location_to`,
+		cityID,
+	)
+
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var route models.DemandRoute
+		if err := rows.Scan(
+			&route.LocationFrom, &route.LocationTo, &route.Signups,
+			&route.PeakDepartureHour, &route.PeakReturnHour, &route.HasCorridor,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !route.HasCorridor && route.Signups >= minSignups {
+			route.Suggested = true
+			route.SuggestedName = route.LocationFrom + " → " + route.LocationTo
+		}
+		report.Routes = append(report.Routes, route)
+	}
+
+	c.JSON(http.StatusOK, report)
+	return nil
+}
+
+// launchCity activates a city and publishes CityLaunched for everyone on its
+// waitlist not yet told, who are notified from the event. It returns the
+// number of users to be notified. The caller commits tx.
+func launchCity(tx *sql.Tx, cityID int) (int, error) {
+	var cityName string
+	err := tx.QueryRow(
+		`UPDATE cities SET status = 'active', launch_at = NULL, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $1 RETURNING name`,
+		cityID,
+	).Scan(&cityName)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(
+		`UPDATE city_waitlist SET notified_at = CURRENT_TIMESTAMP
+		 WHERE city_id = $1 AND notified_at IS NULL
+		 RETURNING user_id`,
+		cityID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	err = events.Publish(tx, events.CityLaunched, events.CityPayload{CityID: cityID, Name: cityName, UserIDs: userIDs})
+	if err != nil {
+		return 0, err
+	}
+	return len(userIDs), nil
+}
+
+// launchDueCities launches every locked city whose launch_at is in the past.
+// A city that fails doesn't hold up the others; the errors are returned
+// together so the job is retried.
+func (h *Handlers) launchDueCities(ctx context.Context) error {
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT id FROM cities WHERE status = 'locked' AND launch_at <= CURRENT_TIMESTAMP`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query scheduled city launches: %w", err)
+	}
+
+	var cityIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		cityIDs = append(cityIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, id := range cityIDs {
+		if err := h.launchDueCity(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("failed to launch city %d: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// launchDueCity launches one scheduled city in its own transaction
+func (h *Handlers) launchDueCity(ctx context.Context, cityID int) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	notified, err := launchCity(tx, cityID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("City %d launched, notifying %d waitlisted users", cityID, notified)
+	return nil
+}
+
+// parseCommuteTime validates an optional HH:MM time
+func parseCommuteTime(value string) (*string, bool) {
+	if value == "" {
+		return nil, true
+	}
+	if _, err := time.Parse("15:04", value); err != nil {
+		return nil, false
+	}
+	return &value, true
+}
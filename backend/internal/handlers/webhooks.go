@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/events"
+	"cpool.ai/backend/internal/models"
+	"cpool.ai/backend/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const webhookEndpointColumns = `id, url, description, event_types, is_active, consecutive_failures,
+	last_success_at, last_failure_at, disabled_at, disabled_reason, created_by, created_at, updated_at`
+
+func scanWebhookEndpoint(scan func(...interface{}) error) (models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	err := scan(
+		&e.ID, &e.URL, &e.Description, pq.Array(&e.EventTypes), &e.IsActive, &e.ConsecutiveFailures,
+		&e.LastSuccessAt, &e.LastFailureAt, &e.DisabledAt, &e.DisabledReason, &e.CreatedBy, &e.CreatedAt, &e.UpdatedAt,
+	)
+	return e, err
+}
+
+// webhookSnapshot captures an endpoint for the audit log without its secret
+func webhookSnapshot(tx *sql.Tx, id int) json.RawMessage {
+	return snapshot(tx, `(SELECT `+webhookEndpointColumns+` FROM webhook_endpoints)`, "id = $1", id)
+}
+
+// validateWebhook checks an endpoint URL and its event types, returning a
+// message for the client when they are unusable
+func validateWebhook(rawURL string, eventTypes []string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "URL must be an absolute http or https URL"
+	}
+
+	known := map[string]bool{"*": true}
+	for _, t := range events.Types {
+		known[t] = true
+	}
+	for _, t := range eventTypes {
+		if !known[t] {
+			return "Unknown event type: " + t
+		}
+	}
+	return ""
+}
+
+// GetWebhookEventTypes lists the event types endpoints can subscribe to (admin only)
+func (h *Handlers) GetWebhookEventTypes(c *gin.Context) error {
+	c.JSON(http.StatusOK, gin.H{"event_types": events.Types})
+	return nil
+}
+
+// GetWebhooks lists webhook endpoints (admin only)
+func (h *Handlers) GetWebhooks(c *gin.Context) error {
+	rows, err := h.DB.Query(`SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints ORDER BY id`)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	endpoints := []models.WebhookEndpoint{}
+	for rows.Next() {
+		e, err := scanWebhookEndpoint(rows.Scan)
+		if err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	c.JSON(http.StatusOK, endpoints)
+	return nil
+}
+
+// CreateWebhook registers an endpoint and returns its signing secret, which
+// is not shown again (admin only). Event type "*" subscribes to everything.
+func (h *Handlers) CreateWebhook(c *gin.Context) error {
+	adminID, _ := c.Get("user_id")
+
+	var req createWebhookRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	if msg := validateWebhook(req.URL, req.EventTypes); msg != "" {
+		return apierr.BadRequest(msg)
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		return apierr.Internal("Failed to generate secret", err)
+	}
+
+	var description *string
+	if req.Description != "" {
+		description = &req.Description
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	endpoint, err := scanWebhookEndpoint(tx.QueryRow(
+		`INSERT INTO webhook_endpoints (url, description, event_types, secret, created_by)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+webhookEndpointColumns,
+		req.URL, description, pq.Array(req.EventTypes), secret, adminID,
+	).Scan)
+	if err == nil {
+		err = audit(c, tx, "webhook.create", "webhook", endpoint.ID, nil, webhookSnapshot(tx, endpoint.ID))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to create webhook", err)
+	}
+
+	endpoint.Secret = secret
+	c.JSON(http.StatusCreated, endpoint)
+	return nil
+}
+
+// UpdateWebhook changes an endpoint's URL, description, event types or
+// active state. Re-enabling an endpoint clears its failure count (admin only).
+func (h *Handlers) UpdateWebhook(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid webhook ID")
+	}
+
+	var req updateWebhookRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return apierr.Validation(err)
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := webhookSnapshot(tx, id)
+	if before == nil {
+		return apierr.NotFound("Webhook not found")
+	}
+
+	updates := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.URL != nil || req.EventTypes != nil {
+		var current models.WebhookEndpoint
+		json.Unmarshal(before, &current)
+		rawURL, eventTypes := current.URL, current.EventTypes
+		if req.URL != nil {
+			rawURL = strings.TrimSpace(*req.URL)
+		}
+		if req.EventTypes != nil {
+			if len(req.EventTypes) == 0 {
+				return apierr.BadRequest("At least one event type is required")
+			}
+			eventTypes = req.EventTypes
+		}
+		if msg := validateWebhook(rawURL, eventTypes); msg != "" {
+			return apierr.BadRequest(msg)
+		}
+		if req.URL != nil {
+			updates = append(updates, "url = $"+strconv.Itoa(argIndex))
+			args = append(args, rawURL)
+			argIndex++
+		}
+		if req.EventTypes != nil {
+			updates = append(updates, "event_types = $"+strconv.Itoa(argIndex))
+			args = append(args, pq.Array(eventTypes))
+			argIndex++
+		}
+	}
+	if req.Description != nil {
+		var description *string
+		if *req.Description != "" {
+			description = req.Description
+		}
+		updates = append(updates, "description = $"+strconv.Itoa(argIndex))
+		args = append(args, description)
+		argIndex++
+	}
+	if req.IsActive != nil {
+		updates = append(updates, "is_active = $"+strconv.Itoa(argIndex))
+		args = append(args, *req.IsActive)
+		argIndex++
+		if *req.IsActive {
+			updates = append(updates, "consecutive_failures = 0", "disabled_at = NULL", "disabled_reason = NULL")
+		} else {
+			updates = append(updates, "disabled_at = CURRENT_TIMESTAMP", "disabled_reason = 'Disabled by an administrator'")
+		}
+	}
+
+	if len(updates) == 0 {
+		return apierr.BadRequest("No fields to update")
+	}
+
+	updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, id)
+
+	endpoint, err := scanWebhookEndpoint(tx.QueryRow(
+		`UPDATE webhook_endpoints SET `+strings.Join(updates, ", ")+
+			` WHERE id = $`+strconv.Itoa(argIndex)+` RETURNING `+webhookEndpointColumns,
+		args...,
+	).Scan)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Webhook not found")
+	}
+	if err == nil {
+		err = audit(c, tx, "webhook.update", "webhook", id, before, webhookSnapshot(tx, id))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to update webhook", err)
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+	return nil
+}
+
+// DeleteWebhook removes an endpoint and its delivery log (admin only)
+func (h *Handlers) DeleteWebhook(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid webhook ID")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	before := webhookSnapshot(tx, id)
+
+	result, err := tx.Exec(`DELETE FROM webhook_endpoints WHERE id = $1`, id)
+	if err != nil {
+		return apierr.Internal("Failed to delete webhook", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Webhook not found")
+	}
+
+	err = audit(c, tx, "webhook.delete", "webhook", id, before, nil)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to delete webhook", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+	return nil
+}
+
+// RotateWebhookSecret replaces an endpoint's signing secret and returns the
+// new one (admin only)
+func (h *Handlers) RotateWebhookSecret(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid webhook ID")
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		return apierr.Internal("Failed to generate secret", err)
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE webhook_endpoints SET secret = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		secret, id,
+	)
+	if err != nil {
+		return apierr.Internal("Failed to rotate secret", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return apierr.NotFound("Webhook not found")
+	}
+
+	err = audit(c, tx, "webhook.rotate_secret", "webhook", id, nil, nil)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to rotate secret", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret})
+	return nil
+}
+
+// TestWebhook sends a signed ping to an endpoint (admin only)
+func (h *Handlers) TestWebhook(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid webhook ID")
+	}
+
+	deliveryID, err := h.Webhooks.SendTest(id)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		return apierr.NotFound("Webhook not found")
+	}
+	if err != nil {
+		return apierr.Internal("Failed to queue test delivery", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Test delivery queued", "delivery_id": deliveryID})
+	return nil
+}
+
+// webhookDeliverySorts are the sort keys GetWebhookDeliveries accepts
+var webhookDeliverySorts = listSorts{
+	"created_at": {"created_at", "timestamptz"},
+}
+
+// GetWebhookDeliveries returns a page of the delivery log, newest first by
+// default, filtered by endpoint_id, status and event_type (admin only)
+func (h *Handlers) GetWebhookDeliveries(c *gin.Context) error {
+	p, err := parsePage(c, webhookDeliverySorts, "-created_at", "id")
+	if err != nil {
+		return err
+	}
+
+	where := ` WHERE 1=1`
+	args := []interface{}{}
+	argIndex := 1
+
+	if v := c.Query("endpoint_id"); v != "" {
+		endpointID, err := strconv.Atoi(v)
+		if err != nil {
+			return apierr.BadRequest("Invalid endpoint_id")
+		}
+		where += ` AND endpoint_id = $` + strconv.Itoa(argIndex)
+		args = append(args, endpointID)
+		argIndex++
+	}
+	if v := c.Query("status"); v != "" {
+		where += ` AND status = $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+	if v := c.Query("event_type"); v != "" {
+		where += ` AND event_type = $` + strconv.Itoa(argIndex)
+		args = append(args, v)
+		argIndex++
+	}
+
+	after, afterArgs := p.where(argIndex)
+	rows, err := h.DB.Query(
+		`SELECT id, endpoint_id, event_id, event_type, status, attempts, response_code, last_error,
+		        next_attempt_at, delivered_at, created_at`+p.sortColumn()+`
+		 FROM webhook_deliveries`+where+after+p.orderBy(),
+		append(args, afterArgs...)...,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var sortValue string
+		if err := rows.Scan(
+			&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.ResponseCode, &d.LastError,
+			&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt, &sortValue,
+		); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		if !p.add(sortValue, d.ID) {
+			break
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	c.JSON(http.StatusOK, webhookDeliveriesPage{Deliveries: deliveries, NextCursor: p.nextCursor()})
+	return nil
+}
+
+// GetWebhookDelivery returns a delivery with its payload and every attempt made (admin only)
+func (h *Handlers) GetWebhookDelivery(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid delivery ID")
+	}
+
+	var d models.WebhookDelivery
+	var payload []byte
+	err = h.DB.QueryRow(
+		`SELECT id, endpoint_id, event_id, event_type, payload, status, attempts, response_code, last_error,
+		        next_attempt_at, delivered_at, created_at
+		 FROM webhook_deliveries WHERE id = $1`,
+		id,
+	).Scan(
+		&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.ResponseCode, &d.LastError,
+		&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return apierr.NotFound("Delivery not found")
+	}
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	d.Payload = payload
+
+	rows, err := h.DB.Query(
+		`SELECT attempt, response_code, response_body, error, duration_ms, created_at
+		 FROM webhook_attempts WHERE delivery_id = $1 ORDER BY id`,
+		id,
+	)
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer rows.Close()
+
+	d.AttemptLog = []models.WebhookAttempt{}
+	for rows.Next() {
+		var a models.WebhookAttempt
+		if err := rows.Scan(&a.Attempt, &a.ResponseCode, &a.ResponseBody, &a.Error, &a.DurationMs, &a.CreatedAt); err != nil {
+			return apierr.Internal("Database error", err)
+		}
+		d.AttemptLog = append(d.AttemptLog, a)
+	}
+
+	c.JSON(http.StatusOK, d)
+	return nil
+}
+
+// RedeliverWebhook sends a delivery again with a fresh set of retries (admin only)
+func (h *Handlers) RedeliverWebhook(c *gin.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return apierr.BadRequest("Invalid delivery ID")
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return apierr.Internal("Database error", err)
+	}
+	defer tx.Rollback()
+
+	err = h.Webhooks.Redeliver(tx, id)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		return apierr.NotFound("Delivery not found or already being sent")
+	}
+	if err == nil {
+		err = audit(c, tx, "webhook.redeliver", "webhook_delivery", id, nil, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return apierr.Internal("Failed to queue redelivery", err)
+	}
+	h.Webhooks.Wake()
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Redelivery queued"})
+	return nil
+}
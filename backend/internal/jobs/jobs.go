@@ -0,0 +1,340 @@
+// Package jobs runs scheduled background jobs. Each job runs on only one
+// replica at a time, coordinated through leases in Postgres.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// pollInterval is how often the runner looks for due jobs
+	pollInterval = 15 * time.Second
+	// leaseDuration is how long a claimed job is reserved for its runner; it
+	// is renewed while the job runs so a crashed replica's lease soon lapses
+	leaseDuration = time.Minute
+	// defaultTimeout bounds a job that doesn't set its own
+	defaultTimeout = 10 * time.Minute
+	// defaultMaxAttempts is how often a failing run is retried before the job
+	// waits for its next scheduled time
+	defaultMaxAttempts = 3
+	baseBackoff        = 30 * time.Second
+	// historyRetention is how long run history is kept
+	historyRetention = 30 * 24 * time.Hour
+)
+
+// Job is a unit of scheduled work
+type Job struct {
+	Name        string
+	Schedule    Schedule
+	Run         func(ctx context.Context) error
+	Timeout     time.Duration
+	MaxAttempts int
+	// RunOnStart makes the job due as soon as it is registered for the first time
+	RunOnStart bool
+}
+
+// Runner claims and runs due jobs
+type Runner struct {
+	db    *sql.DB
+	owner string
+
+	// ctx is the parent of every job's context; Stop cancels it when jobs
+	// don't finish in time
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	running map[string]bool
+	started bool
+	lastRun time.Time
+	lastErr error
+}
+
+// NewRunner creates a job runner backed by the scheduled_jobs tables
+func NewRunner(db *sql.DB) *Runner {
+	host, _ := os.Hostname()
+	var id [4]byte
+	rand.Read(id[:])
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		db:      db,
+		owner:   fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(id[:])),
+		ctx:     ctx,
+		cancel:  cancel,
+		stop:    make(chan struct{}),
+		jobs:    map[string]*Job{},
+		running: map[string]bool{},
+	}
+}
+
+// Register adds a job and records its schedule. A changed schedule takes
+// effect from now; otherwise the stored next run time is kept.
+func (r *Runner) Register(job Job) error {
+	if job.Timeout == 0 {
+		job.Timeout = defaultTimeout
+	}
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = defaultMaxAttempts
+	}
+
+	now := time.Now()
+	next := job.Schedule.Next(now)
+	if job.RunOnStart {
+		next = now
+	}
+
+	_, err := r.db.Exec(
+		`INSERT INTO scheduled_jobs (name, schedule, next_run_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (name) DO UPDATE SET
+		     schedule = EXCLUDED.schedule,
+		     next_run_at = CASE WHEN scheduled_jobs.schedule <> EXCLUDED.schedule
+		                        THEN EXCLUDED.next_run_at ELSE scheduled_jobs.next_run_at END`,
+		job.Name, job.Schedule.String(), next,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register job %s: %w", job.Name, err)
+	}
+
+	r.mu.Lock()
+	r.jobs[job.Name] = &job
+	r.mu.Unlock()
+	return nil
+}
+
+// Start polls for due jobs in the background
+func (r *Runner) Start() {
+	r.mu.Lock()
+	r.started = true
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			r.tick()
+			select {
+			case <-ticker.C:
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for running jobs to finish. Jobs still
+// running when ctx ends are cancelled; their leases lapse so another
+// replica retries them.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.started {
+		r.mu.Unlock()
+		return nil
+	}
+	r.started = false
+	r.mu.Unlock()
+	close(r.stop)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		return ctx.Err()
+	}
+}
+
+// Status reports whether the runner is started and when it last polled
+// successfully, for health checks
+func (r *Runner) Status() (started bool, lastPoll time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.started, r.lastRun, r.lastErr
+}
+
+// tick starts every registered job that is due and not already running here
+func (r *Runner) tick() {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.jobs))
+	for name := range r.jobs {
+		if !r.running[name] {
+			names = append(names, name)
+		}
+	}
+	r.mu.Unlock()
+
+	var pollErr error
+	for _, name := range names {
+		attempt, ok, err := r.claim(name)
+		if err != nil {
+			log.Printf("Failed to claim job %s: %v", name, err)
+			pollErr = err
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		r.mu.Lock()
+		job := r.jobs[name]
+		r.running[name] = true
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.run(job, attempt)
+			r.mu.Lock()
+			delete(r.running, job.Name)
+			r.mu.Unlock()
+		}()
+	}
+
+	r.mu.Lock()
+	r.lastErr = pollErr
+	if pollErr == nil {
+		r.lastRun = time.Now()
+	}
+	r.mu.Unlock()
+}
+
+// claim takes the lease on a due job, returning the attempt number
+func (r *Runner) claim(name string) (int, bool, error) {
+	var attempt int
+	err := r.db.QueryRow(
+		`UPDATE scheduled_jobs
+		 SET locked_by = $2, locked_until = CURRENT_TIMESTAMP + $3::int * INTERVAL '1 second',
+		     attempt = attempt + 1
+		 WHERE name = $1 AND next_run_at <= CURRENT_TIMESTAMP
+		   AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+		 RETURNING attempt`,
+		name, r.owner, int(leaseDuration.Seconds()),
+	).Scan(&attempt)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return attempt, true, nil
+}
+
+// run executes a claimed job, renewing its lease until it finishes, and
+// records the outcome
+func (r *Runner) run(job *Job, attempt int) {
+	var runID int
+	err := r.db.QueryRow(
+		`INSERT INTO job_runs (job_name, attempt, runner) VALUES ($1, $2, $3) RETURNING id`,
+		job.Name, attempt, r.owner,
+	).Scan(&runID)
+	if err != nil {
+		log.Printf("Failed to record start of job %s: %v", job.Name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, job.Timeout)
+	done := make(chan struct{})
+	go r.renew(ctx, job.Name, done)
+
+	started := time.Now()
+	runErr := safeRun(ctx, job)
+	close(done)
+	cancel()
+
+	var errText *string
+	status := "succeeded"
+	if runErr != nil {
+		status = "failed"
+		msg := runErr.Error()
+		errText = &msg
+		log.Printf("Job %s failed (attempt %d/%d): %v", job.Name, attempt, job.MaxAttempts, runErr)
+	}
+
+	if runID != 0 {
+		_, err := r.db.Exec(
+			`UPDATE job_runs SET status = $1, error = $2, finished_at = CURRENT_TIMESTAMP, duration_ms = $3
+			 WHERE id = $4`,
+			status, errText, time.Since(started).Milliseconds(), runID,
+		)
+		if err != nil {
+			log.Printf("Failed to record result of job %s: %v", job.Name, err)
+		}
+	}
+
+	// Retry failures with backoff until attempts run out, then wait for the next scheduled run
+	next := job.Schedule.Next(time.Now())
+	nextAttempt := 0
+	if runErr != nil && attempt < job.MaxAttempts {
+		next = time.Now().Add(baseBackoff << uint(attempt-1))
+		nextAttempt = attempt
+	}
+
+	_, err = r.db.Exec(
+		`UPDATE scheduled_jobs
+		 SET next_run_at = $2, attempt = $3, locked_by = NULL, locked_until = NULL,
+		     last_run_at = $4, last_status = $5, last_error = $6
+		 WHERE name = $1 AND locked_by = $7`,
+		job.Name, next, nextAttempt, started, status, errText, r.owner,
+	)
+	if err != nil {
+		log.Printf("Failed to reschedule job %s: %v", job.Name, err)
+	}
+}
+
+// renew extends the lease on a running job until done is closed
+func (r *Runner) renew(ctx context.Context, name string, done <-chan struct{}) {
+	ticker := time.NewTicker(leaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.db.Exec(
+				`UPDATE scheduled_jobs SET locked_until = CURRENT_TIMESTAMP + $3::int * INTERVAL '1 second'
+				 WHERE name = $1 AND locked_by = $2`,
+				name, r.owner, int(leaseDuration.Seconds()),
+			)
+			if err != nil {
+				log.Printf("Failed to renew lease on job %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// safeRun runs a job, turning a panic into an error
+func safeRun(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// PruneHistory deletes run history older than the retention period
+func (r *Runner) PruneHistory(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM job_runs WHERE started_at < CURRENT_TIMESTAMP - $1::int * INTERVAL '1 second'`,
+		int(historyRetention.Seconds()),
+	)
+	return err
+}
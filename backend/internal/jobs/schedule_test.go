@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+// bits builds the set parseField returns for the given values
+func bits(values ...int) uint64 {
+	var set uint64
+	for _, v := range values {
+		set |= 1 << uint(v)
+	}
+	return set
+}
+
+// span builds the set for every value from lo to hi
+func span(lo, hi int) uint64 {
+	var set uint64
+	for v := lo; v <= hi; v++ {
+		set |= 1 << uint(v)
+	}
+	return set
+}
+
+func date(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		field    string
+		min, max int
+		want     uint64
+	}{
+		{"*", 0, 59, span(0, 59)},
+		{"5", 0, 59, bits(5)},
+		{"1-5", 0, 59, span(1, 5)},
+		{"*/20", 0, 59, bits(0, 20, 40)},
+		{"10/15", 0, 59, bits(10, 25, 40, 55)},
+		{"1-10/3", 0, 59, bits(1, 4, 7, 10)},
+		{"1,15,30", 1, 31, bits(1, 15, 30)},
+		{"1-3,20-22", 0, 23, bits(1, 2, 3, 20, 21, 22)},
+		{"*/2", 1, 12, bits(1, 3, 5, 7, 9, 11)},
+		{"0-7", 0, 7, span(0, 7)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			got, err := parseField(tt.field, tt.min, tt.max)
+			if err != nil {
+				t.Fatalf("parseField(%q, %d, %d) returned error %v", tt.field, tt.min, tt.max, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseField(%q, %d, %d) = %b, want %b", tt.field, tt.min, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		min, max int
+	}{
+		{"above max", "60", 0, 59},
+		{"below min", "0", 1, 31},
+		{"reversed range", "5-1", 0, 59},
+		{"range past max", "20-25", 0, 23},
+		{"zero step", "*/0", 0, 59},
+		{"negative step", "*/-1", 0, 59},
+		{"not a number", "a", 0, 59},
+		{"bad range end", "1-b", 0, 59},
+		{"three-part range", "1-2-3", 0, 59},
+		{"empty", "", 0, 59},
+		{"empty list item", "1,,2", 0, 59},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := parseField(tt.field, tt.min, tt.max); err == nil {
+				t.Errorf("parseField(%q, %d, %d) = %b, want an error", tt.field, tt.min, tt.max, got)
+			}
+		})
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"0 24 * * *",
+		"0 0 0 * *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"@yearly",
+		"@every 500ms",
+		"@every soon",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) returned no error", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		after string
+		want  string
+	}{
+		{"next step", "*/15 * * * *", "2024-01-01T10:07:30Z", "2024-01-01T10:15:00Z"},
+		{"strictly after", "0 * * * *", "2024-01-01T10:00:00Z", "2024-01-01T11:00:00Z"},
+		{"hour range with step", "0 9-17/4 * * *", "2024-01-01T13:00:00Z", "2024-01-01T17:00:00Z"},
+		{"hour range wraps to next day", "0 9-17/4 * * *", "2024-01-01T17:30:00Z", "2024-01-02T09:00:00Z"},
+		{"daily rolls into next month", "@daily", "2024-01-31T23:59:00Z", "2024-02-01T00:00:00Z"},
+		{"skips months without the day", "30 9 31 * *", "2024-04-01T00:00:00Z", "2024-05-31T09:30:00Z"},
+		{"leap day", "0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"rolls into next year", "0 0 1 1 *", "2024-06-15T12:00:00Z", "2025-01-01T00:00:00Z"},
+		{"monthly", "@monthly", "2024-12-01T00:00:00Z", "2025-01-01T00:00:00Z"},
+		{"Sunday as 0", "0 12 * * 0", "2024-01-01T00:00:00Z", "2024-01-07T12:00:00Z"},
+		{"Sunday as 7", "0 12 * * 7", "2024-01-01T00:00:00Z", "2024-01-07T12:00:00Z"},
+		{"weekly", "@weekly", "2024-01-03T00:00:00Z", "2024-01-07T00:00:00Z"},
+		{"weekdays skip the weekend", "0 8 * * 1-5", "2024-01-05T09:00:00Z", "2024-01-08T08:00:00Z"},
+		{"day of month only", "0 8 15 * *", "2024-02-12T00:00:00Z", "2024-02-15T08:00:00Z"},
+		{"day of week matches first", "0 8 15 * 1", "2024-02-06T00:00:00Z", "2024-02-12T08:00:00Z"},
+		{"day of month matches first", "0 8 15 * 1", "2024-02-12T08:00:00Z", "2024-02-15T08:00:00Z"},
+		{"converts to UTC", "0 0 * * *", "2024-01-01T03:00:00+05:30", "2024-01-01T00:00:00Z"},
+		{"every", "@every 90s", "2024-01-01T10:00:00Z", "2024-01-01T10:01:30Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) returned error %v", tt.spec, err)
+			}
+			got := s.Next(date(tt.after))
+			if want := date(tt.want); !got.Equal(want) {
+				t.Errorf("%q.Next(%s) = %s, want %s", tt.spec, tt.after, got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestDayMatches(t *testing.T) {
+	// 12 Feb 2024 is a Monday, 13 Feb a Tuesday and 15 Feb a Thursday
+	tests := []struct {
+		spec string
+		day  string
+		want bool
+	}{
+		{"* * * * *", "2024-02-13T00:00:00Z", true},
+		{"* * 15 * *", "2024-02-15T00:00:00Z", true},
+		{"* * 15 * *", "2024-02-12T00:00:00Z", false},
+		{"* * * * 1", "2024-02-12T00:00:00Z", true},
+		{"* * * * 1", "2024-02-15T00:00:00Z", false},
+		{"* * 15 * 1", "2024-02-12T00:00:00Z", true},
+		{"* * 15 * 1", "2024-02-15T00:00:00Z", true},
+		{"* * 15 * 1", "2024-02-13T00:00:00Z", false},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q) returned error %v", tt.spec, err)
+		}
+		if got := s.(cron).dayMatches(date(tt.day)); got != tt.want {
+			t.Errorf("%q.dayMatches(%s) = %v, want %v", tt.spec, tt.day[:10], got, tt.want)
+		}
+	}
+}
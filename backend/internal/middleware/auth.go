@@ -1,9 +1,10 @@
 package middleware
 
 import (
-	"net/http"
 	"strings"
 
+	"cpool.ai/backend/internal/apierr"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -13,7 +14,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			c.Error(apierr.Unauthorized("Authorization header required"))
 			c.Abort()
 			return
 		}
@@ -21,7 +22,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		// Extract token from "Bearer <token>"
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			c.Error(apierr.Unauthorized("Invalid authorization header format"))
 			c.Abort()
 			return
 		}
@@ -34,7 +35,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Error(apierr.Unauthorized("Invalid token"))
 			c.Abort()
 			return
 		}
@@ -42,7 +43,7 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		// Extract claims
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			c.Error(apierr.Unauthorized("Invalid token claims"))
 			c.Abort()
 			return
 		}
@@ -61,7 +62,7 @@ func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("user_role")
 		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Error(apierr.Forbidden("Admin access required"))
 			c.Abort()
 			return
 		}
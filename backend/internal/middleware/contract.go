@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+
+	"cpool.ai/backend/internal/apispec"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCheckedBody is the largest response body ContractMiddleware validates
+const maxCheckedBody = 1 << 20
+
+// ContractMiddleware logs responses that don't match the OpenAPI document.
+// It buffers every response body, so it is meant for development and
+// staging rather than production.
+func ContractMiddleware(doc *apispec.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if c.FullPath() == "" || recorder.truncated {
+			return
+		}
+		err := doc.CheckResponse(c.Request.Method, c.FullPath(), c.Writer.Status(),
+			c.Writer.Header().Get("Content-Type"), recorder.body.Bytes())
+		if err != nil {
+			log.Printf("API contract: %v", err)
+		}
+	}
+}
+
+// bodyRecorder keeps a copy of what a handler writes
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.record(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyRecorder) record(b []byte) {
+	if w.body.Len()+len(b) > maxCheckedBody {
+		w.truncated = true
+		return
+	}
+	w.body.Write(b)
+}
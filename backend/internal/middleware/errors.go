@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strconv"
+
+	"cpool.ai/backend/internal/apierr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorMiddleware writes the last error recorded with c.Error as the
+// standard error body, and turns panics into internal errors. Causes of 5xx
+// errors are logged with the request ID the client sees.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				c.Abort()
+				writeError(c, apierr.Internal("Internal server error", fmt.Errorf("panic: %v\n%s", rec, debug.Stack())))
+			}
+		}()
+
+		c.Next()
+
+		if len(c.Errors) > 0 {
+			writeError(c, apierr.From(c.Errors.Last().Err))
+		}
+	}
+}
+
+func writeError(c *gin.Context, e *apierr.Error) {
+	requestID := c.GetString("request_id")
+	if e.Status >= 500 {
+		slog.Error("request failed", "method", c.Request.Method, "path", c.Request.URL.Path,
+			"request_id", requestID, "error", e.Error())
+	}
+	if c.Writer.Written() {
+		return
+	}
+	if e.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(e.RetryAfterSeconds()))
+	}
+	c.JSON(e.Status, e.Body(requestID))
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"cpool.ai/backend/internal/presence"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresenceMiddleware records the authenticated user as active.
+// It must run after AuthMiddleware.
+func PresenceMiddleware(tracker *presence.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID, ok := c.Get("user_id"); ok {
+			tracker.Touch(userID.(int))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+
+	"cpool.ai/backend/internal/apierr"
+	"cpool.ai/backend/internal/metrics"
+	"cpool.ai/backend/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+var throttledRequests = metrics.NewCounter("http_requests_throttled_total",
+	"Requests rejected by rate limits, by limit name", "limit")
+
+// Limits are the rates a route allows each client IP and, once signed in,
+// each user. A zero rate sets no limit of that kind.
+type Limits struct {
+	PerIP   ratelimit.Rate
+	PerUser ratelimit.Rate
+}
+
+// RateLimit enforces limits, named by name, on the routes it guards. Every
+// bucket is keyed by route as well, so routes sharing a name don't share
+// buckets. Users are only known after AuthMiddleware, so it must run after
+// it where there is one. If the limiter fails the request goes through
+// rather than taking the route down with it.
+func RateLimit(limiter ratelimit.Limiter, name string, limits Limits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prefix := name + ":" + c.Request.Method + " " + c.FullPath()
+		type bucket struct {
+			key  string
+			rate ratelimit.Rate
+		}
+		var buckets []bucket
+		if limits.PerIP.Limit > 0 {
+			buckets = append(buckets, bucket{prefix + ":ip:" + c.ClientIP(), limits.PerIP})
+		}
+		if userID, ok := c.Get("user_id"); ok && limits.PerUser.Limit > 0 {
+			buckets = append(buckets, bucket{prefix + ":user:" + strconv.Itoa(userID.(int)), limits.PerUser})
+		}
+
+		var wait time.Duration
+		for _, b := range buckets {
+			allowed, retryAfter, err := limiter.Allow(c.Request.Context(), b.key, b.rate)
+			if err != nil {
+				slog.Warn("rate limit check failed", "limit", name, "error", err.Error())
+				continue
+			}
+			if !allowed && retryAfter > wait {
+				wait = retryAfter
+			}
+		}
+		if wait > 0 {
+			throttledRequests.Inc(name)
+			c.Error(apierr.TooManyRequests(apierr.CodeRateLimited, "Too many requests, try again later", wait))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
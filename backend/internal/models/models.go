@@ -1,81 +1,168 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // User represents a user in the system
 type User struct {
-	ID            int       `json:"id"`
-	Email         string    `json:"email"`
-	Name          string    `json:"name"`
-	Phone         *string   `json:"phone"`
-	City          *string   `json:"city"`
-	Role          string    `json:"role"`
-	CarbCredits   int       `json:"carbon_credits"`
-	UPIID         *string   `json:"upi_id"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                int                `json:"id"`
+	Email             string             `json:"email"`
+	Name              string             `json:"name"`
+	Phone             *string            `json:"phone"`
+	City              *string            `json:"city"`
+	Gender            *string            `json:"gender"`
+	Role              string             `json:"role"`
+	CarbCredits       int                `json:"carbon_credits"`
+	UPIID             *string            `json:"upi_id"`
+	Rating            *float64           `json:"rating,omitempty"`
+	ReviewCount       *int               `json:"review_count,omitempty"`
+	EmergencyContacts []EmergencyContact `json:"emergency_contacts,omitempty"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
 }
 
 // City represents a city
 type City struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	Timezone  string     `json:"timezone"`
+	Currency  string     `json:"currency"`
+	Locale    string     `json:"locale"`
+	LaunchAt  *time.Time `json:"launch_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// WaitlistEntry represents a user waiting for a locked city to launch
+type WaitlistEntry struct {
+	ID            int        `json:"id"`
+	CityID        int        `json:"city_id"`
+	UserID        int        `json:"user_id"`
+	UserName      string     `json:"user_name,omitempty"`
+	UserEmail     string     `json:"user_email,omitempty"`
+	PreferredFrom string     `json:"preferred_from"`
+	PreferredTo   string     `json:"preferred_to"`
+	CommuteStart  *string    `json:"commute_start"`
+	CommuteReturn *string    `json:"commute_return"`
+	NotifiedAt    *time.Time `json:"notified_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// DemandRoute aggregates waitlist signups for one from/to pair
+type DemandRoute struct {
+	LocationFrom      string `json:"location_from"`
+	LocationTo        string `json:"location_to"`
+	Signups           int    `json:"signups"`
+	PeakDepartureHour *int   `json:"peak_departure_hour"`
+	PeakReturnHour    *int   `json:"peak_return_hour"`
+	HasCorridor       bool   `json:"has_corridor"`
+	Suggested         bool   `json:"suggested"`
+	SuggestedName     string `json:"suggested_name,omitempty"`
+}
+
+// DemandReport summarises waitlist demand for a city
+type DemandReport struct {
+	CityID       int           `json:"city_id"`
+	CityName     string        `json:"city_name"`
+	Status       string        `json:"status"`
+	TotalSignups int           `json:"total_signups"`
+	MinSignups   int           `json:"min_signups"`
+	Routes       []DemandRoute `json:"routes"`
 }
 
 // Corridor represents a corridor
 type Corridor struct {
-	ID              int       `json:"id"`
-	CityID          int       `json:"city_id"`
-	CityName        string    `json:"city_name,omitempty"`
-	Name            string    `json:"name"`
-	LocationFrom    string    `json:"location_from"`
-	LocationTo      string    `json:"location_to"`
-	PickupPoints    *string   `json:"pickup_points"`
-	TermsConditions *string   `json:"terms_conditions"`
-	IsActive        bool      `json:"is_active"`
-	MapEnabled      bool      `json:"map_enabled"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID               int       `json:"id"`
+	CityID           int       `json:"city_id"`
+	CityName         string    `json:"city_name,omitempty"`
+	Name             string    `json:"name"`
+	LocationFrom     string    `json:"location_from"`
+	LocationTo       string    `json:"location_to"`
+	PickupPoints     *string   `json:"pickup_points"`
+	TermsConditions  *string   `json:"terms_conditions"`
+	IsActive         bool      `json:"is_active"`
+	MapEnabled       bool      `json:"map_enabled"`
+	WomenOnlyAllowed bool      `json:"women_only_allowed"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // Vehicle represents a vehicle
 type Vehicle struct {
-	ID                    int       `json:"id"`
-	UserID                int       `json:"user_id"`
-	VehicleType           string    `json:"vehicle_type"`
-	Make                  string    `json:"make"`
-	Model                 string    `json:"model"`
-	Color                 *string   `json:"color"`
-	VehicleNumber         string    `json:"vehicle_number"`
-	TotalSeats            int       `json:"total_seats"`
-	DefaultAvailableSeats int       `json:"default_available_seats"`
-	CreatedAt             time.Time `json:"created_at"`
-	UpdatedAt             time.Time `json:"updated_at"`
+	ID                    int        `json:"id"`
+	UserID                int        `json:"user_id"`
+	VehicleType           string     `json:"vehicle_type"`
+	Make                  string     `json:"make"`
+	Model                 string     `json:"model"`
+	Color                 *string    `json:"color"`
+	VehicleNumber         string     `json:"vehicle_number"`
+	StateCode             *string    `json:"state_code"`
+	RTOCode               *string    `json:"rto_code"`
+	TotalSeats            int        `json:"total_seats"`
+	DefaultAvailableSeats int        `json:"default_available_seats"`
+	VerificationStatus    string     `json:"verification_status"`
+	VerificationNote      *string    `json:"verification_note"`
+	VerifiedAt            *time.Time `json:"verified_at"`
+	ArchivedAt            *time.Time `json:"archived_at"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// VehicleDocument represents an uploaded RC, insurance or driving licence
+type VehicleDocument struct {
+	ID          int        `json:"id"`
+	VehicleID   int        `json:"vehicle_id"`
+	DocType     string     `json:"doc_type"`
+	FileName    string     `json:"file_name"`
+	ContentType string     `json:"content_type"`
+	SizeBytes   int64      `json:"size_bytes"`
+	ExpiresOn   *string    `json:"expires_on"`
+	Status      string     `json:"status"`
+	ReviewNote  *string    `json:"review_note"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// VehicleReview is a vehicle awaiting admin verification with its documents
+type VehicleReview struct {
+	Vehicle   Vehicle           `json:"vehicle"`
+	OwnerName string            `json:"owner_name"`
+	Documents []VehicleDocument `json:"documents"`
 }
 
 // Ride represents a ride
 type Ride struct {
-	ID               int       `json:"id"`
-	UserID           int       `json:"user_id"`
-	UserName         string    `json:"user_name,omitempty"`
-	CorridorID       int       `json:"corridor_id"`
-	CorridorName     string    `json:"corridor_name,omitempty"`
-	VehicleID        *int      `json:"vehicle_id"`
-	VehicleInfo      *Vehicle  `json:"vehicle_info,omitempty"`
-	RideDate         string    `json:"ride_date"`
-	RideTime         string    `json:"ride_time"`
-	PickupPoint      string    `json:"pickup_point"`
-	DropPoint        string    `json:"drop_point"`
-	RouteDescription *string   `json:"route_description"`
-	PricePerSeat     float64   `json:"price_per_seat"`
-	AvailableSeats   int       `json:"available_seats"`
-	TotalSeats        int       `json:"total_seats"`
-	Status            string    `json:"status"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID               int        `json:"id"`
+	UserID           int        `json:"user_id"`
+	UserName         string     `json:"user_name,omitempty"`
+	CorridorID       int        `json:"corridor_id"`
+	CorridorName     string     `json:"corridor_name,omitempty"`
+	VehicleID        *int       `json:"vehicle_id"`
+	VehicleInfo      *Vehicle   `json:"vehicle_info,omitempty"`
+	RideDate         string     `json:"ride_date"`
+	RideTime         string     `json:"ride_time"`
+	PickupPoint      string     `json:"pickup_point"`
+	DropPoint        string     `json:"drop_point"`
+	RouteDescription *string    `json:"route_description"`
+	PricePerSeat     float64    `json:"price_per_seat"`
+	AvailableSeats   int        `json:"available_seats"`
+	TotalSeats       int        `json:"total_seats"`
+	Status           string     `json:"status"`
+	Visibility       string     `json:"visibility"`
+	Timezone         string     `json:"timezone,omitempty"`
+	Currency         string     `json:"currency,omitempty"`
+	DriverRating     *float64   `json:"driver_rating"`
+	DriverReviews    int        `json:"driver_review_count"`
+	BookedSeats      *int       `json:"booked_seats,omitempty"`
+	CancelReason     *string    `json:"cancel_reason,omitempty"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 }
 
 // RideRequest represents a ride request
@@ -129,11 +216,267 @@ type CarbonCredit struct {
 
 // FeatureFlag represents a feature flag
 type FeatureFlag struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Enabled     bool      `json:"enabled"`
-	Description *string   `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                int             `json:"id"`
+	Name              string          `json:"name"`
+	Enabled           bool            `json:"enabled"`
+	Description       *string         `json:"description"`
+	RolloutPercentage int             `json:"rollout_percentage"`
+	Targeting         json.RawMessage `json:"targeting"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
 }
 
+// Notification represents an in-app notification for a user
+type Notification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Type      string     `json:"type"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AuditEntry represents an append-only record of an admin or owner action
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    *int            `json:"actor_id"`
+	ActorEmail *string         `json:"actor_email"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IPAddress  string          `json:"ip_address"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// RideTimeline is the full history of a ride for admin review
+type RideTimeline struct {
+	Ride     Ride          `json:"ride"`
+	Requests []RideRequest `json:"requests"`
+	Messages []Message     `json:"messages"`
+	Payments []Payment     `json:"payments"`
+	Events   []RideEvent   `json:"events"`
+}
+
+// RideEvent is a single entry in a ride's timeline
+type RideEvent struct {
+	At        time.Time `json:"at"`
+	Type      string    `json:"type"`
+	ActorID   *int      `json:"actor_id"`
+	ActorName string    `json:"actor_name,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// MetricsBucket is one time bucket of ride metrics for a city or corridor
+type MetricsBucket struct {
+	Bucket             string   `json:"bucket"`
+	CityID             int      `json:"city_id"`
+	CityName           string   `json:"city_name"`
+	CorridorID         *int     `json:"corridor_id,omitempty"`
+	CorridorName       *string  `json:"corridor_name,omitempty"`
+	RidesOffered       int      `json:"rides_offered"`
+	RidesCompleted     int      `json:"rides_completed"`
+	RidesCancelled     int      `json:"rides_cancelled"`
+	SeatsOffered       int      `json:"seats_offered"`
+	SeatsFilled        int      `json:"seats_filled"`
+	FillRate           float64  `json:"fill_rate"`
+	RequestsMade       int      `json:"requests_made"`
+	RequestsAccepted   int      `json:"requests_accepted"`
+	AcceptanceRate     float64  `json:"acceptance_rate"`
+	AvgResponseMinutes *float64 `json:"avg_response_minutes"`
+	RevenueSettled     float64  `json:"revenue_settled"`
+	CreditsIssued      int      `json:"credits_issued"`
+}
+
+// OnlineCount is the number of users online in a city or corridor
+type OnlineCount struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	CityID      *int   `json:"city_id,omitempty"`
+	UsersOnline int    `json:"users_online"`
+}
+
+// Review is a rating one ride participant gave another
+type Review struct {
+	ID             int        `json:"id"`
+	RideID         int        `json:"ride_id"`
+	ReviewerID     int        `json:"reviewer_id"`
+	ReviewerName   string     `json:"reviewer_name,omitempty"`
+	RevieweeID     int        `json:"reviewee_id"`
+	RevieweeName   string     `json:"reviewee_name,omitempty"`
+	Rating         int        `json:"rating"`
+	Tags           []string   `json:"tags"`
+	Comment        *string    `json:"comment"`
+	Status         string     `json:"status,omitempty"`
+	ModerationNote *string    `json:"moderation_note,omitempty"`
+	ModeratedAt    *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// RatingSummary aggregates the visible reviews a user has received, with
+// one page of the reviews themselves
+type RatingSummary struct {
+	UserID     int            `json:"user_id"`
+	Average    *float64       `json:"average"`
+	Count      int            `json:"count"`
+	Stars      map[int]int    `json:"stars"`
+	Tags       map[string]int `json:"tags"`
+	Reviews    []Review       `json:"reviews"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// BlockedUser is an entry in the current user's block list
+type BlockedUser struct {
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Report is an abuse report about a user, message or ride
+type Report struct {
+	ID               int        `json:"id"`
+	ReporterID       int        `json:"reporter_id"`
+	ReporterName     string     `json:"reporter_name,omitempty"`
+	TargetType       string     `json:"target_type"`
+	TargetID         int        `json:"target_id"`
+	ReportedUserID   *int       `json:"reported_user_id"`
+	ReportedUserName *string    `json:"reported_user_name,omitempty"`
+	ReportCount      int        `json:"reported_user_open_reports"`
+	Category         string     `json:"category"`
+	Description      *string    `json:"description"`
+	Evidence         *string    `json:"evidence"`
+	Status           string     `json:"status"`
+	ResolutionNote   *string    `json:"resolution_note"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// EmergencyContact is someone to alert when a user raises an SOS
+type EmergencyContact struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Phone        string    `json:"phone"`
+	Email        *string   `json:"email"`
+	Relationship *string   `json:"relationship"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SOSAlert is an emergency raised by a user, usually during a ride
+type SOSAlert struct {
+	ID               int        `json:"id"`
+	UserID           int        `json:"user_id"`
+	UserName         string     `json:"user_name,omitempty"`
+	UserPhone        *string    `json:"user_phone,omitempty"`
+	RideID           *int       `json:"ride_id"`
+	Latitude         *float64   `json:"latitude"`
+	Longitude        *float64   `json:"longitude"`
+	AccuracyM        *float64   `json:"accuracy_m"`
+	Message          *string    `json:"message"`
+	ContactsNotified int        `json:"contacts_notified"`
+	Status           string     `json:"status"`
+	ResolutionNote   *string    `json:"resolution_note"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// SharedRide is the public view of a ride behind a share link
+type SharedRide struct {
+	RideID       int       `json:"ride_id"`
+	SharedBy     string    `json:"shared_by"`
+	DriverName   string    `json:"driver_name"`
+	DriverRating *float64  `json:"driver_rating"`
+	Vehicle      *Vehicle  `json:"vehicle,omitempty"`
+	CorridorName string    `json:"corridor_name"`
+	RideDate     string    `json:"ride_date"`
+	RideTime     string    `json:"ride_time"`
+	Timezone     string    `json:"timezone"`
+	PickupPoint  string    `json:"pickup_point"`
+	DropPoint    string    `json:"drop_point"`
+	Status       string    `json:"status"`
+	Phase        string    `json:"phase"`
+	SOSActive    bool      `json:"sos_active"`
+	LastLocation *Location `json:"last_location,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Location is a reported position
+type Location struct {
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	AccuracyM  *float64  `json:"accuracy_m,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// ScheduledJob is a background job's schedule and latest outcome
+type ScheduledJob struct {
+	Name       string     `json:"name"`
+	Schedule   string     `json:"schedule"`
+	NextRunAt  time.Time  `json:"next_run_at"`
+	Attempt    int        `json:"attempt"`
+	Running    bool       `json:"running"`
+	LockedBy   *string    `json:"locked_by"`
+	LastRunAt  *time.Time `json:"last_run_at"`
+	LastStatus *string    `json:"last_status"`
+	LastError  *string    `json:"last_error"`
+}
+
+// JobRun is one execution of a background job
+type JobRun struct {
+	ID         int        `json:"id"`
+	JobName    string     `json:"job_name"`
+	Attempt    int        `json:"attempt"`
+	Runner     string     `json:"runner"`
+	Status     string     `json:"status"`
+	Error      *string    `json:"error"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	DurationMs *int64     `json:"duration_ms"`
+}
+
+// WebhookEndpoint is a partner URL that receives events. The secret is only
+// returned when it is generated.
+type WebhookEndpoint struct {
+	ID                  int        `json:"id"`
+	URL                 string     `json:"url"`
+	Description         *string    `json:"description"`
+	EventTypes          []string   `json:"event_types"`
+	Secret              string     `json:"secret,omitempty"`
+	IsActive            bool       `json:"is_active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccessAt       *time.Time `json:"last_success_at"`
+	LastFailureAt       *time.Time `json:"last_failure_at"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	DisabledReason      *string    `json:"disabled_reason"`
+	CreatedBy           *int       `json:"created_by"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// WebhookDelivery is an event queued for one endpoint
+type WebhookDelivery struct {
+	ID            int              `json:"id"`
+	EndpointID    int              `json:"endpoint_id"`
+	EventID       *int64           `json:"event_id"`
+	EventType     string           `json:"event_type"`
+	Payload       json.RawMessage  `json:"payload,omitempty"`
+	Status        string           `json:"status"`
+	Attempts      int              `json:"attempts"`
+	ResponseCode  *int             `json:"response_code"`
+	LastError     *string          `json:"last_error"`
+	NextAttemptAt time.Time        `json:"next_attempt_at"`
+	DeliveredAt   *time.Time       `json:"delivered_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+	AttemptLog    []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt is one request made for a delivery
+type WebhookAttempt struct {
+	Attempt      int       `json:"attempt"`
+	ResponseCode *int      `json:"response_code"`
+	ResponseBody *string   `json:"response_body"`
+	Error        *string   `json:"error"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// LogChannel writes deliveries to the server log instead of sending them. It
+// stands in for channels that aren't configured in development.
+type LogChannel struct {
+	Name string
+}
+
+// Send logs the delivery
+func (l LogChannel) Send(ctx context.Context, d Delivery) error {
+	log.Printf("[notify:%s] to=%s kind=%s title=%q body=%q", l.Name, d.Address, d.Kind, d.Title, d.Body)
+	return nil
+}
@@ -0,0 +1,366 @@
+// Package notify delivers user notifications to the in-app inbox and to
+// external channels such as email and web push.
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Channel names
+const (
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+const (
+	// maxAttempts is how many times a delivery is tried before it is marked failed
+	maxAttempts = 6
+	// baseBackoff is the delay before the first retry; it doubles on each attempt
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+	// claimTimeout releases deliveries claimed by a worker that never finished them
+	claimTimeout = 10 * time.Minute
+	batchSize    = 50
+	sendTimeout  = 30 * time.Second
+)
+
+// Notification is a message for one user. When Title is empty it is rendered
+// from the template registered for Kind using Data.
+type Notification struct {
+	UserID int
+	Kind   string
+	Title  string
+	Body   string
+	Data   map[string]interface{}
+}
+
+// Delivery is one attempt to reach a recipient through a channel
+type Delivery struct {
+	ID      int
+	UserID  *int
+	Channel string
+	Address string
+	Kind    string
+	Title   string
+	Body    string
+	Attempt int
+}
+
+// Channel sends deliveries to an external destination
+type Channel interface {
+	Send(ctx context.Context, d Delivery) error
+}
+
+// permanentError marks a failure that retrying won't fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the delivery is failed without further retries
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Service records notifications and delivers them asynchronously
+type Service struct {
+	db       *sql.DB
+	channels map[string]Channel
+	wake     chan struct{}
+	// stop interrupts the delivery loop between batches; done reports that
+	// the loop has exited
+	stop chan struct{}
+	done chan struct{}
+	mu   sync.Mutex
+}
+
+// NewService creates a notification service backed by the notifications tables
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db, channels: map[string]Channel{}, wake: make(chan struct{}, 1), stop: make(chan struct{})}
+}
+
+// Register makes a channel available under name
+func (s *Service) Register(name string, ch Channel) {
+	s.channels[name] = ch
+}
+
+// PushPublicKey returns the VAPID key browsers subscribe with, or an empty
+// string when web push isn't configured
+func (s *Service) PushPublicKey() string {
+	if p, ok := s.channels[ChannelPush].(interface{ PublicKey() string }); ok {
+		return p.PublicKey()
+	}
+	return ""
+}
+
+// Notify stores n in the user's inbox and queues it on every external
+// channel the user receives this kind of notification on
+func (s *Service) Notify(n Notification) error {
+	kind := lookupKind(n.Kind)
+	if n.Title == "" {
+		var err error
+		if n.Title, n.Body, err = kind.render(n.Data); err != nil {
+			return fmt.Errorf("failed to render %s notification: %w", n.Kind, err)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var notificationID int
+	err = tx.QueryRow(
+		`INSERT INTO notifications (user_id, type, title, body) VALUES ($1, $2, $3, $4) RETURNING id`,
+		n.UserID, n.Kind, n.Title, n.Body,
+	).Scan(&notificationID)
+	if err != nil {
+		return err
+	}
+
+	channels, err := enabledChannels(tx, n.UserID, n.Kind, kind)
+	if err != nil {
+		return err
+	}
+
+	queued := 0
+	for _, channel := range channels {
+		if _, ok := s.channels[channel]; !ok {
+			continue
+		}
+		addresses, err := userAddresses(tx, channel, n.UserID)
+		if err != nil {
+			return err
+		}
+		for _, address := range addresses {
+			_, err := tx.Exec(
+				`INSERT INTO notification_deliveries (notification_id, user_id, channel, address, kind, title, body)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				notificationID, n.UserID, channel, address, n.Kind, n.Title, n.Body,
+			)
+			if err != nil {
+				return err
+			}
+			queued++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if queued > 0 {
+		s.poke()
+	}
+	return nil
+}
+
+// NotifyAddress queues a message to someone without an account, such as an
+// emergency contact's email address
+func (s *Service) NotifyAddress(channel, address, kind, title, body string) error {
+	if _, ok := s.channels[channel]; !ok {
+		return fmt.Errorf("notification channel %q is not configured", channel)
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO notification_deliveries (channel, address, kind, title, body) VALUES ($1, $2, $3, $4, $5)`,
+		channel, address, kind, title, body,
+	)
+	if err == nil {
+		s.poke()
+	}
+	return err
+}
+
+// poke wakes the delivery worker without blocking the caller
+func (s *Service) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start delivers queued notifications as they arrive and retries failures every interval
+func (s *Service) Start(interval time.Duration) {
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Process(); err != nil {
+				log.Printf("Failed to deliver notifications: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.wake:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the delivery loop once the notifications in the current batch
+// have been sent, waiting for it until ctx ends
+func (s *Service) Stop(ctx context.Context) error {
+	if s.done == nil {
+		return nil
+	}
+	close(s.stop)
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Process sends every delivery that is due, batch by batch
+func (s *Service) Process() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		batch, err := s.claim()
+		if err != nil {
+			return err
+		}
+		for _, d := range batch {
+			s.deliver(d)
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+// claim locks a batch of due deliveries so other replicas skip them
+func (s *Service) claim() ([]Delivery, error) {
+	rows, err := s.db.Query(
+		`UPDATE notification_deliveries
+		 SET status = 'sending', attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id IN (
+		     SELECT id FROM notification_deliveries
+		     WHERE (status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP)
+		        OR (status = 'sending' AND updated_at < CURRENT_TIMESTAMP - $1::int * INTERVAL '1 second')
+		     ORDER BY next_attempt_at
+		     LIMIT $2
+		     FOR UPDATE SKIP LOCKED)
+		 RETURNING id, user_id, channel, address, kind, title, body, attempts`,
+		int(claimTimeout.Seconds()), batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []Delivery
+	for rows.Next() {
+		var d Delivery
+		var userID sql.NullInt64
+		if err := rows.Scan(&d.ID, &userID, &d.Channel, &d.Address, &d.Kind, &d.Title, &d.Body, &d.Attempt); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			d.UserID = &id
+		}
+		batch = append(batch, d)
+	}
+	return batch, rows.Err()
+}
+
+// deliver sends one delivery and records the outcome
+func (s *Service) deliver(d Delivery) {
+	ch, ok := s.channels[d.Channel]
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("notification channel %q is not configured", d.Channel))
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = ch.Send(ctx, d)
+		cancel()
+	}
+
+	if err == nil {
+		_, err = s.db.Exec(
+			`UPDATE notification_deliveries
+			 SET status = 'sent', sent_at = CURRENT_TIMESTAMP, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $1`,
+			d.ID,
+		)
+		if err != nil {
+			log.Printf("Failed to record notification delivery %d: %v", d.ID, err)
+		}
+		return
+	}
+
+	var permanent permanentError
+	if errors.As(err, &permanent) || d.Attempt >= maxAttempts {
+		log.Printf("Notification delivery %d via %s failed: %v", d.ID, d.Channel, err)
+		_, err = s.db.Exec(
+			`UPDATE notification_deliveries
+			 SET status = 'failed', last_error = $1, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $2`,
+			err.Error(), d.ID,
+		)
+	} else {
+		_, err = s.db.Exec(
+			`UPDATE notification_deliveries
+			 SET status = 'pending', last_error = $1, updated_at = CURRENT_TIMESTAMP,
+			     next_attempt_at = CURRENT_TIMESTAMP + $2::int * INTERVAL '1 second'
+			 WHERE id = $3`,
+			err.Error(), int(backoff(d.Attempt).Seconds()), d.ID,
+		)
+	}
+	if err != nil {
+		log.Printf("Failed to record notification delivery %d: %v", d.ID, err)
+	}
+}
+
+// backoff returns the delay before retrying after the given attempt
+func backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// userAddresses returns where a user receives messages on a channel
+func userAddresses(tx *sql.Tx, channel string, userID int) ([]string, error) {
+	var query string
+	switch channel {
+	case ChannelEmail:
+		query = `SELECT email FROM users WHERE id = $1`
+	case ChannelPush:
+		query = `SELECT endpoint FROM push_subscriptions WHERE user_id = $1`
+	default:
+		return nil, nil
+	}
+
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
@@ -0,0 +1,253 @@
+package notify
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"text/template"
+)
+
+// Kind describes a type of notification: how it is worded and which external
+// channels users receive it on unless they opt out
+type Kind struct {
+	Title    string
+	Body     string
+	Channels []string
+	// Critical notifications ignore preferences and go to every channel
+	Critical bool
+}
+
+// defaultKind applies to notification types without a registered Kind
+var defaultKind = Kind{Channels: []string{ChannelPush}}
+
+// Kinds lists the notification types users can set preferences for. Kinds
+// without a template are always sent with a title and body from the caller.
+var Kinds = map[string]Kind{
+	"ride_request_created": {
+		Title:    "New request for your ride",
+		Body:     "{{.rider}} requested {{.seats}} seat(s) on your ride on {{.date}} at {{.time}}.",
+		Channels: []string{ChannelEmail, ChannelPush},
+	},
+	"ride_request_accepted": {
+		Title:    "Your ride request was accepted",
+		Body:     "{{.driver}} accepted your request for {{.seats}} seat(s) on {{.date}} at {{.time}}. Pickup: {{.pickup}}.",
+		Channels: []string{ChannelEmail, ChannelPush},
+	},
+	"ride_request_rejected": {
+		Title:    "Your ride request was declined",
+		Body:     "{{.driver}} couldn't take your request for the ride on {{.date}} at {{.time}}.",
+		Channels: []string{ChannelEmail, ChannelPush},
+	},
+	"message_received": {
+		Title:    "New message from {{.sender}}",
+		Body:     "{{.message}}",
+		Channels: []string{ChannelPush},
+	},
+	"payment_marked": {
+		Title:    "Payment {{.status}}",
+		Body:     "{{.actor}} marked the payment of {{.amount}} for the ride on {{.date}} as {{.status}}.",
+		Channels: []string{ChannelEmail, ChannelPush},
+	},
+	"ride_request_expired": {
+		Title:    "Your ride request expired",
+		Body:     "The ride on {{.date}} at {{.time}} left before {{.driver}} answered your request.",
+		Channels: []string{ChannelPush},
+	},
+	"ride_reminder": {
+		Title:    "Your ride leaves at {{.time}}",
+		Body:     "Reminder: the ride on {{.date}} departs at {{.time}} from {{.pickup}}.",
+		Channels: []string{ChannelEmail, ChannelPush},
+	},
+	"city_launched": {
+		Title:    "cpool.ai is live in {{.city}}",
+		Body:     "Carpooling is now open in {{.city}}. Ask your admin to assign your corridor and start sharing rides.",
+		Channels: []string{ChannelEmail, ChannelPush},
+	},
+	"ride_cancelled":       {Channels: []string{ChannelEmail, ChannelPush}},
+	"ride_vehicle_changed": {Channels: []string{ChannelEmail, ChannelPush}},
+	"ride_reassigned":      {Channels: []string{ChannelEmail, ChannelPush}},
+	"ride_assigned":        {Channels: []string{ChannelEmail, ChannelPush}},
+	"ride_driver_changed":  {Channels: []string{ChannelEmail, ChannelPush}},
+	"vehicle_verified":     {Channels: []string{ChannelEmail}},
+	"vehicle_rejected":     {Channels: []string{ChannelEmail}},
+	"vehicle_expired":      {Channels: []string{ChannelEmail}},
+	"document_expiring":    {Channels: []string{ChannelEmail}},
+	"review_received":      {Channels: []string{ChannelPush}},
+	"report_resolved":      {Channels: []string{ChannelEmail}},
+	"sos":                  {Channels: []string{ChannelEmail, ChannelPush}, Critical: true},
+}
+
+// lookupKind returns the registered Kind for name, or the default
+func lookupKind(name string) Kind {
+	if k, ok := Kinds[name]; ok {
+		return k
+	}
+	return defaultKind
+}
+
+// render fills in the kind's title and body templates
+func (k Kind) render(data map[string]interface{}) (string, string, error) {
+	if k.Title == "" {
+		return "", "", fmt.Errorf("no template registered")
+	}
+	title, err := execute(k.Title, data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := execute(k.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+	return title, body, nil
+}
+
+func execute(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ErrInvalidPreference is returned for preferences naming an unknown kind or channel
+var ErrInvalidPreference = errors.New("invalid notification preference")
+
+// Preference is whether a user receives a kind of notification on a channel
+type Preference struct {
+	Kind     string `json:"kind"`
+	Channel  string `json:"channel"`
+	Enabled  bool   `json:"enabled"`
+	Critical bool   `json:"critical,omitempty"`
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// storedPreferences loads a user's overrides keyed by kind then channel.
+// The kind "*" applies to every kind without its own override.
+func storedPreferences(q querier, userID int) (map[string]map[string]bool, error) {
+	rows, err := q.Query(
+		`SELECT kind, channel, enabled FROM notification_preferences WHERE user_id = $1`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := map[string]map[string]bool{}
+	for rows.Next() {
+		var kind, channel string
+		var enabled bool
+		if err := rows.Scan(&kind, &channel, &enabled); err != nil {
+			return nil, err
+		}
+		if prefs[kind] == nil {
+			prefs[kind] = map[string]bool{}
+		}
+		prefs[kind][channel] = enabled
+	}
+	return prefs, rows.Err()
+}
+
+// effective resolves whether a kind is delivered on a channel
+func effective(prefs map[string]map[string]bool, name string, kind Kind, channel string) bool {
+	if kind.Critical {
+		return true
+	}
+	if enabled, ok := prefs[name][channel]; ok {
+		return enabled
+	}
+	if enabled, ok := prefs["*"][channel]; ok {
+		return enabled
+	}
+	for _, c := range kind.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledChannels returns the external channels a user receives a kind on
+func enabledChannels(q querier, userID int, name string, kind Kind) ([]string, error) {
+	prefs, err := storedPreferences(q, userID)
+	if err != nil {
+		return nil, err
+	}
+	var channels []string
+	for _, channel := range []string{ChannelEmail, ChannelPush} {
+		if effective(prefs, name, kind, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+// Preferences returns a user's effective preference for every kind and channel
+func (s *Service) Preferences(userID int) ([]Preference, error) {
+	prefs, err := storedPreferences(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(Kinds))
+	for name := range Kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []Preference{}
+	for _, name := range names {
+		kind := Kinds[name]
+		for _, channel := range []string{ChannelEmail, ChannelPush} {
+			result = append(result, Preference{
+				Kind:     name,
+				Channel:  channel,
+				Enabled:  effective(prefs, name, kind, channel),
+				Critical: kind.Critical,
+			})
+		}
+	}
+	return result, nil
+}
+
+// SetPreferences stores overrides for a user. Kind "*" sets a channel for
+// every kind that has no override of its own.
+func (s *Service) SetPreferences(userID int, prefs []Preference) error {
+	for _, p := range prefs {
+		if _, ok := Kinds[p.Kind]; !ok && p.Kind != "*" {
+			return fmt.Errorf("%w: unknown kind %q", ErrInvalidPreference, p.Kind)
+		}
+		if p.Channel != ChannelEmail && p.Channel != ChannelPush {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidPreference, p.Channel)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range prefs {
+		_, err := tx.Exec(
+			`INSERT INTO notification_preferences (user_id, kind, channel, enabled)
+			 VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (user_id, kind, channel) DO UPDATE SET enabled = EXCLUDED.enabled`,
+			userID, p.Kind, p.Channel, p.Enabled,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
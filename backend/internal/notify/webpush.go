@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/hkdf"
+)
+
+// pushRecordSize is the aes128gcm record size advertised to push services
+const pushRecordSize = 4096
+
+// WebPushChannel sends deliveries to browser push subscriptions using the Web
+// Push protocol (RFC 8030) with aes128gcm payload encryption (RFC 8291) and
+// VAPID authentication (RFC 8292)
+type WebPushChannel struct {
+	db      *sql.DB
+	key     *ecdsa.PrivateKey
+	subject string
+	client  *http.Client
+}
+
+// NewWebPushChannel creates a push channel from a base64url VAPID private key,
+// as produced by common web-push tooling. subject is a mailto: or https: URL
+// push services can use to contact the sender.
+func NewWebPushChannel(db *sql.DB, privateKey, subject string) (*WebPushChannel, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(trimPadding(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	priv, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+
+	pub := priv.PublicKey().Bytes()
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}
+
+	return &WebPushChannel{
+		db:      db,
+		key:     key,
+		subject: subject,
+		client:  &http.Client{Timeout: sendTimeout},
+	}, nil
+}
+
+// PublicKey returns the application server key browsers subscribe with
+func (w *WebPushChannel) PublicKey() string {
+	pub := make([]byte, 65)
+	pub[0] = 4
+	w.key.X.FillBytes(pub[1:33])
+	w.key.Y.FillBytes(pub[33:])
+	return base64.RawURLEncoding.EncodeToString(pub)
+}
+
+// Send pushes the delivery to the subscription whose endpoint is its address
+func (w *WebPushChannel) Send(ctx context.Context, d Delivery) error {
+	var p256dh, auth string
+	err := w.db.QueryRowContext(ctx,
+		`SELECT p256dh, auth FROM push_subscriptions WHERE endpoint = $1`,
+		d.Address,
+	).Scan(&p256dh, &auth)
+	if err == sql.ErrNoRows {
+		return Permanent(fmt.Errorf("push subscription no longer exists"))
+	}
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]string{"kind": d.Kind, "title": d.Title, "body": d.Body})
+	if err != nil {
+		return Permanent(err)
+	}
+	body, err := encryptPushPayload(payload, p256dh, auth)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	endpoint, err := url.Parse(d.Address)
+	if err != nil {
+		return Permanent(err)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": endpoint.Scheme + "://" + endpoint.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": w.subject,
+	}).SignedString(w.key)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Address, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Authorization", "vapid t="+token+", k="+w.PublicKey())
+	if lookupKind(d.Kind).Critical {
+		req.Header.Set("Urgency", "high")
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// The browser unsubscribed; stop sending to it
+		w.db.Exec(`DELETE FROM push_subscriptions WHERE endpoint = $1`, d.Address)
+		return Permanent(fmt.Errorf("push subscription expired (%s)", resp.Status))
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("push service returned %s", resp.Status)
+	default:
+		return Permanent(fmt.Errorf("push service returned %s", resp.Status))
+	}
+}
+
+// encryptPushPayload encrypts payload for a subscription as a single
+// aes128gcm record (RFC 8291 section 3)
+func encryptPushPayload(payload []byte, p256dh, auth string) ([]byte, error) {
+	uaPublicBytes, err := base64.RawURLEncoding.DecodeString(trimPadding(p256dh))
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription key: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(trimPadding(auth))
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription auth secret: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription key: %w", err)
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+	ecdhSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicBytes...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ecdhSecret, authSecret, keyInfo), ikm); err != nil {
+		return nil, err
+	}
+
+	cek := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single record ends with the 0x02 delimiter and no padding
+	plaintext := append(append([]byte{}, payload...), 2)
+	if len(plaintext)+gcm.Overhead() > pushRecordSize {
+		return nil, fmt.Errorf("push payload too large")
+	}
+
+	header := make([]byte, 0, 16+4+1+len(asPublicBytes))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(asPublicBytes)))
+	header = append(header, asPublicBytes...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// trimPadding accepts base64url keys with or without trailing padding
+func trimPadding(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '=' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
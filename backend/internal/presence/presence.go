@@ -0,0 +1,76 @@
+// Package presence records when users were last seen without writing to the
+// database on every request.
+package presence
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Tracker buffers user activity in memory and flushes it to users.last_seen_at in batches
+type Tracker struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	pending map[int]struct{}
+}
+
+// NewTracker creates a tracker backed by the users table
+func NewTracker(db *sql.DB) *Tracker {
+	return &Tracker{db: db, pending: map[int]struct{}{}}
+}
+
+// Touch marks a user as active now; it is recorded on the next flush
+func (t *Tracker) Touch(userID int) {
+	t.mu.Lock()
+	t.pending[userID] = struct{}{}
+	t.mu.Unlock()
+}
+
+// Start flushes pending activity every interval
+func (t *Tracker) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := t.Flush(); err != nil {
+				log.Printf("Failed to record user presence: %v", err)
+			}
+		}
+	}()
+}
+
+// Flush writes all pending activity in a single update. Users whose write
+// fails are kept so the next flush retries them.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	if len(t.pending) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	batch := t.pending
+	t.pending = map[int]struct{}{}
+	t.mu.Unlock()
+
+	ids := make([]int64, 0, len(batch))
+	for id := range batch {
+		ids = append(ids, int64(id))
+	}
+
+	_, err := t.db.Exec(
+		`UPDATE users SET last_seen_at = CURRENT_TIMESTAMP WHERE id = ANY($1)`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		t.mu.Lock()
+		for id := range batch {
+			t.pending[id] = struct{}{}
+		}
+		t.mu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Allow drops full buckets from memory
+const sweepInterval = time.Minute
+
+// Memory keeps buckets in this process. Each replica limits on its own.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will have refilled completely
+	full time.Time
+}
+
+// NewMemory creates an in-memory limiter
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]*bucket{}, lastSweep: time.Now()}
+}
+
+// Allow implements Limiter
+func (m *Memory) Allow(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+
+	b := m.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: float64(rate.Limit), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(rate.Limit), b.tokens+now.Sub(b.updated).Seconds()*rate.perSecond())
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((float64(rate.Limit) - b.tokens) / rate.perSecond() * float64(time.Second)))
+	if allowed {
+		return true, 0, nil
+	}
+	return false, rate.wait(b.tokens), nil
+}
+
+// Prune implements Limiter
+func (m *Memory) Prune(ctx context.Context) error {
+	m.mu.Lock()
+	m.sweep(time.Now())
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// idleRetention is how long an untouched bucket is kept. Buckets for the
+// rates in use refill well within it, so dropping them changes nothing.
+const idleRetention = 24 * time.Hour
+
+// refilled is a stored bucket's tokens after refilling since its last
+// update, capped at the limit. $2 is the limit and $3 tokens per second.
+const refilled = `LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at)::float8 * $3::float8)`
+
+// Postgres keeps buckets in the rate_limit_buckets table so every replica
+// draws from the same ones. Each Allow is a single upsert.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres creates a limiter backed by the rate_limit_buckets table
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Allow implements Limiter
+func (p *Postgres) Allow(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	var allowed bool
+	var tokens float64
+	err := p.db.QueryRowContext(ctx,
+		`INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		 VALUES ($1, $2::float8 - 1, TRUE, now())
+		 ON CONFLICT (key) DO UPDATE SET
+		     allowed = `+refilled+` >= 1,
+		     tokens = `+refilled+` - CASE WHEN `+refilled+` >= 1 THEN 1 ELSE 0 END,
+		     updated_at = now()
+		 RETURNING allowed, tokens`,
+		key, rate.Limit, rate.perSecond(),
+	).Scan(&allowed, &tokens)
+	if err != nil {
+		return false, 0, err
+	}
+	if allowed {
+		return true, 0, nil
+	}
+	return false, rate.wait(tokens), nil
+}
+
+// Prune implements Limiter
+func (p *Postgres) Prune(ctx context.Context) error {
+	_, err := p.db.ExecContext(ctx,
+		`DELETE FROM rate_limit_buckets WHERE updated_at < CURRENT_TIMESTAMP - $1::int * INTERVAL '1 second'`,
+		int(idleRetention.Seconds()),
+	)
+	return err
+}
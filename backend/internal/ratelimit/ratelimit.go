@@ -0,0 +1,37 @@
+// Package ratelimit throttles requests with token buckets. Buckets live in
+// memory for a single replica, or in Postgres so replicas share them.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Rate allows Limit requests per Period, in bursts of up to Limit
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// perSecond is how many tokens the bucket regains each second
+func (r Rate) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// wait is how long a bucket holding tokens takes to hold one
+func (r Rate) wait(tokens float64) time.Duration {
+	if tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - tokens) / r.perSecond() * float64(time.Second)))
+}
+
+// Limiter takes tokens from buckets identified by key
+type Limiter interface {
+	// Allow takes a token from key's bucket, which refills at rate. When
+	// the bucket is empty it reports how long until the next token.
+	Allow(ctx context.Context, key string, rate Rate) (allowed bool, retryAfter time.Duration, err error)
+	// Prune forgets buckets that have been idle long enough to be full
+	Prune(ctx context.Context) error
+}
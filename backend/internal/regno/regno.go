@@ -0,0 +1,131 @@
+// Package regno parses and normalises Indian vehicle registration numbers.
+package regno
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalid is returned when a registration number matches no known format
+var ErrInvalid = errors.New("invalid registration number")
+
+// ErrUnknownState is returned when the state code is not an Indian state or UT
+var ErrUnknownState = errors.New("unknown state code")
+
+// Plate is a parsed registration number
+type Plate struct {
+	// Number is the canonical compact form, e.g. MH01AB1234 or 22BH1234AA
+	Number string
+	// Formatted is the spaced display form, e.g. MH 01 AB 1234
+	Formatted string
+	// StateCode is the two-letter state or UT code; empty for BH-series plates
+	StateCode string
+	// StateName is the full name of the state or UT; "Bharat series" for BH plates
+	StateName string
+	// RTOCode is the state code plus district number, e.g. MH01; empty for BH plates
+	RTOCode string
+	// BHSeries reports whether this is a Bharat (BH) series plate
+	BHSeries bool
+}
+
+var (
+	standardPattern = regexp.MustCompile(`^([A-Z]{2})([0-9]{1,2})([A-Z]{0,3})([0-9]{1,4})$`)
+	bhPattern       = regexp.MustCompile(`^([0-9]{2})BH([0-9]{4})([A-Z]{1,2})$`)
+	separators      = strings.NewReplacer(" ", "", "-", "", ".", "", "\t", "")
+)
+
+// States maps state and UT codes, including retired ones still seen on older plates
+var States = map[string]string{
+	"AN": "Andaman and Nicobar Islands",
+	"AP": "Andhra Pradesh",
+	"AR": "Arunachal Pradesh",
+	"AS": "Assam",
+	"BR": "Bihar",
+	"CG": "Chhattisgarh",
+	"CH": "Chandigarh",
+	"DD": "Dadra and Nagar Haveli and Daman and Diu",
+	"DL": "Delhi",
+	"DN": "Dadra and Nagar Haveli",
+	"GA": "Goa",
+	"GJ": "Gujarat",
+	"HP": "Himachal Pradesh",
+	"HR": "Haryana",
+	"JH": "Jharkhand",
+	"JK": "Jammu and Kashmir",
+	"KA": "Karnataka",
+	"KL": "Kerala",
+	"LA": "Ladakh",
+	"LD": "Lakshadweep",
+	"MH": "Maharashtra",
+	"ML": "Meghalaya",
+	"MN": "Manipur",
+	"MP": "Madhya Pradesh",
+	"MZ": "Mizoram",
+	"NL": "Nagaland",
+	"OD": "Odisha",
+	"OR": "Odisha",
+	"PB": "Punjab",
+	"PY": "Puducherry",
+	"RJ": "Rajasthan",
+	"SK": "Sikkim",
+	"TG": "Telangana",
+	"TN": "Tamil Nadu",
+	"TR": "Tripura",
+	"TS": "Telangana",
+	"UA": "Uttarakhand",
+	"UK": "Uttarakhand",
+	"UP": "Uttar Pradesh",
+	"WB": "West Bengal",
+}
+
+// Normalize strips separators and upper-cases a registration number without validating it
+func Normalize(s string) string {
+	return strings.ToUpper(separators.Replace(strings.TrimSpace(s)))
+}
+
+// Parse validates a registration number in standard or BH-series format
+func Parse(s string) (Plate, error) {
+	n := Normalize(s)
+
+	if m := bhPattern.FindStringSubmatch(n); m != nil {
+		return Plate{
+			Number:    n,
+			Formatted: fmt.Sprintf("%s BH %s %s", m[1], m[2], m[3]),
+			StateName: "Bharat series",
+			BHSeries:  true,
+		}, nil
+	}
+
+	m := standardPattern.FindStringSubmatch(n)
+	if m == nil {
+		return Plate{}, ErrInvalid
+	}
+
+	state, district, series, digits := m[1], m[2], m[3], m[4]
+	name, ok := States[state]
+	if !ok {
+		return Plate{}, ErrUnknownState
+	}
+	if district == "0" || district == "00" || strings.Trim(digits, "0") == "" {
+		return Plate{}, ErrInvalid
+	}
+
+	district = fmt.Sprintf("%02s", district)
+	digits = fmt.Sprintf("%04s", digits)
+
+	formatted := state + " " + district
+	if series != "" {
+		formatted += " " + series
+	}
+	formatted += " " + digits
+
+	return Plate{
+		Number:    state + district + series + digits,
+		Formatted: formatted,
+		StateCode: state,
+		StateName: name,
+		RTOCode:   state + district,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package regno
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Plate
+	}{
+		{
+			name:  "standard",
+			input: "MH01AB1234",
+			want: Plate{
+				Number: "MH01AB1234", Formatted: "MH 01 AB 1234",
+				StateCode: "MH", StateName: "Maharashtra", RTOCode: "MH01",
+			},
+		},
+		{
+			name:  "separators and lower case",
+			input: " ka-05 mj.42 ",
+			want: Plate{
+				Number: "KA05MJ0042", Formatted: "KA 05 MJ 0042",
+				StateCode: "KA", StateName: "Karnataka", RTOCode: "KA05",
+			},
+		},
+		{
+			name:  "single-digit district is padded",
+			input: "DL 3 C 7",
+			want: Plate{
+				Number: "DL03C0007", Formatted: "DL 03 C 0007",
+				StateCode: "DL", StateName: "Delhi", RTOCode: "DL03",
+			},
+		},
+		{
+			name:  "no series letters",
+			input: "GJ011234",
+			want: Plate{
+				Number: "GJ011234", Formatted: "GJ 01 1234",
+				StateCode: "GJ", StateName: "Gujarat", RTOCode: "GJ01",
+			},
+		},
+		{
+			name:  "three series letters",
+			input: "DL1CAA1234",
+			want: Plate{
+				Number: "DL01CAA1234", Formatted: "DL 01 CAA 1234",
+				StateCode: "DL", StateName: "Delhi", RTOCode: "DL01",
+			},
+		},
+		{
+			name:  "retired state code",
+			input: "OR02X9",
+			want: Plate{
+				Number: "OR02X0009", Formatted: "OR 02 X 0009",
+				StateCode: "OR", StateName: "Odisha", RTOCode: "OR02",
+			},
+		},
+		{
+			name:  "BH series",
+			input: "22 BH 1234 AA",
+			want: Plate{
+				Number: "22BH1234AA", Formatted: "22 BH 1234 AA",
+				StateName: "Bharat series", BHSeries: true,
+			},
+		},
+		{
+			name:  "BH series with one letter",
+			input: "21bh0001c",
+			want: Plate{
+				Number: "21BH0001C", Formatted: "21 BH 0001 C",
+				StateName: "Bharat series", BHSeries: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{"empty", "", ErrInvalid},
+		{"unknown state", "XX01AB1234", ErrUnknownState},
+		{"district zero", "MH00AB1234", ErrInvalid},
+		{"single zero district", "MH0AB1234", ErrInvalid},
+		{"number zero", "MH01AB0000", ErrInvalid},
+		{"three-digit district", "MH123AB1234", ErrInvalid},
+		{"four series letters", "MH01ABCD1234", ErrInvalid},
+		{"five digits", "MH01AB12345", ErrInvalid},
+		{"no number", "MH01AB", ErrInvalid},
+		{"BH series with three letters", "22BH1234ABC", ErrInvalid},
+		{"BH series with short number", "22BH123AA", ErrInvalid},
+		{"BH series with one-digit year", "2BH1234AA", ErrInvalid},
+		{"other characters", "MH01AB12#4", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Parse(%q) error = %v, want %v", tt.input, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"mh 01 ab 1234", "MH01AB1234"},
+		{"MH-01-AB-1234", "MH01AB1234"},
+		{"mh.01.ab.1234", "MH01AB1234"},
+		{"\tMH01\tAB1234 ", "MH01AB1234"},
+		{"not a plate!", "NOTAPLATE!"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.input); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package webhooks
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret    = "whsec_test"
+	testTolerance = 5 * time.Minute
+)
+
+var testBody = []byte(`{"id":"evt_1","type":"ride.created","data":{"ride_id":42}}`)
+
+func TestSignFormat(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	header := Sign(testSecret, at, testBody)
+
+	ts, sig, ok := strings.Cut(header, ",v1=")
+	if !ok || ts != "t=1700000000" {
+		t.Fatalf("Sign() = %q, want t=1700000000,v1=<hex>", header)
+	}
+	if len(sig) != 64 || strings.Trim(sig, "0123456789abcdef") != "" {
+		t.Errorf("Sign() signature = %q, want 64 lower-case hex characters", sig)
+	}
+	if again := Sign(testSecret, at, testBody); again != header {
+		t.Errorf("Sign() is not deterministic: %q then %q", header, again)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	stale := now.Add(-testTolerance - time.Minute)
+	ts := "t=" + strconv.FormatInt(now.Unix(), 10)
+	current := strings.TrimPrefix(Sign(testSecret, now, testBody), ts+",")
+	previous := strings.TrimPrefix(Sign("whsec_previous", now, testBody), ts+",")
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		want   error
+	}{
+		{"round trip", testSecret, Sign(testSecret, now, testBody), testBody, nil},
+		{"empty body", testSecret, Sign(testSecret, now, nil), nil, nil},
+		{"within tolerance", testSecret, Sign(testSecret, now.Add(-testTolerance+time.Minute), testBody), testBody, nil},
+		{"spaces after commas", testSecret, ts + ", " + current, testBody, nil},
+		{"tampered body", testSecret, Sign(testSecret, now, testBody), []byte(`{"id":"evt_1","type":"ride.created","data":{"ride_id":43}}`), ErrInvalidSignature},
+		{"wrong secret", "whsec_other", Sign(testSecret, now, testBody), testBody, ErrInvalidSignature},
+		{"stale timestamp", testSecret, Sign(testSecret, stale, testBody), testBody, ErrInvalidSignature},
+		{"future timestamp", testSecret, Sign(testSecret, now.Add(testTolerance+time.Minute), testBody), testBody, ErrInvalidSignature},
+		{"timestamp swapped", testSecret, "t=" + strconv.FormatInt(now.Unix()-1, 10) + "," + current, testBody, ErrInvalidSignature},
+		{"current signature second", testSecret, ts + "," + previous + "," + current, testBody, nil},
+		{"current signature first", testSecret, ts + "," + current + "," + previous, testBody, nil},
+		{"no matching signature", testSecret, ts + "," + previous + ",v1=00", testBody, ErrInvalidSignature},
+		{"no signature", testSecret, ts, testBody, ErrInvalidSignature},
+		{"no timestamp", testSecret, current, testBody, ErrInvalidSignature},
+		{"bad timestamp", testSecret, "t=soon," + current, testBody, ErrInvalidSignature},
+		{"empty header", testSecret, "", testBody, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, testTolerance)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify(%q) error = %v, want %v", tt.header, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,430 @@
+// Package webhooks delivers domain events to partner endpoints registered by
+// admins. Requests are signed with a per-endpoint secret, retried with
+// exponential backoff and logged attempt by attempt.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cpool.ai/backend/internal/events"
+)
+
+// TestEvent is the type of the ping sent by SendTest
+const TestEvent = "webhook.test"
+
+// Request headers
+const (
+	HeaderSignature = "X-Cpool-Signature"
+	HeaderEvent     = "X-Cpool-Event"
+	HeaderDelivery  = "X-Cpool-Delivery"
+)
+
+const (
+	// maxAttempts is how many times a delivery is tried before it is marked failed
+	maxAttempts = 8
+	// baseBackoff is the delay before the first retry; it doubles on each attempt
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+	// disableAfter is how many failed attempts in a row disable an endpoint
+	disableAfter = 20
+	// claimTimeout releases deliveries claimed by a worker that never finished them
+	claimTimeout = 5 * time.Minute
+	batchSize    = 50
+	sendTimeout  = 10 * time.Second
+	// responseLimit is how much of a response body is kept in the log
+	responseLimit = 1024
+	// retention is how long finished deliveries are kept
+	retention = 30 * 24 * time.Hour
+)
+
+// ErrNotFound is returned for unknown endpoints and deliveries
+var ErrNotFound = errors.New("not found")
+
+// Payload is the JSON body posted to endpoints
+type Payload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Service queues and sends webhook deliveries
+type Service struct {
+	db     *sql.DB
+	client *http.Client
+	wake   chan struct{}
+	// stop and done let Stop end the send loop and wait for the POSTs it
+	// has in flight
+	stop chan struct{}
+	done chan struct{}
+	mu   sync.Mutex
+}
+
+// NewService creates a webhook service backed by the webhook tables
+func NewService(db *sql.DB) *Service {
+	return &Service{
+		db:     db,
+		client: &http.Client{Timeout: sendTimeout},
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+}
+
+// NewSecret generates a signing secret for an endpoint
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Enqueue queues an event for every active endpoint subscribed to its type.
+// It is an events.Handler and is safe to run more than once per event.
+func (s *Service) Enqueue(ctx context.Context, e events.Event) error {
+	body, err := json.Marshal(Payload{
+		ID:        fmt.Sprintf("evt_%d", e.ID),
+		Type:      e.Type,
+		CreatedAt: e.CreatedAt,
+		Data:      e.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+		 SELECT id, $1, $2, $3 FROM webhook_endpoints
+		 WHERE is_active AND ($2 = ANY(event_types) OR '*' = ANY(event_types))
+		 ON CONFLICT (endpoint_id, event_id) DO NOTHING`,
+		e.ID, e.Type, string(body),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		s.poke()
+	}
+	return nil
+}
+
+// SendTest queues a ping to an endpoint, whether or not it is active, and
+// returns the delivery ID
+func (s *Service) SendTest(endpointID int) (int, error) {
+	body, err := json.Marshal(Payload{
+		ID:        "evt_test",
+		Type:      TestEvent,
+		CreatedAt: time.Now().UTC(),
+		Data:      json.RawMessage(fmt.Sprintf(`{"endpoint_id":%d}`, endpointID)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.db.QueryRow(
+		`INSERT INTO webhook_deliveries (endpoint_id, event_type, payload)
+		 SELECT id, $2, $3 FROM webhook_endpoints WHERE id = $1
+		 RETURNING id`,
+		endpointID, TestEvent, string(body),
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.poke()
+	return id, nil
+}
+
+// Redeliver queues a delivery to be sent again straight away with a fresh
+// set of attempts. It runs in the caller's tx; call Wake once that commits.
+func (s *Service) Redeliver(tx *sql.Tx, deliveryID int) error {
+	result, err := tx.Exec(
+		`UPDATE webhook_deliveries
+		 SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $1 AND status <> 'sending'`,
+		deliveryID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Wake asks the delivery worker to look for due deliveries now, for callers
+// that queue them in their own transaction
+func (s *Service) Wake() {
+	s.poke()
+}
+
+// poke wakes the delivery worker without blocking the caller
+func (s *Service) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start sends queued deliveries as they arrive and retries failures every interval
+func (s *Service) Start(interval time.Duration) {
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Process(); err != nil {
+				log.Printf("Failed to deliver webhooks: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.wake:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the send loop once the requests in flight get a response or hit
+// sendTimeout, waiting for it until ctx ends
+func (s *Service) Stop(ctx context.Context) error {
+	if s.done == nil {
+		return nil
+	}
+	close(s.stop)
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Process sends every delivery that is due, batch by batch
+func (s *Service) Process() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		batch, err := s.claim()
+		if err != nil {
+			return err
+		}
+		for _, d := range batch {
+			s.deliver(d)
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+// delivery is a claimed request to one endpoint
+type delivery struct {
+	id         int
+	endpointID int
+	url        string
+	secret     string
+	eventType  string
+	payload    []byte
+	attempt    int
+	test       bool
+}
+
+// claim locks a batch of due deliveries so other replicas skip them.
+// Deliveries to disabled endpoints wait until the endpoint is re-enabled,
+// except test pings.
+func (s *Service) claim() ([]delivery, error) {
+	rows, err := s.db.Query(
+		`UPDATE webhook_deliveries d
+		 SET status = 'sending', attempts = d.attempts + 1, updated_at = CURRENT_TIMESTAMP
+		 FROM webhook_endpoints e
+		 WHERE e.id = d.endpoint_id AND d.id IN (
+		     SELECT wd.id FROM webhook_deliveries wd
+		     JOIN webhook_endpoints we ON we.id = wd.endpoint_id
+		     WHERE (we.is_active OR wd.event_type = $3)
+		       AND ((wd.status = 'pending' AND wd.next_attempt_at <= CURRENT_TIMESTAMP)
+		            OR (wd.status = 'sending' AND wd.updated_at < CURRENT_TIMESTAMP - $1::int * INTERVAL '1 second'))
+		     ORDER BY wd.next_attempt_at
+		     LIMIT $2
+		     FOR UPDATE OF wd SKIP LOCKED)
+		 RETURNING d.id, d.endpoint_id, e.url, e.secret, d.event_type, d.payload, d.attempts`,
+		int(claimTimeout.Seconds()), batchSize, TestEvent,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []delivery
+	for rows.Next() {
+		var d delivery
+		if err := rows.Scan(&d.id, &d.endpointID, &d.url, &d.secret, &d.eventType, &d.payload, &d.attempt); err != nil {
+			return nil, err
+		}
+		d.test = d.eventType == TestEvent
+		batch = append(batch, d)
+	}
+	return batch, rows.Err()
+}
+
+// deliver posts one delivery, logs the attempt and schedules a retry or
+// disables the endpoint as needed
+func (s *Service) deliver(d delivery) {
+	started := time.Now()
+	code, body, err := s.send(d)
+	duration := time.Since(started).Milliseconds()
+
+	var codeArg, bodyArg, errArg interface{}
+	if code != 0 {
+		codeArg = code
+		bodyArg = body
+	}
+	if err != nil {
+		errArg = err.Error()
+	}
+
+	_, logErr := s.db.Exec(
+		`INSERT INTO webhook_attempts (delivery_id, attempt, response_code, response_body, error, duration_ms)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		d.id, d.attempt, codeArg, bodyArg, errArg, duration,
+	)
+	if logErr != nil {
+		log.Printf("Failed to log webhook delivery %d: %v", d.id, logErr)
+	}
+
+	var dbErr error
+	switch {
+	case err == nil:
+		_, dbErr = s.db.Exec(
+			`UPDATE webhook_deliveries
+			 SET status = 'succeeded', response_code = $1, last_error = NULL,
+			     delivered_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $2`,
+			code, d.id,
+		)
+	case d.test || d.attempt >= maxAttempts:
+		_, dbErr = s.db.Exec(
+			`UPDATE webhook_deliveries
+			 SET status = 'failed', response_code = $1, last_error = $2, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $3`,
+			codeArg, err.Error(), d.id,
+		)
+	default:
+		_, dbErr = s.db.Exec(
+			`UPDATE webhook_deliveries
+			 SET status = 'pending', response_code = $1, last_error = $2, updated_at = CURRENT_TIMESTAMP,
+			     next_attempt_at = CURRENT_TIMESTAMP + $3::int * INTERVAL '1 second'
+			 WHERE id = $4`,
+			codeArg, err.Error(), int(backoff(d.attempt).Seconds()), d.id,
+		)
+	}
+	if dbErr != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", d.id, dbErr)
+	}
+
+	// Test pings don't count towards disabling an endpoint
+	if d.test {
+		return
+	}
+	if err == nil {
+		_, dbErr = s.db.Exec(
+			`UPDATE webhook_endpoints SET consecutive_failures = 0, last_success_at = CURRENT_TIMESTAMP
+			 WHERE id = $1`,
+			d.endpointID,
+		)
+	} else {
+		var failures int
+		dbErr = s.db.QueryRow(
+			`UPDATE webhook_endpoints
+			 SET consecutive_failures = consecutive_failures + 1,
+			     last_failure_at = CURRENT_TIMESTAMP,
+			     is_active = is_active AND consecutive_failures + 1 < $2,
+			     disabled_at = CASE WHEN is_active AND consecutive_failures + 1 >= $2
+			                        THEN CURRENT_TIMESTAMP ELSE disabled_at END,
+			     disabled_reason = CASE WHEN is_active AND consecutive_failures + 1 >= $2
+			                            THEN $3 ELSE disabled_reason END
+			 WHERE id = $1
+			 RETURNING consecutive_failures`,
+			d.endpointID, disableAfter, fmt.Sprintf("Disabled after %d failed deliveries in a row", disableAfter),
+		).Scan(&failures)
+		if failures == disableAfter {
+			log.Printf("Webhook endpoint %d disabled after %d consecutive failures", d.endpointID, disableAfter)
+		}
+	}
+	if dbErr != nil {
+		log.Printf("Failed to update webhook endpoint %d: %v", d.endpointID, dbErr)
+	}
+}
+
+// send posts a delivery and returns the response status and the start of its body
+func (s *Service) send(d delivery) (int, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cpool-webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.eventType)
+	req.Header.Set(HeaderDelivery, fmt.Sprint(d.id))
+	req.Header.Set(HeaderSignature, Sign(d.secret, time.Now(), d.payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, responseLimit))
+	// Postgres text can't hold NUL bytes or invalid UTF-8
+	body := strings.ToValidUTF8(strings.ReplaceAll(string(raw), "\x00", ""), "\uFFFD")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, body, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// backoff returns the delay before retrying after the given attempt
+func backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// PruneDeliveries deletes finished deliveries older than the retention period
+func (s *Service) PruneDeliveries(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM webhook_deliveries
+		 WHERE status IN ('succeeded', 'failed')
+		   AND created_at < CURRENT_TIMESTAMP - $1::int * INTERVAL '1 second'`,
+		int(retention.Seconds()),
+	)
+	return err
+}
@@ -1,13 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // city timezones must resolve on hosts without zoneinfo
 
 	"cpool.ai/backend/internal/config"
 	"cpool.ai/backend/internal/db"
+	"cpool.ai/backend/internal/events"
+	"cpool.ai/backend/internal/flags"
 	"cpool.ai/backend/internal/handlers"
+	"cpool.ai/backend/internal/jobs"
+	"cpool.ai/backend/internal/metrics"
 	"cpool.ai/backend/internal/middleware"
+	"cpool.ai/backend/internal/notify"
+	"cpool.ai/backend/internal/presence"
+	"cpool.ai/backend/internal/ratelimit"
+	"cpool.ai/backend/internal/storage"
+	"cpool.ai/backend/internal/webhooks"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,12 +34,21 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Log structured lines; the standard logger goes through the same handler
+	setupLogging(cfg.LogLevel, cfg.LogFormat)
+
+	// Refuse to start with settings unsafe for the environment
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	// Initialize database
 	database, err := db.Initialize(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 	defer database.Close()
+	metrics.RegisterDBStats(database)
 
 	// Run migrations
 	if err := db.RunMigrations(database); err != nil {
@@ -33,108 +60,184 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Initialize router
-	router := gin.Default()
+	// Initialize router, tagging each request with an ID for the access log,
+	// metrics and error responses
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.Use(gin.Recovery(), middleware.RequestID(), middleware.RequestLogger(), middleware.Metrics())
+	router.GET("/metrics", gin.WrapH(metrics.Handler(cfg.MetricsToken)))
+
+	// Allow the configured browser origins and harden every response
+	if len(cfg.AllowedOrigins) == 0 {
+		log.Println("No CORS origins allowed; set CORS_ALLOWED_ORIGINS for browser clients")
+	} else {
+		log.Printf("Running as %s, allowing browser origins %s", cfg.Environment, strings.Join(cfg.AllowedOrigins, ", "))
+	}
+	router.Use(middleware.SecurityHeaders(cfg.Environment != config.EnvDevelopment), middleware.CORS(cfg.AllowedOrigins))
 
-	// CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+	// Initialize document storage
+	store, err := storage.NewLocalStore(cfg.UploadDir)
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+
+	// Load feature flags and follow changes made by other replicas
+	flagService := flags.NewService(database)
+	if err := flagService.Load(); err != nil {
+		log.Fatal("Failed to load feature flags:", err)
+	}
+	if err := flagService.Listen(cfg.DatabaseURL); err != nil {
+		log.Println("Feature flag change notifications unavailable:", err)
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	// Deliver notifications by email and web push, logging any channel that isn't configured
+	notifier := notify.NewService(database)
+	if cfg.SMTPHost != "" {
+		notifier.Register(notify.ChannelEmail, notify.SMTPChannel{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	} else {
+		notifier.Register(notify.ChannelEmail, notify.LogChannel{Name: notify.ChannelEmail})
+	}
+	if cfg.VAPIDPrivateKey != "" {
+		push, err := notify.NewWebPushChannel(database, cfg.VAPIDPrivateKey, cfg.VAPIDSubject)
+		if err != nil {
+			log.Fatal("Failed to initialize web push:", err)
 		}
+		notifier.Register(notify.ChannelPush, push)
+	} else {
+		notifier.Register(notify.ChannelPush, notify.LogChannel{Name: notify.ChannelPush})
+	}
+	notifier.Start(time.Minute)
+
+	// Dispatch domain events recorded alongside state changes to notifications,
+	// credits and analytics
+	bus := events.NewBus(database)
+	if err := bus.Listen(cfg.DatabaseURL); err != nil {
+		log.Println("Outbox event notifications unavailable:", err)
+	}
 
-		c.Next()
-	})
+	// Deliver subscribed events to partner webhook endpoints
+	hooks := webhooks.NewService(database)
+	hooks.Start(30 * time.Second)
+
+	// Throttle abuse-prone routes, sharing limits between replicas when
+	// configured to
+	var limiter ratelimit.Limiter = ratelimit.NewMemory()
+	if cfg.RateLimitStore == config.RateLimitPostgres {
+		limiter = ratelimit.NewPostgres(database)
+	}
 
 	// Initialize handlers
-	h := handlers.New(database, cfg)
-
-	// Public routes
-	api := router.Group("/api")
-	{
-		api.GET("/health", handlers.HealthCheck)
-		api.POST("/auth/register", h.Register)
-		api.POST("/auth/login", h.Login)
-	}
-
-	// Protected routes
-	protected := api.Group("")
-	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
-	{
-		// Auth
-		protected.GET("/auth/profile", h.GetProfile)
-
-		// Stats
-		protected.GET("/stats", h.GetStats)
-
-		// Cities
-		protected.GET("/cities", h.GetCities)
-		protected.PUT("/cities/:id/status", h.UpdateCityStatus) // Admin only
-
-		// Corridors
-		protected.GET("/corridors", h.GetCorridors)
-		protected.GET("/corridors/:id", h.GetCorridor)
-		protected.POST("/corridors", h.CreateCorridor)      // Admin only
-		protected.PUT("/corridors/:id", h.UpdateCorridor)    // Admin only
-		protected.DELETE("/corridors/:id", h.DeleteCorridor) // Admin only
-
-		// User corridors
-		protected.GET("/user/corridors", h.GetUserCorridors)
-		protected.POST("/user/corridors", h.AssignCorridor) // Admin only
-
-		// Vehicles
-		protected.GET("/vehicles", h.GetVehicles)
-		protected.GET("/vehicles/:id", h.GetVehicle)
-		protected.POST("/vehicles", h.CreateVehicle)
-		protected.PUT("/vehicles/:id", h.UpdateVehicle)
-		protected.DELETE("/vehicles/:id", h.DeleteVehicle)
-
-		// Rides
-		protected.GET("/rides", h.GetRides)
-		protected.GET("/rides/:id", h.GetRide)
-		protected.POST("/rides", h.CreateRide)
-		protected.PUT("/rides/:id", h.UpdateRide)
-		protected.DELETE("/rides/:id", h.CancelRide)
-
-		// Ride requests
-		protected.GET("/rides/:id/requests", h.GetRideRequests)
-		protected.POST("/rides/:id/requests", h.CreateRideRequest)
-		protected.PUT("/rides/:id/requests/:requestId", h.UpdateRideRequest)
-
-		// Messages
-		protected.GET("/rides/:id/messages", h.GetMessages)
-		protected.POST("/rides/:id/messages", h.CreateMessage)
-
-		// Payments
-		protected.GET("/rides/:id/payments", h.GetPayments)
-		protected.POST("/rides/:id/payments", h.CreatePayment)
-		protected.PUT("/rides/:id/payments/:userId", h.UpdatePaymentStatus)
-
-		// Admin routes
-		admin := protected.Group("/admin")
-		admin.Use(middleware.AdminMiddleware())
-		{
-			admin.GET("/users", h.GetAllUsers)
-			admin.PUT("/users/:id", h.UpdateUser)
-			admin.GET("/analytics", h.GetAnalytics)
-			admin.PUT("/features/:name", h.ToggleFeature)
-		}
+	h := handlers.New(database, cfg, store, flagService, notifier, bus, hooks, limiter)
+	h.RegisterSubscribers()
+	bus.Start(30 * time.Second)
+
+	// Run scheduled housekeeping: city launches, document expiry, analytics
+	// rollups, stale rides and departure reminders
+	runner := jobs.NewRunner(database)
+	if err := h.RegisterJobs(runner); err != nil {
+		log.Fatal("Failed to register jobs:", err)
+	}
+	runner.Start()
+
+	// Record user activity in batches for presence stats
+	tracker := presence.NewTracker(database)
+	tracker.Start(30 * time.Second)
+
+	// Mount API routes. Errors handlers return are written in one format,
+	// inside the contract check when it is on so error bodies are checked too.
+	if cfg.ContractCheck {
+		router.Use(middleware.ContractMiddleware(handlers.Spec()))
 	}
+	router.Use(middleware.ErrorMiddleware())
+	h.RegisterRoutes(router, tracker)
+
+	// Liveness and readiness probes, outside /api like /metrics
+	probes := &handlers.Probes{DB: database, Runner: runner}
+	router.GET("/healthz", probes.Healthz)
+	router.GET("/readyz", probes.Readyz)
 
 	// Start server
 	port := cfg.Port
 	if port == "" {
 		port = "8080"
 	}
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
 		log.Fatal("Failed to start server:", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Fail readiness first so load balancers stop sending traffic, then
+	// drain in-flight requests and background work within the timeout
+	log.Println("Shutting down")
+	probes.Drain()
+	time.Sleep(cfg.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("HTTP server did not drain:", err)
+	}
+	if err := runner.Stop(shutdownCtx); err != nil {
+		log.Println("Jobs did not finish:", err)
+	}
+	if err := bus.Stop(shutdownCtx); err != nil {
+		log.Println("Event dispatch did not finish:", err)
+	}
+	if err := notifier.Stop(shutdownCtx); err != nil {
+		log.Println("Notification delivery did not finish:", err)
+	}
+	if err := hooks.Stop(shutdownCtx); err != nil {
+		log.Println("Webhook delivery did not finish:", err)
+	}
+	if err := tracker.Flush(); err != nil {
+		log.Println("Failed to record user presence:", err)
+	}
+	log.Println("Server stopped")
+}
+
+// setupLogging makes slog the default logger, writing JSON unless format is
+// text
+func setupLogging(level, format string) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, opts)
+	if format == "text" {
+		handler = slog.NewTextHandler(os.Stdout, opts)
 	}
+	slog.SetDefault(slog.New(handler))
 }
 
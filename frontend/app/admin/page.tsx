@@ -53,11 +53,11 @@ export default function AdminPage() {
     try {
       const [citiesData, corridorsData, analyticsData] = await Promise.all([
         api.get('/cities'),
-        api.get('/corridors'),
+        api.getAll<Corridor>('/corridors?limit=200', 'corridors'),
         api.get('/admin/analytics'),
       ])
       setCities((citiesData as unknown) as City[])
-      setCorridors((corridorsData as unknown) as Corridor[])
+      setCorridors(corridorsData)
       setAnalytics(analyticsData as any)
     } catch (error) {
       toast.error('Failed to load data')
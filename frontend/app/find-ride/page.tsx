@@ -47,8 +47,8 @@ export default function FindRidePage() {
       if (filters.corridor_id) params.append('corridor_id', filters.corridor_id)
       if (filters.date) params.append('date', filters.date)
 
-      const data = await api.get(`/rides?${params.toString()}`)
-      setRides(data as unknown as Ride[])
+      const data = await api.getAll<Ride>(`/rides?${params.toString()}`, 'rides')
+      setRides(data)
     } catch (error) {
       toast.error('Failed to load rides')
     } finally {
@@ -98,11 +98,11 @@ export default function RideDetailsPage() {
     try {
       const lastId = messages.length > 0 ? messages[messages.length - 1].id : undefined
       const params = lastId ? `?last_id=${lastId}` : ''
-      const data = await api.get(`/rides/${rideId}/messages${params}`)
+      const data = await api.getAll<Message>(`/rides/${rideId}/messages${params}`, 'messages')
       if (lastId) {
-        setMessages((prev) => [...prev, ...(data as unknown as Message[])])
+        setMessages((prev) => [...prev, ...data])
       } else {
-        setMessages(data as unknown as Message[])
+        setMessages(data)
       }
     } catch (error) {
       console.error('Failed to load messages')
@@ -111,8 +111,8 @@ export default function RideDetailsPage() {
 
   const fetchPayments = async () => {
     try {
-      const data = await api.get(`/rides/${rideId}/payments`)
-      setPayments(data as unknown as Payment[])
+      const data = await api.getAll<Payment>(`/rides/${rideId}/payments`, 'payments')
+      setPayments(data)
     } catch (error) {
       console.error('Failed to load payments')
     }
@@ -0,0 +1,1601 @@
+// Code generated by go run ./cmd/openapi. DO NOT EDIT.
+// Types and functions for every operation in /api/openapi.json.
+
+import type { AxiosInstance } from 'axios'
+
+export interface AdminCancelRideRequest {
+  reason: string
+}
+
+export interface AdminCancelRideResponse {
+  message: string
+  riders_notified: number
+}
+
+export interface AdminRidesPage {
+  next_cursor: string | null
+  rides: Ride[]
+}
+
+export interface AnalyticsResponse {
+  active_corridors: number
+  active_rides: number
+  completed_rides: number
+  total_credits: number
+  total_revenue: number
+  total_rides: number
+  total_users: number
+}
+
+export interface AssignCorridorRequest {
+  corridor_id: number
+  user_id: number
+}
+
+export interface AuditEntry {
+  action: string
+  actor_email: string | null
+  actor_id: number | null
+  after: unknown
+  before: unknown
+  created_at: string
+  id: number
+  ip_address: string
+  target_id: string
+  target_type: string
+}
+
+export interface AuditLogPage {
+  entries: AuditEntry[]
+  next_cursor: string | null
+}
+
+export interface BlockUserRequest {
+  user_id: number
+}
+
+export interface BlockedUser {
+  created_at: string
+  name: string
+  user_id: number
+}
+
+export interface City {
+  created_at: string
+  currency: string
+  id: number
+  launch_at: string | null
+  locale: string
+  name: string
+  status: string
+  timezone: string
+  updated_at: string
+}
+
+export interface CityStatusResponse {
+  launch_at?: string | null
+  message: string
+  waitlist_notified?: number
+}
+
+export interface CloseStaleRidesRequest {
+  ride_ids?: number[]
+}
+
+export interface CloseStaleRidesResponse {
+  cancelled: number[]
+  completed: number[]
+}
+
+export interface Corridor {
+  city_id: number
+  city_name?: string
+  created_at: string
+  id: number
+  is_active: boolean
+  location_from: string
+  location_to: string
+  map_enabled: boolean
+  name: string
+  pickup_points: string | null
+  terms_conditions: string | null
+  updated_at: string
+  women_only_allowed: boolean
+}
+
+export interface CorridorsPage {
+  corridors: Corridor[]
+  next_cursor: string | null
+}
+
+export interface CreateCorridorRequest {
+  city_id: number
+  is_active?: boolean
+  location_from: string
+  location_to: string
+  map_enabled?: boolean
+  name: string
+  pickup_points?: string
+  terms_conditions?: string
+  women_only_allowed?: boolean
+}
+
+export interface CreateEmergencyContactRequest {
+  email?: string
+  name: string
+  phone: string
+  relationship?: string
+}
+
+export interface CreateFeatureRequest {
+  description?: string
+  enabled?: boolean
+  name: string
+  rollout_percentage?: number | null
+  targeting?: Targeting | null
+}
+
+export interface CreateMessageRequest {
+  message: string
+}
+
+export interface CreatePaymentRequest {
+  amount: number
+  rider_id: number
+}
+
+export interface CreatePushSubscriptionRequest {
+  endpoint: string
+  keys?: {
+    auth: string
+    p256dh: string
+  }
+}
+
+export interface CreateReportRequest {
+  category: 'harassment' | 'unsafe_driving' | 'inappropriate_content' | 'spam' | 'fraud' | 'no_show' | 'other'
+  description?: string
+  target_id: number
+  target_type: 'user' | 'message' | 'ride'
+}
+
+export interface CreateReviewRequest {
+  comment?: string
+  rating: number
+  reviewee_id: number
+  tags?: string[]
+}
+
+export interface CreateRideRequest {
+  available_seats: number
+  corridor_id: number
+  drop_point: string
+  pickup_point: string
+  price_per_seat: number
+  ride_date: string
+  ride_time: string
+  route_description?: string
+  vehicle_id: number
+  visibility?: 'everyone' | 'women_only'
+}
+
+export interface CreateRideRequestBody {
+  comment?: string
+  seats_requested: number
+}
+
+export interface CreateShareLinkRequest {
+  hours?: number
+}
+
+export interface CreateVehicleRequest {
+  color?: string
+  default_available_seats: number
+  make: string
+  model: string
+  total_seats: number
+  vehicle_number: string
+  vehicle_type: 'car' | 'bike'
+}
+
+export interface CreateWebhookRequest {
+  description?: string
+  event_types: string[]
+  url: string
+}
+
+export interface CreatedResponse {
+  id: number
+  message: string
+}
+
+export interface DeletePushSubscriptionRequest {
+  endpoint: string
+}
+
+export interface DemandReport {
+  city_id: number
+  city_name: string
+  min_signups: number
+  routes: DemandRoute[]
+  status: string
+  total_signups: number
+}
+
+export interface DemandRoute {
+  has_corridor: boolean
+  location_from: string
+  location_to: string
+  peak_departure_hour: number | null
+  peak_return_hour: number | null
+  signups: number
+  suggested: boolean
+  suggested_name?: string
+}
+
+export interface EmergencyContact {
+  created_at: string
+  email: string | null
+  id: number
+  name: string
+  phone: string
+  relationship: string | null
+}
+
+// ErrorResponse is the body of every 4xx and 5xx response
+export interface ErrorResponse {
+  booked_seats?: number
+  code: 'bad_request' | 'invalid_json' | 'validation_failed' | 'unauthorized' | 'forbidden' | 'not_found' | 'conflict' | 'payload_too_large' | 'rate_limited' | 'internal_error' | 'service_unavailable' | 'invalid_credentials' | 'email_taken' | 'vehicle_number_taken' | 'vehicle_unverified' | 'vehicle_in_use' | 'seats_booked' | 'insufficient_seats' | 'duplicate_request' | 'duplicate_review' | 'duplicate_report' | 'feature_exists' | 'ride_closed' | 'ride_not_upcoming' | 'driver_is_rider' | 'women_only' | 'push_unavailable' | 'account_locked'
+  details?: FieldError[]
+  error: string
+  request_id?: string
+  retry_after?: number
+  upcoming_rides?: number
+}
+
+export interface FeatureFlag {
+  created_at: string
+  description: string | null
+  enabled: boolean
+  id: number
+  name: string
+  rollout_percentage: number
+  targeting: unknown
+  updated_at: string
+}
+
+export interface FieldError {
+  field: string
+  message: string
+  rule: string
+}
+
+export interface HealthResponse {
+  message: string
+  status: string
+}
+
+export interface JobRun {
+  attempt: number
+  duration_ms: number | null
+  error: string | null
+  finished_at: string | null
+  id: number
+  job_name: string
+  runner: string
+  started_at: string
+  status: string
+}
+
+export interface JobRunsPage {
+  next_cursor: string | null
+  runs: JobRun[]
+}
+
+export interface JobScheduledResponse {
+  message: string
+  next_run_at: string
+}
+
+export interface JoinWaitlistRequest {
+  commute_return?: string
+  commute_start?: string
+  preferred_from: string
+  preferred_to: string
+}
+
+export interface Location {
+  accuracy_m?: number | null
+  latitude: number
+  longitude: number
+  recorded_at: string
+}
+
+export interface LoginRequest {
+  email: string
+  password: string
+}
+
+export interface LoginResponse {
+  token: string
+  user: User
+}
+
+export interface MarkAllReadResponse {
+  message: string
+  updated: number
+}
+
+export interface Message {
+  created_at: string
+  id: number
+  message: string
+  ride_id: number
+  user_id: number
+  user_name?: string
+}
+
+export interface MessageResponse {
+  message: string
+}
+
+export interface MessagesPage {
+  messages: Message[]
+  next_cursor: string | null
+}
+
+export interface MetricsBucket {
+  acceptance_rate: number
+  avg_response_minutes: number | null
+  bucket: string
+  city_id: number
+  city_name: string
+  corridor_id?: number | null
+  corridor_name?: string | null
+  credits_issued: number
+  fill_rate: number
+  requests_accepted: number
+  requests_made: number
+  revenue_settled: number
+  rides_cancelled: number
+  rides_completed: number
+  rides_offered: number
+  seats_filled: number
+  seats_offered: number
+}
+
+export interface ModerateReviewRequest {
+  note?: string
+  status: 'visible' | 'hidden'
+}
+
+export interface Notification {
+  body: string
+  created_at: string
+  id: number
+  read_at: string | null
+  title: string
+  type: string
+  user_id: number
+}
+
+export interface NotificationPreferencesResponse {
+  preferences: Preference[]
+  push_public_key: string
+}
+
+export interface NotificationsPage {
+  next_cursor: string | null
+  notifications: Notification[]
+}
+
+export interface OnlineCount {
+  city_id?: number | null
+  id: number
+  name: string
+  users_online: number
+}
+
+export interface Payment {
+  admin_override: boolean
+  amount: number
+  created_at: string
+  giver_name?: string
+  giver_status: string
+  id: number
+  ride_giver_id: number
+  ride_id: number
+  rider_id: number
+  rider_name?: string
+  rider_status: string
+  updated_at: string
+}
+
+export interface PaymentsPage {
+  next_cursor: string | null
+  payments: Payment[]
+}
+
+export interface Preference {
+  channel: string
+  critical?: boolean
+  enabled: boolean
+  kind: string
+}
+
+export interface PreferenceInput {
+  channel?: string
+  critical?: boolean
+  enabled?: boolean
+  kind?: string
+}
+
+export interface RaiseSOSRequest {
+  accuracy_m?: number | null
+  latitude?: number | null
+  longitude?: number | null
+  message?: string
+}
+
+export interface RaiseSOSResponse {
+  contacts_notified: number
+  id: number
+  message: string
+}
+
+export interface RatingSummary {
+  average: number | null
+  count: number
+  next_cursor: string | null
+  reviews: Review[]
+  stars: Record<string, number>
+  tags: Record<string, number>
+  user_id: number
+}
+
+export interface ReassignRideRequest {
+  user_id: number
+  vehicle_id: number
+}
+
+export interface RegisterRequest {
+  city?: string
+  email: string
+  gender?: 'female' | 'male' | 'non_binary' | 'undisclosed'
+  name: string
+  password: string
+  phone?: string
+}
+
+export interface RegisterResponse {
+  token: string
+  user: RegisteredUser
+}
+
+export interface RegisteredUser {
+  email: string
+  id: number
+  name: string
+  role: string
+}
+
+export interface Report {
+  category: string
+  created_at: string
+  description: string | null
+  evidence: string | null
+  id: number
+  reported_user_id: number | null
+  reported_user_name?: string | null
+  reported_user_open_reports: number
+  reporter_id: number
+  reporter_name?: string
+  resolution_note: string | null
+  resolved_at: string | null
+  status: string
+  target_id: number
+  target_type: string
+}
+
+export interface ReportsPage {
+  next_cursor: string | null
+  reports: Report[]
+}
+
+export interface ResolveSOSAlertRequest {
+  note: string
+}
+
+export interface Review {
+  comment: string | null
+  created_at: string
+  id: number
+  moderated_at?: string | null
+  moderation_note?: string | null
+  rating: number
+  reviewee_id: number
+  reviewee_name?: string
+  reviewer_id: number
+  reviewer_name?: string
+  ride_id: number
+  status?: string
+  tags: string[]
+}
+
+export interface ReviewCandidate {
+  name: string
+  user_id: number
+}
+
+export interface ReviewVehicleRequest {
+  note?: string
+  status: 'approved' | 'rejected'
+}
+
+export interface ReviewsPage {
+  next_cursor: string | null
+  reviews: Review[]
+}
+
+export interface RevokeShareLinksResponse {
+  message: string
+  revoked: number
+}
+
+export interface Ride {
+  available_seats: number
+  booked_seats?: number | null
+  cancel_reason?: string | null
+  cancelled_at?: string | null
+  corridor_id: number
+  corridor_name?: string
+  created_at: string
+  currency?: string
+  driver_rating: number | null
+  driver_review_count: number
+  drop_point: string
+  id: number
+  pickup_point: string
+  price_per_seat: number
+  ride_date: string
+  ride_time: string
+  route_description: string | null
+  status: string
+  timezone?: string
+  total_seats: number
+  updated_at: string
+  user_id: number
+  user_name?: string
+  vehicle_id: number | null
+  vehicle_info?: Vehicle | null
+  visibility: string
+}
+
+export interface RideEvent {
+  actor_id: number | null
+  actor_name?: string
+  at: string
+  detail?: string
+  type: string
+}
+
+export interface RideRequest {
+  comment: string | null
+  created_at: string
+  id: number
+  ride_id: number
+  seats_requested: number
+  status: string
+  updated_at: string
+  user_id: number
+  user_name?: string
+}
+
+export interface RideReviewsResponse {
+  reviews: Review[]
+  to_review: ReviewCandidate[]
+}
+
+export interface RideTimeline {
+  events: RideEvent[]
+  messages: Message[]
+  payments: Payment[]
+  requests: RideRequest[]
+  ride: Ride
+}
+
+export interface RidesPage {
+  next_cursor: string | null
+  rides: Ride[]
+}
+
+export interface SOSAlert {
+  accuracy_m: number | null
+  contacts_notified: number
+  created_at: string
+  id: number
+  latitude: number | null
+  longitude: number | null
+  message: string | null
+  resolution_note: string | null
+  resolved_at: string | null
+  ride_id: number | null
+  status: string
+  user_id: number
+  user_name?: string
+  user_phone?: string | null
+}
+
+export interface ScheduledJob {
+  attempt: number
+  last_error: string | null
+  last_run_at: string | null
+  last_status: string | null
+  locked_by: string | null
+  name: string
+  next_run_at: string
+  running: boolean
+  schedule: string
+}
+
+export interface ShareLinkResponse {
+  expires_at: string
+  path: string
+  token: string
+  url: string
+}
+
+export interface SharedRide {
+  corridor_name: string
+  driver_name: string
+  driver_rating: number | null
+  drop_point: string
+  expires_at: string
+  last_location?: Location | null
+  phase: string
+  pickup_point: string
+  ride_date: string
+  ride_id: number
+  ride_time: string
+  shared_by: string
+  sos_active: boolean
+  status: string
+  timezone: string
+  vehicle?: Vehicle | null
+}
+
+export interface SosAlertsPage {
+  alerts: SOSAlert[]
+  next_cursor: string | null
+}
+
+export interface StatsResponse {
+  online_by_city: OnlineCount[]
+  online_by_corridor: OnlineCount[]
+  online_window_minutes: number
+  rides_taken_today: number
+  rides_today: number
+  users_online: number
+}
+
+export interface SwapVehicleRequest {
+  ride_ids?: number[]
+  vehicle_id: number
+}
+
+export interface SwapVehicleResponse {
+  message: string
+  rides_updated: number
+}
+
+export interface Targeting {
+  city_ids?: number[]
+  corridor_ids?: number[]
+  orgs?: string[]
+  roles?: string[]
+  user_ids?: number[]
+}
+
+export interface TimeSeriesResponse {
+  buckets: MetricsBucket[]
+  from: string
+  granularity: string
+  group_by: string
+  refreshed_at?: string | null
+  to: string
+}
+
+export interface ToggleFeatureRequest {
+  description?: string | null
+  enabled?: boolean | null
+  rollout_percentage?: number | null
+  targeting?: Targeting | null
+}
+
+export interface UnreadCountResponse {
+  unread: number
+}
+
+export interface UpdateCitySettingsRequest {
+  currency?: string | null
+  locale?: string | null
+  timezone?: string | null
+}
+
+export interface UpdateCityStatusRequest {
+  launch_at?: string | null
+  status: 'active' | 'locked'
+}
+
+export interface UpdateCorridorRequest {
+  is_active?: boolean | null
+  location_from?: string | null
+  location_to?: string | null
+  map_enabled?: boolean | null
+  name?: string | null
+  pickup_points?: string | null
+  terms_conditions?: string | null
+  women_only_allowed?: boolean | null
+}
+
+export interface UpdateNotificationPreferencesRequest {
+  preferences: PreferenceInput[]
+}
+
+export interface UpdatePaymentStatusRequest {
+  giver_status?: 'pending' | 'received' | null
+  rider_status?: 'pending' | 'done' | null
+}
+
+export interface UpdateProfileRequest {
+  city?: string | null
+  gender?: string | null
+  name?: string | null
+  phone?: string | null
+  upi_id?: string | null
+}
+
+export interface UpdateReportRequest {
+  note?: string
+  status: 'open' | 'reviewing' | 'actioned' | 'dismissed'
+}
+
+export interface UpdateRideRequest {
+  available_seats?: number | null
+  drop_point?: string | null
+  pickup_point?: string | null
+  price_per_seat?: number | null
+  ride_time?: string | null
+  route_description?: string | null
+  status?: 'open' | 'full' | 'completed' | null
+  visibility?: 'everyone' | 'women_only' | null
+}
+
+export interface UpdateRideRequestBody {
+  status: 'accepted' | 'rejected'
+}
+
+export interface UpdateUserRequest {
+  carbon_credits?: number | null
+  city?: string | null
+  name?: string | null
+  phone?: string | null
+  role?: string | null
+  upi_id?: string | null
+}
+
+export interface UpdateVehicleRequest {
+  color?: string | null
+  default_available_seats?: number | null
+  make?: string | null
+  model?: string | null
+  total_seats?: number | null
+  vehicle_number?: string | null
+}
+
+export interface UpdateWebhookRequest {
+  description?: string | null
+  event_types?: string[]
+  is_active?: boolean | null
+  url?: string | null
+}
+
+export interface User {
+  carbon_credits: number
+  city: string | null
+  created_at: string
+  email: string
+  emergency_contacts?: EmergencyContact[]
+  gender: string | null
+  id: number
+  name: string
+  phone: string | null
+  rating?: number | null
+  review_count?: number | null
+  role: string
+  updated_at: string
+  upi_id: string | null
+}
+
+export interface UsersPage {
+  next_cursor: string | null
+  users: User[]
+}
+
+export interface Vehicle {
+  archived_at: string | null
+  color: string | null
+  created_at: string
+  default_available_seats: number
+  id: number
+  make: string
+  model: string
+  rto_code: string | null
+  state_code: string | null
+  total_seats: number
+  updated_at: string
+  user_id: number
+  vehicle_number: string
+  vehicle_type: string
+  verification_note: string | null
+  verification_status: string
+  verified_at: string | null
+}
+
+export interface VehicleCreatedResponse {
+  id: number
+  message: string
+  vehicle_number: string
+}
+
+export interface VehicleDocument {
+  content_type: string
+  created_at: string
+  doc_type: string
+  expires_on: string | null
+  file_name: string
+  id: number
+  review_note: string | null
+  reviewed_at: string | null
+  size_bytes: number
+  status: string
+  updated_at: string
+  vehicle_id: number
+}
+
+export interface VehicleReview {
+  documents: VehicleDocument[]
+  owner_name: string
+  vehicle: Vehicle
+}
+
+export interface WaitlistEntry {
+  city_id: number
+  commute_return: string | null
+  commute_start: string | null
+  created_at: string
+  id: number
+  notified_at: string | null
+  preferred_from: string
+  preferred_to: string
+  updated_at: string
+  user_email?: string
+  user_id: number
+  user_name?: string
+}
+
+export interface WaitlistPage {
+  entries: WaitlistEntry[]
+  next_cursor: string | null
+}
+
+export interface WebhookAttempt {
+  attempt: number
+  created_at: string
+  duration_ms: number
+  error: string | null
+  response_body: string | null
+  response_code: number | null
+}
+
+export interface WebhookDeliveriesPage {
+  deliveries: WebhookDelivery[]
+  next_cursor: string | null
+}
+
+export interface WebhookDelivery {
+  attempt_log?: WebhookAttempt[]
+  attempts: number
+  created_at: string
+  delivered_at: string | null
+  endpoint_id: number
+  event_id: number | null
+  event_type: string
+  id: number
+  last_error: string | null
+  next_attempt_at: string
+  payload?: unknown
+  response_code: number | null
+  status: string
+}
+
+export interface WebhookEndpoint {
+  consecutive_failures: number
+  created_at: string
+  created_by: number | null
+  description: string | null
+  disabled_at: string | null
+  disabled_reason: string | null
+  event_types: string[]
+  id: number
+  is_active: boolean
+  last_failure_at: string | null
+  last_success_at: string | null
+  secret?: string
+  updated_at: string
+  url: string
+}
+
+export interface WebhookEventTypesResponse {
+  event_types: string[]
+}
+
+export interface WebhookSecretResponse {
+  secret: string
+}
+
+export interface WebhookTestResponse {
+  delivery_id: number
+  message: string
+}
+
+export interface GetAnalyticsTimeSeriesParams {
+  granularity?: 'day' | 'week' | 'month'
+  group_by?: 'city' | 'corridor'
+  from?: string
+  to?: string
+  city_id?: number
+  corridor_id?: number
+}
+
+export interface GetAuditLogParams {
+  actor_id?: number
+  action?: string
+  target_type?: string
+  target_id?: string
+  from?: string
+  to?: string
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -created_at by default */
+  sort?: '-created_at' | 'created_at'
+}
+
+export interface ExportAuditLogParams {
+  actor_id?: number
+  action?: string
+  target_type?: string
+  target_id?: string
+  from?: string
+  to?: string
+}
+
+export interface GetCityDemandParams {
+  /** Signups needed to suggest a corridor */
+  min_signups?: number
+}
+
+export interface GetWaitlistParams {
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; created_at by default */
+  sort?: '-created_at' | 'created_at'
+}
+
+export interface GetJobRunsParams {
+  job?: string
+  status?: 'running' | 'succeeded' | 'failed'
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -started_at by default */
+  sort?: '-started_at' | 'started_at'
+}
+
+export interface GetReportsParams {
+  status?: string
+  category?: string
+  target_type?: string
+  reported_user_id?: number
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; triage by default */
+  sort?: '-created_at' | '-triage' | 'created_at' | 'triage'
+}
+
+export interface AdminGetReviewsParams {
+  status?: string
+  reviewer_id?: number
+  reviewee_id?: number
+  ride_id?: number
+  max_rating?: number
+  has_comment?: boolean
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -created_at by default */
+  sort?: '-created_at' | '-rating' | 'created_at' | 'rating'
+}
+
+export interface AdminGetRidesParams {
+  status?: string
+  city_id?: number
+  corridor_id?: number
+  /** Driver */
+  user_id?: number
+  vehicle_id?: number
+  /** Rides this user requested */
+  rider_id?: number
+  /** Driver, corridor or pickup/drop point */
+  q?: string
+  from?: string
+  to?: string
+  /** Only past rides still open */
+  stale?: boolean
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -departure by default */
+  sort?: '-created_at' | '-departure' | 'created_at' | 'departure'
+}
+
+export interface GetSOSAlertsParams {
+  status?: string
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -triage by default */
+  sort?: '-created_at' | '-triage' | 'created_at' | 'triage'
+}
+
+export interface GetAllUsersParams {
+  /** Search name, email, city and role */
+  q?: string
+  /** Users in this city */
+  city?: string
+  role?: 'user' | 'admin'
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -created_at by default */
+  sort?: '-city' | '-created_at' | '-email' | '-name' | 'city' | 'created_at' | 'email' | 'name'
+}
+
+export interface GetWebhookDeliveriesParams {
+  endpoint_id?: number
+  status?: 'pending' | 'sending' | 'succeeded' | 'failed'
+  event_type?: string
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -created_at by default */
+  sort?: '-created_at' | 'created_at'
+}
+
+export interface GetCorridorsParams {
+  /** Limit to one city */
+  city_id?: number
+  /** Only active corridors */
+  active?: boolean
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; name by default */
+  sort?: '-created_at' | '-name' | 'created_at' | 'name'
+}
+
+export interface GetFeaturesParams {
+  /** Evaluate for this city */
+  city_id?: number
+  /** Evaluate for this corridor */
+  corridor_id?: number
+}
+
+export interface GetNotificationsParams {
+  /** Only unread notifications */
+  unread?: boolean
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -created_at by default */
+  sort?: '-created_at' | 'created_at'
+}
+
+export interface GetRidesParams {
+  corridor_id?: number
+  date?: string
+  status?: 'open' | 'partially_filled' | 'full' | 'completed' | 'cancelled'
+  /** Rides offered by this driver */
+  user_id?: number
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; departure by default */
+  sort?: '-available_seats' | '-departure' | '-price_per_seat' | 'available_seats' | 'departure' | 'price_per_seat'
+}
+
+export interface GetMessagesParams {
+  /** Only messages after this one */
+  last_id?: number
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; created_at by default */
+  sort?: '-created_at' | 'created_at'
+}
+
+export interface GetPaymentsParams {
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -created_at by default */
+  sort?: '-amount' | '-created_at' | 'amount' | 'created_at'
+}
+
+export interface GetStatsParams {
+  /** Limit to one city */
+  city_id?: number
+}
+
+export interface GetUserReviewsParams {
+  /** Results per page, 50 by default and at most 200 */
+  limit?: number
+  /** next_cursor from the previous page */
+  cursor?: string
+  /** Sort key, prefixed with - for descending; -created_at by default */
+  sort?: '-created_at' | '-rating' | 'created_at' | 'rating'
+}
+
+export interface GetVehiclesParams {
+  /** Include archived vehicles */
+  include_archived?: boolean
+}
+
+// The response interceptor on the axios instance passed in must unwrap
+// response.data, as apiClient in api.ts does
+const unwrap = <T>(response: Promise<unknown>) => response as Promise<T>
+
+export function createClient(http: AxiosInstance) {
+  return {
+    /** Platform totals (admin only) */
+    getAnalytics: () =>
+      unwrap<AnalyticsResponse>(http.get(`/admin/analytics`)),
+
+    /** Rebuild the analytics rollup (admin only) */
+    refreshAnalytics: () =>
+      unwrap<MessageResponse>(http.post(`/admin/analytics/refresh`, undefined)),
+
+    /** Ride metrics over time (admin only) */
+    getAnalyticsTimeSeries: (params?: GetAnalyticsTimeSeriesParams) =>
+      unwrap<TimeSeriesResponse>(http.get(`/admin/analytics/timeseries`, { params })),
+
+    /** Audit log (admin only) */
+    getAuditLog: (params?: GetAuditLogParams) =>
+      unwrap<AuditLogPage>(http.get(`/admin/audit`, { params })),
+
+    /** Audit log as CSV (admin only) */
+    exportAuditLog: (params?: ExportAuditLogParams) =>
+      unwrap<Blob>(http.get(`/admin/audit/export`, { params, responseType: 'blob' })),
+
+    /** Update a city's timezone, currency and locale (admin only) */
+    updateCitySettings: (id: number, body: UpdateCitySettingsRequest) =>
+      unwrap<MessageResponse>(http.put(`/admin/cities/${id}`, body)),
+
+    /** Waitlist demand by route (admin only) */
+    getCityDemand: (id: number, params?: GetCityDemandParams) =>
+      unwrap<DemandReport>(http.get(`/admin/cities/${id}/demand`, { params })),
+
+    /** A city's waitlist (admin only) */
+    getWaitlist: (id: number, params?: GetWaitlistParams) =>
+      unwrap<WaitlistPage>(http.get(`/admin/cities/${id}/waitlist`, { params })),
+
+    /** All feature flags (admin only) */
+    listFeatures: () =>
+      unwrap<FeatureFlag[]>(http.get(`/admin/features`)),
+
+    /** Create a feature flag (admin only) */
+    createFeature: (body: CreateFeatureRequest) =>
+      unwrap<CreatedResponse>(http.post(`/admin/features`, body)),
+
+    /** Update a feature flag (admin only) */
+    toggleFeature: (name: string, body: ToggleFeatureRequest) =>
+      unwrap<MessageResponse>(http.put(`/admin/features/${encodeURIComponent(name)}`, body)),
+
+    /** Delete a feature flag (admin only) */
+    deleteFeature: (name: string) =>
+      unwrap<MessageResponse>(http.delete(`/admin/features/${encodeURIComponent(name)}`)),
+
+    /** Background jobs (admin only) */
+    getJobs: () =>
+      unwrap<ScheduledJob[]>(http.get(`/admin/jobs`)),
+
+    /** Background job run history (admin only) */
+    getJobRuns: (params?: GetJobRunsParams) =>
+      unwrap<JobRunsPage>(http.get(`/admin/jobs/runs`, { params })),
+
+    /** Run a job now (admin only) */
+    runJob: (name: string) =>
+      unwrap<JobScheduledResponse>(http.post(`/admin/jobs/${encodeURIComponent(name)}/run`, undefined)),
+
+    /** Abuse reports (admin only) */
+    getReports: (params?: GetReportsParams) =>
+      unwrap<ReportsPage>(http.get(`/admin/reports`, { params })),
+
+    /** Update a report's status (admin only) */
+    updateReport: (id: number, body: UpdateReportRequest) =>
+      unwrap<MessageResponse>(http.put(`/admin/reports/${id}`, body)),
+
+    /** Reviews for moderation (admin only) */
+    adminGetReviews: (params?: AdminGetReviewsParams) =>
+      unwrap<ReviewsPage>(http.get(`/admin/reviews`, { params })),
+
+    /** Hide or restore a review (admin only) */
+    moderateReview: (id: number, body: ModerateReviewRequest) =>
+      unwrap<MessageResponse>(http.put(`/admin/reviews/${id}`, body)),
+
+    /** Search rides (admin only) */
+    adminGetRides: (params?: AdminGetRidesParams) =>
+      unwrap<AdminRidesPage>(http.get(`/admin/rides`, { params })),
+
+    /** Complete or cancel rides left open (admin only) */
+    closeStaleRides: (body?: CloseStaleRidesRequest) =>
+      unwrap<CloseStaleRidesResponse>(http.post(`/admin/rides/close-stale`, body)),
+
+    /** Cancel a ride and tell everyone on it (admin only) */
+    adminCancelRide: (id: number, body: AdminCancelRideRequest) =>
+      unwrap<AdminCancelRideResponse>(http.post(`/admin/rides/${id}/cancel`, body)),
+
+    /** Give a ride to another driver (admin only) */
+    reassignRide: (id: number, body: ReassignRideRequest) =>
+      unwrap<MessageResponse>(http.post(`/admin/rides/${id}/reassign`, body)),
+
+    /** A ride's full history (admin only) */
+    getRideTimeline: (id: number) =>
+      unwrap<RideTimeline>(http.get(`/admin/rides/${id}/timeline`)),
+
+    /** SOS alerts (admin only) */
+    getSOSAlerts: (params?: GetSOSAlertsParams) =>
+      unwrap<SosAlertsPage>(http.get(`/admin/sos`, { params })),
+
+    /** Resolve an SOS alert (admin only) */
+    resolveSOSAlert: (id: number, body: ResolveSOSAlertRequest) =>
+      unwrap<MessageResponse>(http.put(`/admin/sos/${id}/resolve`, body)),
+
+    /** Search users (admin only) */
+    getAllUsers: (params?: GetAllUsersParams) =>
+      unwrap<UsersPage>(http.get(`/admin/users`, { params })),
+
+    /** Update a user (admin only) */
+    updateUser: (id: number, body: UpdateUserRequest) =>
+      unwrap<MessageResponse>(http.put(`/admin/users/${id}`, body)),
+
+    /** Vehicles awaiting verification (admin only) */
+    getVehicleReviewQueue: () =>
+      unwrap<VehicleReview[]>(http.get(`/admin/vehicles/review`)),
+
+    /** Approve or reject a vehicle (admin only) */
+    reviewVehicle: (id: number, body: ReviewVehicleRequest) =>
+      unwrap<MessageResponse>(http.put(`/admin/vehicles/${id}/review`, body)),
+
+    /** Webhook endpoints (admin only) */
+    getWebhooks: () =>
+      unwrap<WebhookEndpoint[]>(http.get(`/admin/webhooks`)),
+
+    /** Register a webhook endpoint (admin only) */
+    createWebhook: (body: CreateWebhookRequest) =>
+      unwrap<WebhookEndpoint>(http.post(`/admin/webhooks`, body)),
+
+    /** Webhook deliveries, newest first (admin only) */
+    getWebhookDeliveries: (params?: GetWebhookDeliveriesParams) =>
+      unwrap<WebhookDeliveriesPage>(http.get(`/admin/webhooks/deliveries`, { params })),
+
+    /** A delivery with its payload and attempts (admin only) */
+    getWebhookDelivery: (id: number) =>
+      unwrap<WebhookDelivery>(http.get(`/admin/webhooks/deliveries/${id}`)),
+
+    /** Send a delivery again (admin only) */
+    redeliverWebhook: (id: number) =>
+      unwrap<MessageResponse>(http.post(`/admin/webhooks/deliveries/${id}/redeliver`, undefined)),
+
+    /** Event types endpoints can subscribe to (admin only) */
+    getWebhookEventTypes: () =>
+      unwrap<WebhookEventTypesResponse>(http.get(`/admin/webhooks/event-types`)),
+
+    /** Update or re-enable a webhook endpoint (admin only) */
+    updateWebhook: (id: number, body: UpdateWebhookRequest) =>
+      unwrap<WebhookEndpoint>(http.put(`/admin/webhooks/${id}`, body)),
+
+    /** Delete a webhook endpoint (admin only) */
+    deleteWebhook: (id: number) =>
+      unwrap<MessageResponse>(http.delete(`/admin/webhooks/${id}`)),
+
+    /** Replace an endpoint's signing secret (admin only) */
+    rotateWebhookSecret: (id: number) =>
+      unwrap<WebhookSecretResponse>(http.post(`/admin/webhooks/${id}/rotate-secret`, undefined)),
+
+    /** Send a test ping (admin only) */
+    testWebhook: (id: number) =>
+      unwrap<WebhookTestResponse>(http.post(`/admin/webhooks/${id}/test`, undefined)),
+
+    /** Log in with email and password */
+    login: (body: LoginRequest) =>
+      unwrap<LoginResponse>(http.post(`/auth/login`, body)),
+
+    /** Current user's profile */
+    getProfile: () =>
+      unwrap<User>(http.get(`/auth/profile`)),
+
+    /** Update the current user's profile */
+    updateProfile: (body: UpdateProfileRequest) =>
+      unwrap<MessageResponse>(http.put(`/auth/profile`, body)),
+
+    /** Create an account */
+    register: (body: RegisterRequest) =>
+      unwrap<RegisterResponse>(http.post(`/auth/register`, body)),
+
+    /** All cities */
+    getCities: () =>
+      unwrap<City[]>(http.get(`/cities`)),
+
+    /** Launch, lock or schedule a city (admin only) */
+    updateCityStatus: (id: number, body: UpdateCityStatusRequest) =>
+      unwrap<CityStatusResponse>(http.put(`/cities/${id}/status`, body)),
+
+    /** Join a locked city's waitlist */
+    joinWaitlist: (id: number, body: JoinWaitlistRequest) =>
+      unwrap<CreatedResponse>(http.post(`/cities/${id}/waitlist`, body)),
+
+    /** Leave a city's waitlist */
+    leaveWaitlist: (id: number) =>
+      unwrap<MessageResponse>(http.delete(`/cities/${id}/waitlist`)),
+
+    /** Corridors, optionally for one city */
+    getCorridors: (params?: GetCorridorsParams) =>
+      unwrap<CorridorsPage>(http.get(`/corridors`, { params })),
+
+    /** Create a corridor (admin only) */
+    createCorridor: (body: CreateCorridorRequest) =>
+      unwrap<CreatedResponse>(http.post(`/corridors`, body)),
+
+    /** A corridor */
+    getCorridor: (id: number) =>
+      unwrap<Corridor>(http.get(`/corridors/${id}`)),
+
+    /** Update a corridor (admin only) */
+    updateCorridor: (id: number, body: UpdateCorridorRequest) =>
+      unwrap<MessageResponse>(http.put(`/corridors/${id}`, body)),
+
+    /** Delete a corridor (admin only) */
+    deleteCorridor: (id: number) =>
+      unwrap<MessageResponse>(http.delete(`/corridors/${id}`)),
+
+    /** Feature flags evaluated for the current user */
+    getFeatures: (params?: GetFeaturesParams) =>
+      unwrap<Record<string, boolean>>(http.get(`/features`, { params })),
+
+    /** API health status */
+    healthCheck: () =>
+      unwrap<HealthResponse>(http.get(`/health`)),
+
+    /** Current user's notifications, newest first */
+    getNotifications: (params?: GetNotificationsParams) =>
+      unwrap<NotificationsPage>(http.get(`/notifications`, { params })),
+
+    /** Channel preferences per kind of notification */
+    getNotificationPreferences: () =>
+      unwrap<NotificationPreferencesResponse>(http.get(`/notifications/preferences`)),
+
+    /** Turn channels on or off per kind of notification */
+    updateNotificationPreferences: (body: UpdateNotificationPreferencesRequest) =>
+      unwrap<MessageResponse>(http.put(`/notifications/preferences`, body)),
+
+    /** Register a browser for web push */
+    createPushSubscription: (body: CreatePushSubscriptionRequest) =>
+      unwrap<MessageResponse>(http.post(`/notifications/push-subscriptions`, body)),
+
+    /** Unregister a browser from web push */
+    deletePushSubscription: (body: DeletePushSubscriptionRequest) =>
+      unwrap<MessageResponse>(http.delete(`/notifications/push-subscriptions`, { data: body })),
+
+    /** Mark every notification read */
+    markAllNotificationsRead: () =>
+      unwrap<MarkAllReadResponse>(http.put(`/notifications/read-all`, undefined)),
+
+    /** Number of unread notifications */
+    getUnreadNotificationCount: () =>
+      unwrap<UnreadCountResponse>(http.get(`/notifications/unread-count`)),
+
+    /** Mark a notification read */
+    markNotificationRead: (id: number) =>
+      unwrap<MessageResponse>(http.put(`/notifications/${id}/read`, undefined)),
+
+    /** This OpenAPI document */
+    getOpenAPISpec: () =>
+      unwrap<Record<string, unknown>>(http.get(`/openapi.json`)),
+
+    /** Report a user, message or ride */
+    createReport: (body: CreateReportRequest) =>
+      unwrap<CreatedResponse>(http.post(`/reports`, body)),
+
+    /** Rides visible to the current user */
+    getRides: (params?: GetRidesParams) =>
+      unwrap<RidesPage>(http.get(`/rides`, { params })),
+
+    /** Offer a ride */
+    createRide: (body: CreateRideRequest) =>
+      unwrap<CreatedResponse>(http.post(`/rides`, body)),
+
+    /** A ride */
+    getRide: (id: number) =>
+      unwrap<Ride>(http.get(`/rides/${id}`)),
+
+    /** Update a ride */
+    updateRide: (id: number, body: UpdateRideRequest) =>
+      unwrap<MessageResponse>(http.put(`/rides/${id}`, body)),
+
+    /** Cancel a ride */
+    cancelRide: (id: number) =>
+      unwrap<MessageResponse>(http.delete(`/rides/${id}`)),
+
+    /** A ride's chat messages */
+    getMessages: (id: number, params?: GetMessagesParams) =>
+      unwrap<MessagesPage>(http.get(`/rides/${id}/messages`, { params })),
+
+    /** Send a chat message */
+    createMessage: (id: number, body: CreateMessageRequest) =>
+      unwrap<CreatedResponse>(http.post(`/rides/${id}/messages`, body)),
+
+    /** A ride's payments */
+    getPayments: (id: number, params?: GetPaymentsParams) =>
+      unwrap<PaymentsPage>(http.get(`/rides/${id}/payments`, { params })),
+
+    /** Record a payment */
+    createPayment: (id: number, body: CreatePaymentRequest) =>
+      unwrap<MessageResponse>(http.post(`/rides/${id}/payments`, body)),
+
+    /** Mark a payment sent or received */
+    updatePaymentStatus: (id: number, userId: number, body: UpdatePaymentStatusRequest) =>
+      unwrap<MessageResponse>(http.put(`/rides/${id}/payments/${userId}`, body)),
+
+    /** Requests for seats on a ride */
+    getRideRequests: (id: number) =>
+      unwrap<RideRequest[]>(http.get(`/rides/${id}/requests`)),
+
+    /** Request seats on a ride */
+    createRideRequest: (id: number, body: CreateRideRequestBody) =>
+      unwrap<CreatedResponse>(http.post(`/rides/${id}/requests`, body)),
+
+    /** Accept or reject a request */
+    updateRideRequest: (id: number, requestId: number, body: UpdateRideRequestBody) =>
+      unwrap<MessageResponse>(http.put(`/rides/${id}/requests/${requestId}`, body)),
+
+    /** Reviews the current user gave on a ride and who is left */
+    getRideReviews: (id: number) =>
+      unwrap<RideReviewsResponse>(http.get(`/rides/${id}/reviews`)),
+
+    /** Review another participant */
+    createReview: (id: number, body: CreateReviewRequest) =>
+      unwrap<CreatedResponse>(http.post(`/rides/${id}/reviews`, body)),
+
+    /** Create a link to share a ride */
+    createShareLink: (id: number, body?: CreateShareLinkRequest) =>
+      unwrap<ShareLinkResponse>(http.post(`/rides/${id}/share`, body)),
+
+    /** Revoke a ride's share links */
+    revokeShareLinks: (id: number) =>
+      unwrap<RevokeShareLinksResponse>(http.delete(`/rides/${id}/share`)),
+
+    /** Raise an SOS alert */
+    raiseSOS: (id: number, body: RaiseSOSRequest) =>
+      unwrap<RaiseSOSResponse>(http.post(`/rides/${id}/sos`, body)),
+
+    /** Public view of a shared ride */
+    getSharedRide: (token: string) =>
+      unwrap<SharedRide>(http.get(`/share/${encodeURIComponent(token)}`)),
+
+    /** Rides today and users online */
+    getStats: (params?: GetStatsParams) =>
+      unwrap<StatsResponse>(http.get(`/stats`, { params })),
+
+    /** Users the current user has blocked */
+    getBlockedUsers: () =>
+      unwrap<BlockedUser[]>(http.get(`/user/blocks`)),
+
+    /** Block a user */
+    blockUser: (body: BlockUserRequest) =>
+      unwrap<MessageResponse>(http.post(`/user/blocks`, body)),
+
+    /** Unblock a user */
+    unblockUser: (userId: number) =>
+      unwrap<MessageResponse>(http.delete(`/user/blocks/${userId}`)),
+
+    /** Corridors assigned to the current user */
+    getUserCorridors: () =>
+      unwrap<Corridor[]>(http.get(`/user/corridors`)),
+
+    /** Assign a corridor to a user (admin only) */
+    assignCorridor: (body: AssignCorridorRequest) =>
+      unwrap<MessageResponse>(http.post(`/user/corridors`, body)),
+
+    /** Current user's emergency contacts */
+    getEmergencyContacts: () =>
+      unwrap<EmergencyContact[]>(http.get(`/user/emergency-contacts`)),
+
+    /** Add an emergency contact */
+    createEmergencyContact: (body: CreateEmergencyContactRequest) =>
+      unwrap<CreatedResponse>(http.post(`/user/emergency-contacts`, body)),
+
+    /** Remove an emergency contact */
+    deleteEmergencyContact: (contactId: number) =>
+      unwrap<MessageResponse>(http.delete(`/user/emergency-contacts/${contactId}`)),
+
+    /** A user's rating summary */
+    getUserReviews: (id: number, params?: GetUserReviewsParams) =>
+      unwrap<RatingSummary>(http.get(`/users/${id}/reviews`, { params })),
+
+    /** Current user's vehicles */
+    getVehicles: (params?: GetVehiclesParams) =>
+      unwrap<Vehicle[]>(http.get(`/vehicles`, { params })),
+
+    /** Add a vehicle */
+    createVehicle: (body: CreateVehicleRequest) =>
+      unwrap<VehicleCreatedResponse>(http.post(`/vehicles`, body)),
+
+    /** A vehicle */
+    getVehicle: (id: number) =>
+      unwrap<Vehicle>(http.get(`/vehicles/${id}`)),
+
+    /** Update a vehicle */
+    updateVehicle: (id: number, body: UpdateVehicleRequest) =>
+      unwrap<MessageResponse>(http.put(`/vehicles/${id}`, body)),
+
+    /** Archive a vehicle */
+    deleteVehicle: (id: number) =>
+      unwrap<MessageResponse>(http.delete(`/vehicles/${id}`)),
+
+    /** A vehicle's documents */
+    getVehicleDocuments: (id: number) =>
+      unwrap<VehicleDocument[]>(http.get(`/vehicles/${id}/documents`)),
+
+    /** Upload an RC, insurance or driving licence */
+    uploadVehicleDocument: (id: number, form: FormData) =>
+      unwrap<CreatedResponse>(http.post(`/vehicles/${id}/documents`, form)),
+
+    /** Download a document */
+    downloadVehicleDocument: (id: number, docId: number) =>
+      unwrap<Blob>(http.get(`/vehicles/${id}/documents/${docId}/file`, { responseType: 'blob' })),
+
+    /** Move upcoming rides to another vehicle */
+    swapVehicle: (id: number, body: SwapVehicleRequest) =>
+      unwrap<SwapVehicleResponse>(http.post(`/vehicles/${id}/swap`, body)),
+  }
+}
+
+export type Client = ReturnType<typeof createClient>
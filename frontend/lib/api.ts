@@ -1,4 +1,5 @@
 import axios from 'axios'
+import { createClient } from './api.gen'
 
 const API_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080/api'
 
@@ -55,7 +56,27 @@ export const api = {
   
   delete: (endpoint: string) =>
     apiClient.delete(endpoint),
+
+  // Follows next_cursor through every page of a paginated list; key is the
+  // field holding the items, e.g. 'rides'
+  getAll: async <T>(endpoint: string, key: string): Promise<T[]> => {
+    const items: T[] = []
+    const separator = endpoint.includes('?') ? '&' : '?'
+    let cursor: string | null = null
+    do {
+      const params: string = cursor ? `${separator}cursor=${encodeURIComponent(cursor)}` : ''
+      const page: any = await apiClient.get(endpoint + params)
+      items.push(...page[key])
+      cursor = page.next_cursor
+    } while (cursor)
+    return items
+  },
 }
 
+// Typed functions for every endpoint, generated from the backend's OpenAPI
+// document. Regenerate with `go run ./cmd/openapi` in backend/.
+export const client = createClient(apiClient)
+export * from './api.gen'
+
 export default apiClient
 
@@ -14,7 +14,7 @@ if (-not (Test-Path "frontend\.env.local")) {
 
 # Start backend in background
 Write-Host "`n🔧 Starting backend server..." -ForegroundColor Yellow
-Start-Process powershell -ArgumentList "-NoExit", "-Command", "cd backend; Write-Host 'Backend starting on http://localhost:8080' -ForegroundColor Green; go run main.go"
+Start-Process powershell -ArgumentList "-NoExit", "-Command", "cd backend; Write-Host 'Backend starting on http://localhost:8080' -ForegroundColor Green; `$env:APP_ENV = 'development'; go run main.go"
 
 # Wait a bit for backend to start
 Start-Sleep -Seconds 3
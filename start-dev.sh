@@ -18,7 +18,7 @@ fi
 echo ""
 echo "🔧 Starting backend server..."
 cd backend
-go run main.go &
+APP_ENV=${APP_ENV:-development} go run main.go &
 BACKEND_PID=$!
 cd ..
 